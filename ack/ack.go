@@ -0,0 +1,45 @@
+// Package ack carries the acknowledgements of the consumed events from
+// an ingress to the ingestion, the ingestion acknowledges an event once
+// it has been stored so the ingress can commit it (e.g. kafka offsets).
+package ack
+
+// Event represents a consumed event and its acknowledgement.
+type Event struct {
+	Value interface{}
+	Ack   func()
+}
+
+// Split returns the value and the acknowledgement of an event, the
+// acknowledgement is a no-op if the ingress doesn't acknowledge.
+func Split(i interface{}) (interface{}, func()) {
+	if e, ok := i.(Event); ok {
+		return e.Value, e.Ack
+	}
+
+	return i, nop
+}
+
+// Batch represents the acknowledgements of a batch.
+type Batch []func()
+
+// Add adds an acknowledgement to the batch.
+func (b *Batch) Add(fn func()) {
+	*b = append(*b, fn)
+}
+
+// Done acknowledges the batch and resets it.
+func (b *Batch) Done() {
+	for _, fn := range *b {
+		fn()
+	}
+
+	b.Reset()
+}
+
+// Reset resets the batch without acknowledging, the
+// events are redelivered by the ingress.
+func (b *Batch) Reset() {
+	*b = (*b)[:0]
+}
+
+func nop() {}
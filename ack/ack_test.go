@@ -0,0 +1,39 @@
+package ack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	acked := 0
+
+	v, fn := Split(Event{Value: "foo", Ack: func() { acked++ }})
+	assert.Equal(t, "foo", v)
+	fn()
+	assert.Equal(t, 1, acked)
+
+	v, fn = Split("bar")
+	assert.Equal(t, "bar", v)
+	fn()
+	assert.Equal(t, 1, acked)
+}
+
+func TestBatch(t *testing.T) {
+	var (
+		b     Batch
+		acked int
+	)
+
+	b.Add(func() { acked++ })
+	b.Add(func() { acked++ })
+	b.Done()
+	assert.Equal(t, 2, acked)
+	assert.Len(t, b, 0)
+
+	b.Add(func() { acked++ })
+	b.Reset()
+	b.Done()
+	assert.Equal(t, 2, acked)
+}
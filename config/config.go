@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -23,10 +24,25 @@ type ctxKey string
 type Config struct {
 	Tracepoints []Tracepoint
 	Fields      map[string][]Field
+	Derived     map[string][]DerivedField
+	Routes      map[string][]Route
 	Egress      map[string]EgressConfig
 	Log         *zap.Config
 
-	logger *zap.Logger
+	// UnavailableFields is the policy for the fields which are
+	// not available at the running kernel: fail or drop.
+	UnavailableFields string `yaml:"unavailable_fields"`
+
+	// ShutdownTimeout is the maximum time in seconds to drain
+	// and flush the egresses at shutdown.
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+
+	// API is the address of the agent's http api, e.g. localhost:8086
+	API string `yaml:"api"`
+
+	logger  *zap.Logger
+	file    string
+	version string
 }
 
 // TLSConfig represents TLS configuration.
@@ -36,12 +52,20 @@ type TLSConfig struct {
 	CertFile           string
 	KeyFile            string
 	CAFile             string
+	ClientAuth         bool // server verifies the client certificates by the CA
 }
 
 // EgressConfig represents egress configuration.
 type EgressConfig struct {
 	Type   string
 	Config map[string]interface{}
+
+	// ChannelSize is the capacity of the egress channel.
+	ChannelSize int `yaml:"channel_size"`
+
+	// Overflow is the policy once the egress channel is full:
+	// drop-newest, drop-oldest, block or sample.
+	Overflow string `yaml:"overflow"`
 }
 
 // cliRequest represents cli requests.
@@ -62,6 +86,11 @@ func (c *Config) Logger() *zap.Logger {
 	return c.logger
 }
 
+// Version returns the agent's version.
+func (c *Config) Version() string {
+	return c.version
+}
+
 // WithContext returns new context including configuration.
 func (c *Config) WithContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, ctxKey("cfg"), c)
@@ -103,6 +132,71 @@ type Tracepoint struct {
 	Workers  int    `yaml:"workers"`
 	INet     []int  `yaml:"inet"`
 	Egress   string `yaml:"egress"`
+
+	// Egresses fans out the events to multiple egresses,
+	// it's mutually exclusive with the egress.
+	Egresses []EgressRef `yaml:"egresses"`
+
+	// Routes is the name of the routing table which routes
+	// the events based on their content.
+	Routes string `yaml:"routes"`
+}
+
+// Route represents a routing rule, the events which match the expression
+// are sent to the egresses. the routes are evaluated in order and the
+// first match wins, a route without match expression matches all.
+type Route struct {
+	Match    string      `yaml:"match"`
+	Egresses []EgressRef `yaml:"egresses"`
+}
+
+// EgressRef represents an egress of a tracepoint with
+// an optional field projection and filter.
+type EgressRef struct {
+	Name   string   `yaml:"name"`
+	Fields []string `yaml:"fields,omitempty"`
+	Filter string   `yaml:"filter,omitempty"`
+}
+
+// EgressRefs returns the tracepoint's egresses.
+func (t Tracepoint) EgressRefs() []EgressRef {
+	if len(t.Egresses) > 0 {
+		return t.Egresses
+	}
+
+	return []EgressRef{{Name: t.Egress}}
+}
+
+// GetTPEgressRefs returns the tracepoint's egresses, including
+// the egresses of its routing table.
+func (c *Config) GetTPEgressRefs(tp Tracepoint) []EgressRef {
+	if tp.Routes == "" {
+		return tp.EgressRefs()
+	}
+
+	var (
+		refs []EgressRef
+		seen = map[string]bool{}
+	)
+
+	for _, route := range c.Routes[tp.Routes] {
+		for _, ref := range route.Egresses {
+			if !seen[ref.Name] {
+				seen[ref.Name] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	return refs
+}
+
+// ForEgress returns a copy of the tracepoint bound to one of its egresses.
+func (t Tracepoint) ForEgress(ref EgressRef) Tracepoint {
+	t.Egress = ref.Name
+	t.Egresses = []EgressRef{ref}
+
+	return t
 }
 
 // Field represents a field.
@@ -112,6 +206,13 @@ type Field struct {
 	Filter string `yaml:"filter,omitempty"`
 }
 
+// DerivedField represents a field which is computed in user space
+// from the captured fields of the same fields template.
+type DerivedField struct {
+	Name string `yaml:"name"`
+	Expr string `yaml:"expr"`
+}
+
 // GetTPFields returns a tracepoint fields.
 func (c *Config) GetTPFields(name string) []string {
 	fields := []string{}
@@ -124,6 +225,45 @@ func (c *Config) GetTPFields(name string) []string {
 	return fields
 }
 
+// GetTPOutFields returns a tracepoint captured and derived fields
+// in the same order as they're encoded.
+func (c *Config) GetTPOutFields(name string) []Field {
+	fields := append([]Field{}, c.Fields[name]...)
+	for _, d := range c.Derived[name] {
+		fields = append(fields, Field{Name: d.Name})
+	}
+
+	return fields
+}
+
+// GetEgressOutFields returns the output fields of a tracepoint which
+// is bound to an egress, the fields are projected if it's requested.
+func (c *Config) GetEgressOutFields(tp Tracepoint) []Field {
+	fields := c.GetTPOutFields(tp.Fields)
+
+	for _, ref := range tp.Egresses {
+		if ref.Name != tp.Egress || len(ref.Fields) < 1 {
+			continue
+		}
+
+		projection := map[string]bool{}
+		for _, name := range ref.Fields {
+			projection[name] = true
+		}
+
+		var projected []Field
+		for _, f := range fields {
+			if projection[f.Name] {
+				projected = append(projected, f)
+			}
+		}
+
+		return projected
+	}
+
+	return fields
+}
+
 // load reads yaml configuration
 func load(file string) (*Config, error) {
 	f, err := os.Open(file)
@@ -154,6 +294,24 @@ func setDefault(conf *Config) {
 		}
 	}
 
+	if conf.UnavailableFields == "" {
+		conf.UnavailableFields = "fail"
+	}
+
+	if conf.ShutdownTimeout < 1 {
+		conf.ShutdownTimeout = 5
+	}
+
+	for name, e := range conf.Egress {
+		if e.ChannelSize < 1 {
+			e.ChannelSize = 1000
+		}
+		if e.Overflow == "" {
+			e.Overflow = "drop-newest"
+		}
+		conf.Egress[name] = e
+	}
+
 	// set default logger
 	if conf.logger == nil {
 		conf.logger = GetDefaultLogger()
@@ -185,15 +343,40 @@ func Get(args []string, version string) (*Config, error) {
 		}
 
 		config.logger = GetLogger(config.Log)
+		config.file = cli.Config
+		config.version = version
 
 		return config, nil
 	}
 
 	config, err = cliToConfig(cli)
+	if config != nil {
+		config.version = version
+	}
 
 	return config, err
 }
 
+// Reload reads the configuration file again, the logger
+// is shared with the running configuration.
+func (c *Config) Reload() (*Config, error) {
+	if c.file == "" {
+		return nil, errors.New("reload requires a configuration file")
+	}
+
+	config, err := load(c.file)
+	if err != nil {
+		return nil, err
+	}
+
+	config.logger = c.logger
+	config.file = c.file
+	config.version = c.version
+	setDefault(config)
+
+	return config, nil
+}
+
 func cliToConfig(cli *cliRequest) (*Config, error) {
 	var inet []int
 
@@ -322,6 +505,15 @@ func GetTLS(cfg *TLSConfig) (*tls.Config, error) {
 		tlsConfig.RootCAs = caCertPool
 	}
 
+	if cfg.ClientAuth {
+		if caCertPool == nil {
+			return nil, errors.New("client authentication requires a CA file")
+		}
+
+		tlsConfig.ClientCAs = caCertPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
 	tlsConfig.InsecureSkipVerify = cfg.InsecureSkipVerify
 
 	return tlsConfig, nil
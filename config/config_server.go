@@ -49,7 +49,15 @@ type ServerConfig struct {
 	Geo       Geo
 	Log       *zap.Config
 
+	// ShutdownTimeout is the maximum time in seconds to drain
+	// and flush the flows at shutdown.
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+
+	// API is the address of the server's http api, e.g. localhost:8087
+	API string `yaml:"api"`
+
 	logger *zap.Logger
+	file   string
 }
 
 // Logger returns logger
@@ -138,11 +146,31 @@ func GetServer(args []string, version string) (*ServerConfig, error) {
 	}
 
 	config.logger = GetLogger(config.Log)
+	config.file = cli.Config
+
+	return config, nil
+}
+
+// Reload reads the configuration file again, the logger
+// is shared with the running configuration.
+func (c *ServerConfig) Reload() (*ServerConfig, error) {
+	config, err := loadServer(c.file)
+	if err != nil {
+		return nil, err
+	}
+
+	config.logger = c.logger
+	config.file = c.file
+	setDefaultServer(config)
 
 	return config, nil
 }
 
 func setDefaultServer(conf *ServerConfig) {
+	if conf.ShutdownTimeout < 1 {
+		conf.ShutdownTimeout = 5
+	}
+
 	if conf.logger == nil {
 		conf.logger = GetDefaultLogger()
 	}
@@ -5,11 +5,13 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
 	"io"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"testing"
@@ -99,12 +101,77 @@ func TestGetTPFields(t *testing.T) {
 	assert.Equal(t, "f2", s[1])
 }
 
+func TestGetTPOutFields(t *testing.T) {
+	c := &Config{
+		Fields: map[string][]Field{
+			"foo": {{Name: "f1"}, {Name: "f2"}},
+		},
+		Derived: map[string][]DerivedField{
+			"foo": {{Name: "d1", Expr: "f1 / f2"}},
+		},
+	}
+
+	f := c.GetTPOutFields("foo")
+	assert.Len(t, f, 3)
+	assert.Equal(t, "d1", f[2].Name)
+	assert.Len(t, c.Fields["foo"], 2)
+}
+
+func TestGetEgressOutFields(t *testing.T) {
+	c := &Config{
+		Fields: map[string][]Field{
+			"foo": {{Name: "f1"}, {Name: "f2"}},
+		},
+		Derived: map[string][]DerivedField{
+			"foo": {{Name: "d1", Expr: "f1 / f2"}},
+		},
+	}
+
+	tp := Tracepoint{
+		Fields: "foo",
+		Egresses: []EgressRef{
+			{Name: "e1"},
+			{Name: "e2", Fields: []string{"d1", "f1"}},
+		},
+	}
+
+	assert.Equal(t, []EgressRef{{Name: "bar"}}, Tracepoint{Egress: "bar"}.EgressRefs())
+	assert.Len(t, tp.EgressRefs(), 2)
+
+	f := c.GetEgressOutFields(tp.ForEgress(tp.Egresses[0]))
+	assert.Len(t, f, 3)
+
+	// it keeps the template order
+	f = c.GetEgressOutFields(tp.ForEgress(tp.Egresses[1]))
+	assert.Equal(t, []Field{{Name: "f1"}, {Name: "d1"}}, f)
+}
+
+func TestGetTPEgressRefs(t *testing.T) {
+	c := &Config{
+		Routes: map[string][]Route{
+			"foo": {
+				{Match: "DPort == 443", Egresses: []EgressRef{{Name: "e1"}, {Name: "e2"}}},
+				{Egresses: []EgressRef{{Name: "e3"}, {Name: "e2"}}},
+			},
+		},
+	}
+
+	refs := c.GetTPEgressRefs(Tracepoint{Routes: "foo"})
+	assert.Equal(t, []EgressRef{{Name: "e1"}, {Name: "e2"}, {Name: "e3"}}, refs)
+
+	refs = c.GetTPEgressRefs(Tracepoint{Egress: "e1"})
+	assert.Equal(t, []EgressRef{{Name: "e1"}}, refs)
+}
+
 func TestSetDefault(t *testing.T) {
 	c := &Config{
 		Tracepoints: []Tracepoint{{Name: "foo"}},
+		Egress:      map[string]EgressConfig{"bar": {Type: "console"}},
 	}
 	setDefault(c)
 	assert.Equal(t, 1, c.Tracepoints[0].Workers)
+	assert.Equal(t, 1000, c.Egress["bar"].ChannelSize)
+	assert.Equal(t, "drop-newest", c.Egress["bar"].Overflow)
 	assert.NotNil(t, c.logger)
 }
 
@@ -150,6 +217,33 @@ func TestGet(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestReload(t *testing.T) {
+	filename := os.TempDir() + "/config_reload.yml"
+	defer os.Remove(filename)
+
+	err := ioutil.WriteFile(filename, []byte("tracepoints:\n  - name: foo\n"), 0644)
+	assert.NoError(t, err)
+
+	c, err := Get([]string{"tcpdog", "-config", filename}, "0.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", c.Tracepoints[0].Name)
+
+	err = ioutil.WriteFile(filename, []byte("tracepoints:\n  - name: bar\n"), 0644)
+	assert.NoError(t, err)
+
+	n, err := c.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", n.Tracepoints[0].Name)
+	assert.Equal(t, 1, n.Tracepoints[0].Workers)
+	assert.Equal(t, c.Logger(), n.Logger())
+
+	// cli configuration
+	c, err = Get([]string{"tcpdog"}, "0.0.0")
+	assert.NoError(t, err)
+	_, err = c.Reload()
+	assert.Error(t, err)
+}
+
 func TestGetTLSCreds(t *testing.T) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
 	assert.NoError(t, err)
@@ -218,6 +312,15 @@ func TestGetTLSCreds(t *testing.T) {
 	_, err = GetCreds(cfg)
 	assert.NoError(t, err)
 
+	// mutual tls
+	cfg.ClientAuth = true
+	tlsConfig, err = GetTLS(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	assert.NotNil(t, tlsConfig.ClientCAs)
+
+	cfg.ClientAuth = false
+
 	// wrong files
 	cfg.CAFile = "foo"
 	_, err = GetTLS(cfg)
@@ -356,6 +459,31 @@ flow:
 	assert.Error(t, err)
 }
 
+func TestReloadServer(t *testing.T) {
+	filename := os.TempDir() + "/server_reload.yml"
+	defer os.Remove(filename)
+
+	err := ioutil.WriteFile(filename, []byte("flow:\n  - ingress: foo\n"), 0644)
+	assert.NoError(t, err)
+
+	c, err := GetServer([]string{"tcpdog", "-config", filename}, "0.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", c.Flow[0].Ingress)
+
+	err = ioutil.WriteFile(filename, []byte("flow:\n  - ingress: bar\n"), 0644)
+	assert.NoError(t, err)
+
+	n, err := c.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", n.Flow[0].Ingress)
+	assert.Equal(t, 5, n.ShutdownTimeout)
+	assert.Equal(t, c.Logger(), n.Logger())
+
+	os.Remove(filename)
+	_, err = c.Reload()
+	assert.Error(t, err)
+}
+
 func TestConfigContextLoggerServer(t *testing.T) {
 	c := &ServerConfig{
 		Ingress: map[string]Ingress{"foo": {Type: "grpc"}},
@@ -3,8 +3,11 @@ package ebpf
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	bpf "github.com/iovisor/gobpf/bcc"
 	"go.uber.org/zap"
@@ -12,94 +15,288 @@ import (
 	"github.com/mehrdadrad/tcpdog/config"
 )
 
-// BPF represents eBPF procedures.
+// drainPeriod is the time that perf buffers are polled
+// after the tracepoints have been detached.
+const drainPeriod = 100 * time.Millisecond
+
+// BPF represents eBPF procedures, each tracepoint has its own
+// module so it can be attached and detached independently.
 type BPF struct {
+	sync.Mutex
+
+	probes   map[int]*probe
+	counters counters
+	perfLost uint64 // lost at the perf buffers
+}
+
+// perfReader represents a perf buffer poller (bpf.PerfMap).
+type perfReader interface {
+	Stop()
+}
+
+// probe represents a tracepoint bpf module and its perf buffers.
+type probe struct {
 	m        *bpf.Module
-	perfMaps []*bpf.PerfMap
+	suffix   int
+	perfMaps []perfReader
+	perfChs  []chan []byte
+	lostChs  []chan uint64
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	quit     chan struct{}
 }
 
 // TP represents a tracepoint
 type TP struct {
 	Name    string
 	BufPool *sync.Pool
-	OutChan chan *bytes.Buffer
+	Outputs []Output
+	Routes  []Route
 	Index   int
 	Workers int
 	INet    []int
 	Fields  []string
+	Derived []config.DerivedField
 }
 
-// New generates and loads the bpf program.
+// New generates and loads the bpf programs.
 func New(conf *config.Config) *BPF {
-	code, err := GetBPFCode(conf)
+	b := &BPF{probes: map[int]*probe{}, counters: newCounters()}
+
+	for index := range conf.Tracepoints {
+		if err := b.Load(conf, index, index); err != nil {
+			conf.Logger().Fatal("ebpf", zap.Error(err))
+		}
+	}
+
+	return b
+}
+
+// Load generates and loads the bpf program of a tracepoint at
+// the configuration, the loaded program is identified by the id.
+func (b *BPF) Load(conf *config.Config, index, id int) error {
+	code, err := GetTracepointBPFCode(conf, index)
 	if err != nil {
-		conf.Logger().Fatal("ebpf", zap.Error(err))
+		return err
 	}
 
 	m := bpf.NewModule(code, []string{})
+	if m == nil {
+		return errors.New("failed to compile the BPF program")
+	}
 
-	return &BPF{m: m}
+	b.Lock()
+	defer b.Unlock()
+
+	if p, ok := b.probes[id]; ok {
+		p.m.Close()
+	}
+
+	b.probes[id] = &probe{
+		m:      m,
+		suffix: index,
+		stop:   make(chan struct{}),
+		quit:   make(chan struct{}),
+	}
+
+	return nil
 }
 
 // Start loads and attaches tracepoint and approperiate channel
 func (b *BPF) Start(ctx context.Context, tp TP) {
+	if err := b.Attach(ctx, tp); err != nil {
+		config.FromContext(ctx).Logger().Fatal("ebpf", zap.Error(err))
+	}
+}
+
+// Attach attaches a loaded tracepoint, tp.Index is the id of the loaded
+// program. the decoders, the perf buffers and the workers are set up
+// before attaching, the program is unloaded if it fails.
+func (b *BPF) Attach(ctx context.Context, tp TP) (err error) {
 	logger := config.FromContext(ctx).Logger()
 
-	trace, err := b.m.LoadTracepoint(fmt.Sprintf("sk_trace%d", tp.Index))
+	b.Lock()
+	p, ok := b.probes[tp.Index]
+	b.Unlock()
+
+	if !ok {
+		return fmt.Errorf("tracepoint %d has not been loaded", tp.Index)
+	}
+
+	defer func() {
+		if err != nil {
+			b.Stop(tp.Index)
+		}
+	}()
+
+	trace, err := p.m.LoadTracepoint(fmt.Sprintf("sk_trace%d", p.suffix))
 	if err != nil {
-		logger.Fatal("ebpf", zap.Error(err))
+		return err
 	}
 
-	if err := b.m.AttachTracepoint(tp.Name, trace); err != nil {
-		logger.Fatal("ebpf", zap.Error(err))
+	type perfBuffer struct {
+		perfMap  *bpf.PerfMap
+		ch       chan []byte
+		lostCh   chan uint64
+		decoders []*decoder
 	}
 
-	logger.Info("ebpf", zap.String("msg", tp.Name+" has been attached"))
+	var buffers []perfBuffer
 
 	for _, version := range tp.INet {
-		table := bpf.NewTable(b.m.TableId(fmt.Sprintf("ipv%d_events%d", version, tp.Index)), b.m)
-		ch := make(chan []byte, 1000)
-
-		perfMap, err := bpf.InitPerfMap(table, ch, nil)
-		if err != nil {
-			logger.Fatal("ebpf", zap.Error(err))
+		pb := perfBuffer{
+			ch:     make(chan []byte, 1000),
+			lostCh: make(chan uint64),
 		}
 
 		for i := 0; i < tp.Workers; i++ {
-			go func(version int) {
-				var data []byte
+			d := newDecoder(logger, (version == 4))
+			if err := d.setDerived(tp.Derived); err != nil {
+				return err
+			}
 
-				d := newDecoder(logger, (version == 4))
+			pb.decoders = append(pb.decoders, d)
+		}
 
-				for {
-					select {
-					case data = <-ch:
-					case <-ctx.Done():
-						return
-					}
+		table := bpf.NewTable(p.m.TableId(fmt.Sprintf("ipv%d_events%d", version, p.suffix)), p.m)
+		pb.perfMap, err = bpf.InitPerfMap(table, pb.ch, pb.lostCh)
+		if err != nil {
+			return err
+		}
 
+		buffers = append(buffers, pb)
+	}
+
+	for _, pb := range buffers {
+		for _, d := range pb.decoders {
+			p.wg.Add(1)
+			go func(d *decoder, ch chan []byte) {
+				defer p.wg.Done()
+
+				// it drains the perf channel until it's closed
+				for data := range ch {
 					buf := tp.BufPool.Get().(*bytes.Buffer)
 					buf.Reset()
 					d.decode(data, tp.Fields, buf)
 
-					select {
-					case tp.OutChan <- buf:
-					default:
-						logger.Warn("ebpf", zap.String("msg", "egress channel maxed out"))
-					}
+					b.tee(p, tp, buf, logger)
 				}
-			}(version)
+			}(d, pb.ch)
 		}
 
-		perfMap.Start()
-		b.perfMaps = append(b.perfMaps, perfMap)
+		go b.lost(pb.lostCh)
+
+		pb.perfMap.Start()
+		p.perfMaps = append(p.perfMaps, pb.perfMap)
+		p.perfChs = append(p.perfChs, pb.ch)
+		p.lostChs = append(p.lostChs, pb.lostCh)
 	}
+
+	// the events are emitted once it's attached
+	if err := p.m.AttachTracepoint(tp.Name, trace); err != nil {
+		return err
+	}
+
+	logger.Info("ebpf", zap.String("msg", tp.Name+" has been attached"))
+
+	for _, out := range tp.outputs() {
+		if out.Overflow == OverflowSample {
+			go b.adapt(p, tp, logger)
+			break
+		}
+	}
+
+	return nil
+}
+
+// Dropped returns number of events which have been dropped
+// because of the egress channel was maxed out or they have
+// been lost at the perf buffers.
+func (b *BPF) Dropped() uint64 {
+	dropped := b.PerfLost()
+	for _, v := range b.counters {
+		dropped += atomic.LoadUint64(v)
+	}
+
+	return dropped
+}
+
+// PerfLost returns number of events which have been lost at the perf buffers.
+func (b *BPF) PerfLost() uint64 {
+	return atomic.LoadUint64(&b.perfLost)
+}
+
+// Counters returns number of dropped events per overflow policy.
+func (b *BPF) Counters() map[string]uint64 {
+	c := map[string]uint64{}
+	for policy, v := range b.counters {
+		c[policy] = atomic.LoadUint64(v)
+	}
+
+	return c
 }
 
-// Close cleans up BPF attachments
+// Stop detaches a tracepoint, drains its perf buffers and returns
+// once all its workers handed over the events to the egress.
+func (b *BPF) Stop(id int) {
+	b.Lock()
+	p, ok := b.probes[id]
+	delete(b.probes, id)
+	b.Unlock()
+
+	if ok {
+		p.close()
+	}
+}
+
+// Close detaches the tracepoints, drains the perf buffers and
+// returns once all the workers handed over the events to the egresses.
 func (b *BPF) Close() {
-	for _, perfMap := range b.perfMaps {
+	var wg sync.WaitGroup
+
+	b.Lock()
+	for id, p := range b.probes {
+		wg.Add(1)
+		go func(p *probe) {
+			defer wg.Done()
+			p.close()
+		}(p)
+		delete(b.probes, id)
+	}
+	b.Unlock()
+
+	wg.Wait()
+}
+
+func (p *probe) close() {
+	close(p.stop)
+	p.m.Close()
+
+	time.Sleep(drainPeriod)
+	p.drain()
+}
+
+// drain stops the perf buffers and waits for the workers. a perf buffer
+// can't be stopped while its poller is blocked on the full perf channel,
+// so the blocked workers drop the rest of events once the egress doesn't
+// make progress within the block timeout.
+func (p *probe) drain() {
+	timer := time.AfterFunc(blockTimeout, func() {
+		close(p.quit)
+	})
+	defer timer.Stop()
+
+	for _, perfMap := range p.perfMaps {
 		perfMap.Stop()
 	}
-	b.m.Close()
+
+	for _, ch := range p.perfChs {
+		close(ch)
+	}
+
+	for _, ch := range p.lostChs {
+		close(ch)
+	}
+
+	p.wg.Wait()
 }
@@ -53,7 +53,7 @@ func TestStart(t *testing.T) {
 	eBPF.Start(ctx, TP{
 		Name:    "sock:inet_sock_set_state",
 		BufPool: bufPool,
-		OutChan: ch,
+		Outputs: []Output{{Name: "console", Chan: ch}},
 		Index:   0,
 		Workers: 1,
 		INet:    []int{4},
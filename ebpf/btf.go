@@ -0,0 +1,220 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	btfMagic = 0xeb9f
+
+	btfKindInt      = 1
+	btfKindPtr      = 2
+	btfKindArray    = 3
+	btfKindStruct   = 4
+	btfKindUnion    = 5
+	btfKindEnum     = 6
+	btfKindFwd      = 7
+	btfKindTypedef  = 8
+	btfKindVolatile = 9
+	btfKindConst    = 10
+	btfKindRestrict = 11
+	btfKindFunc     = 12
+	btfKindFuncProt = 13
+	btfKindVar      = 14
+	btfKindDatasec  = 15
+	btfKindFloat    = 16
+	btfKindDeclTag  = 17
+	btfKindTypeTag  = 18
+	btfKindEnum64   = 19
+)
+
+// btfType represents the part of a BTF type which is needed
+// to look up the struct members.
+type btfType struct {
+	name    string
+	kind    uint8
+	typ     uint32 // referenced type for modifiers and typedefs
+	members []btfMember
+}
+
+type btfMember struct {
+	name string
+	typ  uint32
+}
+
+// btf represents the kernel BTF types.
+type btf struct {
+	types   []btfType // index is the type id
+	structs map[string]uint32
+}
+
+// loadBTF reads and parses a raw BTF file, e.g. /sys/kernel/btf/vmlinux.
+func loadBTF(path string) (*btf, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBTF(b)
+}
+
+func parseBTF(b []byte) (*btf, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+
+	if len(b) < 24 {
+		return nil, errors.New("btf: short header")
+	}
+
+	if order.Uint16(b) != btfMagic {
+		order = binary.BigEndian
+		if order.Uint16(b) != btfMagic {
+			return nil, errors.New("btf: invalid magic")
+		}
+	}
+
+	var (
+		hdrLen  = order.Uint32(b[4:])
+		typeOff = order.Uint32(b[8:])
+		typeLen = order.Uint32(b[12:])
+		strOff  = order.Uint32(b[16:])
+		strLen  = order.Uint32(b[20:])
+	)
+
+	if uint64(hdrLen)+uint64(typeOff)+uint64(typeLen) > uint64(len(b)) ||
+		uint64(hdrLen)+uint64(strOff)+uint64(strLen) > uint64(len(b)) {
+		return nil, errors.New("btf: truncated data")
+	}
+
+	types := b[hdrLen+typeOff : hdrLen+typeOff+typeLen]
+	strs := b[hdrLen+strOff : hdrLen+strOff+strLen]
+
+	name := func(off uint32) string {
+		if int(off) >= len(strs) {
+			return ""
+		}
+		s := strs[off:]
+		if i := bytes.IndexByte(s, 0); i >= 0 {
+			return string(s[:i])
+		}
+		return string(s)
+	}
+
+	t := &btf{
+		types:   []btfType{{}}, // type id 0 is void
+		structs: map[string]uint32{},
+	}
+
+	for c := 0; c+12 <= len(types); {
+		var (
+			info = order.Uint32(types[c+4:])
+			vlen = int(info & 0xffff)
+			kind = uint8((info >> 24) & 0x1f)
+			bt   = btfType{
+				name: name(order.Uint32(types[c:])),
+				kind: kind,
+				typ:  order.Uint32(types[c+8:]),
+			}
+			size int
+		)
+
+		c += 12
+
+		switch kind {
+		case btfKindInt, btfKindVar, btfKindDeclTag:
+			size = 4
+		case btfKindArray:
+			size = 12
+		case btfKindStruct, btfKindUnion:
+			size = 12 * vlen
+			if c+size > len(types) {
+				return nil, errors.New("btf: truncated struct")
+			}
+			for i := 0; i < vlen; i++ {
+				m := types[c+i*12:]
+				bt.members = append(bt.members, btfMember{
+					name: name(order.Uint32(m)),
+					typ:  order.Uint32(m[4:]),
+				})
+			}
+		case btfKindEnum, btfKindFuncProt:
+			size = 8 * vlen
+		case btfKindDatasec, btfKindEnum64:
+			size = 12 * vlen
+		case btfKindPtr, btfKindFwd, btfKindTypedef, btfKindVolatile, btfKindConst,
+			btfKindRestrict, btfKindFunc, btfKindFloat, btfKindTypeTag:
+		default:
+			return nil, fmt.Errorf("btf: unknown kind %d", kind)
+		}
+
+		c += size
+
+		if kind == btfKindStruct && bt.name != "" {
+			if _, ok := t.structs[bt.name]; !ok {
+				t.structs[bt.name] = uint32(len(t.types))
+			}
+		}
+
+		t.types = append(t.types, bt)
+	}
+
+	return t, nil
+}
+
+// resolve skips typedefs and type modifiers.
+func (t *btf) resolve(id uint32) *btfType {
+	for i := 0; i < 16 && int(id) < len(t.types); i++ {
+		bt := &t.types[id]
+		switch bt.kind {
+		case btfKindTypedef, btfKindVolatile, btfKindConst, btfKindRestrict, btfKindTypeTag:
+			id = bt.typ
+		default:
+			return bt
+		}
+	}
+
+	return nil
+}
+
+// member looks up a member by name, anonymous structs and unions are flattened
+// the same way as the C compiler does.
+func (t *btf) member(bt *btfType, name string) *btfType {
+	if bt == nil {
+		return nil
+	}
+
+	for _, m := range bt.members {
+		if m.name == name {
+			return t.resolve(m.typ)
+		}
+
+		if m.name == "" {
+			if r := t.member(t.resolve(m.typ), name); r != nil {
+				return r
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasMember checks if the struct has the member path, e.g.
+// hasMember("tcp_sock", "rcv_rtt_est", "rtt_us").
+func (t *btf) hasMember(structName string, path ...string) bool {
+	id, ok := t.structs[structName]
+	if !ok {
+		return false
+	}
+
+	bt := &t.types[id]
+	for _, name := range path {
+		if bt = t.member(bt, name); bt == nil {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,6 +3,7 @@ package ebpf
 import (
 	"bytes"
 	"errors"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -17,6 +18,7 @@ type TracepointTemplate struct {
 	TCPState   string
 	Suffix     int
 	Sample     int
+	Adaptive   bool
 	TCPInfo    bool
 	ICSK       bool
 }
@@ -33,6 +35,16 @@ func (t *TracepointTemplate) Init() {
 	}
 }
 
+// SampleRate returns the sample rate expression, the adaptive
+// rate is updated by user space through the sample_rate map.
+func (t TracepointTemplate) SampleRate() string {
+	if t.Adaptive {
+		return "rate"
+	}
+
+	return strconv.Itoa(t.Sample)
+}
+
 // CGen represents code generator
 type CGen struct {
 	conf *config.Config
@@ -55,6 +67,21 @@ func GetBPFCode(conf *config.Config) (string, error) {
 	return includes + bpfCode, nil
 }
 
+// GetTracepointBPFCode returns BPF program of a tracepoint.
+func GetTracepointBPFCode(conf *config.Config, index int) (string, error) {
+	if index < 0 || index >= len(conf.Tracepoints) {
+		return "", errors.New("tracepoint not exist")
+	}
+
+	cg := CGen{conf: conf}
+	code, err := cg.getTracepointBPFCode(index, conf.Tracepoints[index])
+	if err != nil {
+		return "", err
+	}
+
+	return includes + code, nil
+}
+
 func (c *CGen) getTracepointBPFCode(index int, tp config.Tracepoint) (string, error) {
 	var (
 		cfgFields []config.Field
@@ -86,6 +113,12 @@ func (c *CGen) getTracepointBPFCode(index int, tp config.Tracepoint) (string, er
 		Sample:     tp.Sample,
 	}
 
+	for _, ref := range c.conf.GetTPEgressRefs(tp) {
+		if c.conf.Egress[ref.Name].Overflow == OverflowSample {
+			tt.Adaptive = true
+		}
+	}
+
 	tt.Init()
 
 	buf := new(bytes.Buffer)
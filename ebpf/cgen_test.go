@@ -55,3 +55,32 @@ func TestGetBPFCode(t *testing.T) {
 	assert.Contains(t, source, "unsigned __int128 skc_v6_rcv_saddr2;")
 	assert.Contains(t, source, "unsigned __int128 skc_v6_daddr3;")
 }
+
+func TestGetBPFCodeAdaptive(t *testing.T) {
+	conf := &config.Config{
+		Tracepoints: []config.Tracepoint{{
+			Name:     "sock:inet_sock_set_state",
+			Fields:   "fields1",
+			TCPState: "TCP_CLOSE",
+			INet:     []int{4},
+			Egress:   "foo",
+		}},
+		Fields: map[string][]config.Field{
+			"fields1": {{Name: "SRTT"}},
+		},
+		Egress: map[string]config.EgressConfig{
+			"foo": {Overflow: OverflowSample},
+		},
+	}
+
+	source, err := GetTracepointBPFCode(conf, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, source, "BPF_ARRAY(sample_rate0, u64, 1);")
+	assert.Contains(t, source, "BPF_HASH(ipv4_sample, struct sock *, u64, 100000);")
+	assert.Contains(t, source, "if (*count < rate)")
+
+	conf.Egress["foo"] = config.EgressConfig{Overflow: OverflowDropNewest}
+	source, err = GetTracepointBPFCode(conf, 0)
+	assert.NoError(t, err)
+	assert.NotContains(t, source, "sample_rate0")
+}
@@ -142,10 +142,11 @@ var (
 			Desc:   "RFC4898 tcpEStatsAppHCThruOctetsReceived",
 		},
 		"BytesSent": {
-			DS:     "tcpi",
-			CField: "bytes_sent",
-			CType:  u64,
-			Desc:   "RFC4898 tcpEStatsPerfHCDataOctetsOut",
+			DS:        "tcpi",
+			CField:    "bytes_sent",
+			CType:     u64,
+			Desc:      "RFC4898 tcpEStatsPerfHCDataOctetsOut",
+			MinKernel: "4.19",
 		},
 		"BytesAcked": {
 			DS:     "tcpi",
@@ -206,10 +207,11 @@ var (
 			Desc:   "Total number of segments sent",
 		},
 		"DsackDups": {
-			DS:     "tcpi",
-			CField: "dsack_dups",
-			CType:  u32,
-			Desc:   "Total number of DSACK blocks received",
+			DS:        "tcpi",
+			CField:    "dsack_dups",
+			CType:     u32,
+			Desc:      "Total number of DSACK blocks received",
+			MinKernel: "4.19",
 		},
 		"RateDelivered": {
 			DS:     "tcpi",
@@ -290,10 +292,11 @@ var (
 			Desc:   "Total data packets delivered incl. rexmits",
 		},
 		"DeliveredCe": {
-			CType:  u32,
-			CField: "delivered_ce",
-			DS:     "tcpi",
-			Desc:   "Like the above but only ECE marked packets",
+			CType:     u32,
+			CField:    "delivered_ce",
+			DS:        "tcpi",
+			Desc:      "Like the above but only ECE marked packets",
+			MinKernel: "4.18",
 		},
 		"Lost": {
 			CType:  u32,
@@ -403,6 +406,13 @@ func ValidateField(f string) (string, error) {
 	return f, fmt.Errorf("invalid field: %s", f)
 }
 
+// IsNumeric returns true if the field is decoded as a number,
+// the task name and the ip addresses are decoded as strings.
+func IsNumeric(f string) bool {
+	prop, ok := fieldsModel4[f]
+	return ok && prop.CType != char && prop.DType != IP
+}
+
 // ValidateTCPStatus validates a TCP status
 func ValidateTCPStatus(status string) (string, error) {
 	statusUpper := strings.ToUpper(status)
@@ -29,3 +29,10 @@ func TestValidateTracepoint(t *testing.T) {
 	assert.NoError(t, ValidateTracepoint("tcp:tcp_probe"))
 	assert.Error(t, ValidateTracepoint("tcp:unknown"))
 }
+
+func TestIsNumeric(t *testing.T) {
+	assert.True(t, IsNumeric("SRTT"))
+	assert.False(t, IsNumeric("Task"))
+	assert.False(t, IsNumeric("SAddr"))
+	assert.False(t, IsNumeric("foo"))
+}
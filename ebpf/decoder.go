@@ -8,16 +8,28 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/expr"
 )
 
 type decoder struct {
-	v16    uint16
-	v32    uint32
-	v64    uint64
-	c      uint16
-	v4     bool
-	ip     net.IP
-	logger *zap.Logger
+	v16     uint16
+	v32     uint32
+	v64     uint64
+	c       uint16
+	v4      bool
+	ip      net.IP
+	fb      []byte
+	derived []derived
+	values  map[string]interface{}
+	logger  *zap.Logger
+}
+
+type derived struct {
+	name   string
+	expr   *expr.Expr
+	failed bool // the failure has been logged
 }
 
 func newDecoder(logger *zap.Logger, v4 bool) *decoder {
@@ -33,6 +45,25 @@ func newDecoder(logger *zap.Logger, v4 bool) *decoder {
 	}
 }
 
+// setDerived parses the derived fields expressions, the decoder keeps
+// the captured values only if there is any derived field.
+func (d *decoder) setDerived(fields []config.DerivedField) error {
+	for _, f := range fields {
+		e, err := expr.Parse(f.Expr)
+		if err != nil {
+			return err
+		}
+
+		d.derived = append(d.derived, derived{name: f.Name, expr: e})
+	}
+
+	if len(d.derived) > 0 {
+		d.values = make(map[string]interface{})
+	}
+
+	return nil
+}
+
 func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 	var prop FieldAttrs
 
@@ -58,6 +89,10 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 			buf.Write([]byte(strconv.FormatUint(uint64(data[d.c]), 10)))
 			buf.WriteRune(',')
 
+			if d.values != nil {
+				d.values[field] = float64(data[d.c])
+			}
+
 			d.c++
 
 		case u16:
@@ -70,6 +105,10 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 			buf.Write([]byte(strconv.FormatUint(uint64(d.v16), 10)))
 			buf.WriteRune(',')
 
+			if d.values != nil {
+				d.values[field] = float64(d.v16)
+			}
+
 			d.c += 2
 
 		case u32:
@@ -82,9 +121,17 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 				buf.WriteRune('"')
 				buf.Write([]byte(d.ip.String()))
 				buf.WriteRune('"')
+
+				if d.values != nil {
+					d.values[field] = d.ip.String()
+				}
 			} else {
 				d.v32 = bytesToUint32(prop.BigEndian, data, d.c)
 				buf.Write([]byte(strconv.FormatUint(uint64(d.v32), 10)))
+
+				if d.values != nil {
+					d.values[field] = float64(d.v32)
+				}
 			}
 
 			buf.WriteRune(',')
@@ -101,6 +148,10 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 			buf.Write([]byte(strconv.FormatUint(d.v64, 10)))
 			buf.WriteRune(',')
 
+			if d.values != nil {
+				d.values[field] = float64(d.v64)
+			}
+
 			d.c += 8
 
 		case u128:
@@ -114,6 +165,10 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 			buf.WriteRune('"')
 			buf.WriteRune(',')
 
+			if d.values != nil {
+				d.values[field] = d.ip.String()
+			}
+
 			d.c += 16
 
 		case char:
@@ -124,6 +179,10 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 			buf.WriteRune('"')
 			buf.WriteRune(',')
 
+			if d.values != nil {
+				d.values[field] = string(trim(data[d.c : d.c+16]))
+			}
+
 			d.c += 16
 
 		default:
@@ -131,6 +190,29 @@ func (d *decoder) decode(data []byte, fields []string, buf *bytes.Buffer) {
 		}
 	}
 
+	for i := range d.derived {
+		f := &d.derived[i]
+
+		// the failure is logged once per expression as it
+		// likely fails for the next events too.
+		v, err := f.expr.Float(d.values)
+		if err != nil && !f.failed && d.logger != nil {
+			d.logger.Warn("decoder", zap.String("field", f.name), zap.Error(err),
+				zap.String("msg", "the next failures of this field are not logged"))
+			f.failed = true
+		}
+
+		d.values[f.name] = v
+
+		buf.WriteRune('"')
+		buf.Write([]byte(f.name))
+		buf.WriteRune('"')
+		buf.WriteRune(':')
+		d.fb = strconv.AppendFloat(d.fb[:0], v, 'f', -1, 64)
+		buf.Write(d.fb)
+		buf.WriteRune(',')
+	}
+
 	buf.WriteRune('"')
 	buf.Write([]byte("Timestamp"))
 	buf.WriteRune('"')
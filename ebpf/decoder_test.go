@@ -5,6 +5,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/mehrdadrad/tcpdog/config"
 )
 
 func TestDecoderV4(t *testing.T) {
@@ -31,6 +35,38 @@ func TestDecoderV6(t *testing.T) {
 	assert.Contains(t, buf.String(), expected)
 }
 
+func TestDecoderDerived(t *testing.T) {
+	data := []byte{0xf3, 0xd2, 0x12, 0x0, 0x63, 0x75, 0x72, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xc7, 0xbd, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xb4, 0x5, 0x0, 0x0, 0x25, 0x39, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xe, 0x0, 0x0, 0x0, 0xb, 0x0, 0x0, 0x0, 0xa, 0x0, 0x2, 0xf, 0xac, 0xd9, 0x5, 0xc4, 0x0, 0x50, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}
+	fields := []string{"PID", "Task", "NumSAcks", "SRTT", "RTT", "TotalRetrans", "AdvMSS", "BytesReceived", "SegsIn", "SegsOut", "SAddr", "DAddr", "DPort"}
+	expected := `"DPort":80,"SegsRatio":1.2727272727272727,"Loss":0,`
+
+	buf := new(bytes.Buffer)
+	d := newDecoder(nil, true)
+	err := d.setDerived([]config.DerivedField{
+		{Name: "SegsRatio", Expr: "SegsIn / SegsOut"},
+		{Name: "Loss", Expr: "TotalRetrans / SegsOut"},
+	})
+	assert.NoError(t, err)
+
+	d.decode(data, fields, buf)
+	assert.Contains(t, buf.String(), expected)
+	assert.Equal(t, "curl", d.values["Task"])
+
+	err = d.setDerived([]config.DerivedField{{Name: "Foo", Expr: "SegsIn /"}})
+	assert.Error(t, err)
+
+	// the failure is logged once
+	core, logs := observer.New(zap.WarnLevel)
+	d = newDecoder(zap.New(core), true)
+	err = d.setDerived([]config.DerivedField{{Name: "Foo", Expr: "Task * 2"}})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		d.decode(data, fields, buf)
+	}
+	assert.Equal(t, 1, logs.Len())
+}
+
 func BenchmarkDecoderV4(b *testing.B) {
 	data := []byte{0xf3, 0xd2, 0x12, 0x0, 0x63, 0x75, 0x72, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xc7, 0xbd, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xb4, 0x5, 0x0, 0x0, 0x25, 0x39, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xe, 0x0, 0x0, 0x0, 0xb, 0x0, 0x0, 0x0, 0xa, 0x0, 0x2, 0xf, 0xac, 0xd9, 0x5, 0xc4, 0x0, 0x50, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}
 	buf := new(bytes.Buffer)
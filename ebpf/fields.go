@@ -38,6 +38,7 @@ type FieldAttrs struct {
 	Func      string
 	Filter    string
 	Desc      string
+	MinKernel string
 	DSNP      bool
 	BigEndian bool
 }
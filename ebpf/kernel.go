@@ -0,0 +1,121 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	btfPath          = "/sys/kernel/btf/vmlinux"
+	osReleasePath    = "/proc/sys/kernel/osrelease"
+	defaultMinKernel = "4.16"
+)
+
+// Kernel represents the running kernel features.
+type Kernel struct {
+	Release string
+	btf     *btf
+}
+
+// ProbeKernel probes the running kernel by its BTF, if the BTF
+// is not available, it falls back to the kernel release.
+func ProbeKernel() *Kernel {
+	k := &Kernel{}
+
+	if b, err := ioutil.ReadFile(osReleasePath); err == nil {
+		k.Release = strings.TrimSpace(string(b))
+	}
+
+	if t, err := loadBTF(btfPath); err == nil {
+		k.btf = t
+	}
+
+	return k
+}
+
+// HasBTF returns true if the kernel exposes its BTF.
+func (k *Kernel) HasBTF() bool {
+	return k.btf != nil
+}
+
+// CheckField returns an error which names the field and the minimum
+// kernel version if the field is not available at the running kernel.
+func (k *Kernel) CheckField(name string) error {
+	attrs, ok := fieldsModel4[name]
+	if !ok {
+		return fmt.Errorf("invalid field: %s", name)
+	}
+
+	structName, path := fieldPath(attrs)
+	if structName == "" {
+		return nil
+	}
+
+	minKernel := getValue(attrs.MinKernel, defaultMinKernel)
+
+	if k.btf != nil {
+		if k.btf.hasMember(structName, path...) {
+			return nil
+		}
+	} else if k.Release == "" || kernelVersionCmp(k.Release, minKernel) >= 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("field %s (%s.%s) is not available at kernel %s",
+		name, structName, strings.Join(path, "."), k.Release)
+
+	if kernelVersionCmp(k.Release, minKernel) < 0 {
+		err = fmt.Errorf("%v, it requires kernel %s or later", err, minKernel)
+	}
+
+	return err
+}
+
+// fieldPath returns the kernel struct and the member path of a field.
+func fieldPath(attrs FieldAttrs) (string, []string) {
+	ds := strings.Split(attrs.DS, "->")
+
+	switch ds[0] {
+	case "tcpi":
+		return "tcp_sock", append(ds[1:], attrs.CField)
+	case "icsk":
+		return "inet_connection_sock", append(ds[1:], attrs.CField)
+	case "sk":
+		return "sock_common", []string{attrs.CField}
+	}
+
+	// tracepoint arguments and bpf helpers
+	return "", nil
+}
+
+// kernelVersionCmp compares the major and minor of two kernel versions.
+func kernelVersionCmp(a, b string) int {
+	va, vb := kernelVersion(a), kernelVersion(b)
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func kernelVersion(release string) [2]int {
+	var v [2]int
+
+	parts := strings.SplitN(release, ".", 3)
+	for i := 0; i < len(parts) && i < 2; i++ {
+		n := strings.IndexFunc(parts[i], func(r rune) bool { return r < '0' || r > '9' })
+		if n >= 0 {
+			parts[i] = parts[i][:n]
+		}
+		v[i], _ = strconv.Atoi(parts[i])
+	}
+
+	return v
+}
@@ -0,0 +1,114 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBTF builds a little BTF blob:
+// struct tcp_sock { u32 srtt_us; struct { u64 bytes_sent; }; struct tcp_options_received rx_opt; }
+func fakeBTF() []byte {
+	strs := []byte("\x00int\x00tcp_sock\x00srtt_us\x00rx_opt\x00tcp_options_received\x00num_sacks\x00bytes_sent\x00u64\x00")
+	off := func(s string) uint32 { return uint32(bytes.Index(strs, []byte("\x00"+s+"\x00")) + 1) }
+
+	types := new(bytes.Buffer)
+	w := func(v ...uint32) {
+		for _, u := range v {
+			binary.Write(types, binary.LittleEndian, u)
+		}
+	}
+	info := func(kind, vlen uint32) uint32 { return kind<<24 | vlen }
+
+	// [1] int
+	w(off("int"), info(btfKindInt, 0), 4, 32)
+	// [2] struct tcp_sock
+	w(off("tcp_sock"), info(btfKindStruct, 3), 32)
+	w(off("srtt_us"), 1, 0)
+	w(0, 5, 64)
+	w(off("rx_opt"), 3, 128)
+	// [3] struct tcp_options_received
+	w(off("tcp_options_received"), info(btfKindStruct, 1), 4)
+	w(off("num_sacks"), 1, 0)
+	// [4] typedef u64
+	w(off("u64"), info(btfKindTypedef, 0), 1)
+	// [5] anonymous struct
+	w(0, info(btfKindStruct, 1), 8)
+	w(off("bytes_sent"), 4, 0)
+
+	hdr := new(bytes.Buffer)
+	binary.Write(hdr, binary.LittleEndian, uint16(btfMagic))
+	hdr.Write([]byte{1, 0})
+	for _, v := range []uint32{24, 0, uint32(types.Len()), uint32(types.Len()), uint32(len(strs))} {
+		binary.Write(hdr, binary.LittleEndian, v)
+	}
+
+	hdr.Write(types.Bytes())
+	hdr.Write(strs)
+
+	return hdr.Bytes()
+}
+
+func TestParseBTF(t *testing.T) {
+	b, err := parseBTF(fakeBTF())
+	assert.NoError(t, err)
+
+	assert.True(t, b.hasMember("tcp_sock", "srtt_us"))
+	assert.True(t, b.hasMember("tcp_sock", "bytes_sent"))
+	assert.True(t, b.hasMember("tcp_sock", "rx_opt", "num_sacks"))
+	assert.False(t, b.hasMember("tcp_sock", "dsack_dups"))
+	assert.False(t, b.hasMember("tcp_sock", "rx_opt", "user_mss"))
+	assert.False(t, b.hasMember("sock_common", "skc_daddr"))
+
+	_, err = parseBTF([]byte("garbage"))
+	assert.Error(t, err)
+
+	_, err = parseBTF(make([]byte, 32))
+	assert.Error(t, err)
+}
+
+func TestCheckField(t *testing.T) {
+	b, err := parseBTF(fakeBTF())
+	assert.NoError(t, err)
+
+	k := &Kernel{Release: "4.18.0", btf: b}
+	assert.NoError(t, k.CheckField("SRTT"))
+	assert.NoError(t, k.CheckField("BytesSent"))
+	assert.NoError(t, k.CheckField("NumSAcks"))
+	assert.NoError(t, k.CheckField("Task"))
+	assert.NoError(t, k.CheckField("NewState"))
+	assert.Error(t, k.CheckField("Foo"))
+
+	err = k.CheckField("DsackDups")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tcp_sock.dsack_dups")
+	assert.Contains(t, err.Error(), "4.19")
+
+	// without BTF
+	k = &Kernel{Release: "4.18.0-240.el8.x86_64"}
+	assert.NoError(t, k.CheckField("SRTT"))
+	assert.NoError(t, k.CheckField("DeliveredCe"))
+	assert.Error(t, k.CheckField("BytesSent"))
+}
+
+func TestKernelVersionCmp(t *testing.T) {
+	assert.Equal(t, 0, kernelVersionCmp("4.19.0-16-amd64", "4.19"))
+	assert.Equal(t, 1, kernelVersionCmp("5.4.0", "4.19"))
+	assert.Equal(t, -1, kernelVersionCmp("4.9.337", "4.16"))
+	assert.Equal(t, 1, kernelVersionCmp("4.20+", "4.19"))
+}
+
+func TestFieldPath(t *testing.T) {
+	s, p := fieldPath(fieldsModel4["RcvRTT"])
+	assert.Equal(t, "tcp_sock", s)
+	assert.Equal(t, []string{"rcv_rtt_est", "rtt_us"}, p)
+
+	s, p = fieldPath(fieldsModel4["DAddr"])
+	assert.Equal(t, "sock_common", s)
+	assert.Equal(t, []string{"skc_daddr"}, p)
+
+	s, _ = fieldPath(fieldsModel4["PID"])
+	assert.Equal(t, "", s)
+}
@@ -0,0 +1,191 @@
+package ebpf
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bpf "github.com/iovisor/gobpf/bcc"
+	"go.uber.org/zap"
+)
+
+// Overflow policies once the egress channel is full.
+const (
+	// OverflowDropNewest drops the new event.
+	OverflowDropNewest = "drop-newest"
+	// OverflowDropOldest evicts the oldest event from the channel (ring).
+	OverflowDropOldest = "drop-oldest"
+	// OverflowBlock waits for the egress, the events back up
+	// into the perf buffer and the kernel drops them once it's full.
+	OverflowBlock = "block"
+	// OverflowSample drops the new event and raises
+	// the in-kernel sample rate under pressure.
+	OverflowSample = "sample"
+)
+
+const (
+	// blockTimeout is the time that blocked workers wait
+	// for the egress at shutdown before they drop the events.
+	blockTimeout = time.Second
+
+	sampleInterval = time.Second
+	maxSampleRate  = 1024
+	highWatermark  = 0.75
+	lowWatermark   = 0.25
+)
+
+var overflowPolicies = []string{
+	OverflowDropNewest,
+	OverflowDropOldest,
+	OverflowBlock,
+	OverflowSample,
+}
+
+// ValidateOverflow validates an overflow policy.
+func ValidateOverflow(policy string) error {
+	for _, p := range overflowPolicies {
+		if p == policy {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid overflow policy: %s", policy)
+}
+
+// counters represents the dropped events per overflow policy.
+type counters map[string]*uint64
+
+func newCounters() counters {
+	c := counters{}
+	for _, p := range overflowPolicies {
+		c[p] = new(uint64)
+	}
+
+	return c
+}
+
+func (c counters) add(policy string, n uint64) {
+	if v, ok := c[policy]; ok {
+		atomic.AddUint64(v, n)
+		return
+	}
+
+	atomic.AddUint64(c[OverflowDropNewest], n)
+}
+
+// send hands over the buffer to the egress channel based on the overflow policy.
+func (b *BPF) send(p *probe, bufpool *sync.Pool, out Output, buf *bytes.Buffer, logger *zap.Logger) {
+	switch out.Overflow {
+	case OverflowBlock:
+		select {
+		case out.Chan <- buf:
+		case <-p.quit:
+			bufpool.Put(buf)
+			b.counters.add(OverflowBlock, 1)
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case out.Chan <- buf:
+				return
+			default:
+			}
+
+			select {
+			case old := <-out.Chan:
+				bufpool.Put(old)
+				b.counters.add(OverflowDropOldest, 1)
+			default:
+			}
+		}
+
+	default:
+		select {
+		case out.Chan <- buf:
+		default:
+			bufpool.Put(buf)
+			b.counters.add(out.Overflow, 1)
+			logger.Warn("ebpf", zap.String("msg", out.Name+" egress channel maxed out"))
+		}
+	}
+}
+
+// lost counts the events which have been lost at the perf buffer.
+func (b *BPF) lost(ch chan uint64) {
+	for n := range ch {
+		atomic.AddUint64(&b.perfLost, n)
+	}
+}
+
+// adapt raises the in-kernel sample rate while the egress channel is
+// under pressure and lowers it back once the pressure is gone.
+func (b *BPF) adapt(p *probe, tp TP, logger *zap.Logger) {
+	var (
+		rate  uint64
+		key   = make([]byte, 4)
+		leaf  = make([]byte, 8)
+		order = bpf.GetHostByteOrder()
+		table = bpf.NewTable(p.m.TableId(fmt.Sprintf("sample_rate%d", p.suffix)), p.m)
+	)
+
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+
+		next := nextSampleRate(rate, pressure(tp.outputs()))
+		if next == rate {
+			continue
+		}
+
+		order.PutUint64(leaf, next)
+		if err := table.Set(key, leaf); err != nil {
+			logger.Error("ebpf", zap.Error(err))
+			continue
+		}
+
+		rate = next
+		logger.Info("ebpf", zap.String("msg", fmt.Sprintf("%s sample rate has been set to %d", tp.Name, rate)))
+	}
+}
+
+// pressure returns the highest usage of the egress
+// channels which have the sample overflow policy.
+func pressure(outputs []Output) float64 {
+	var usage float64
+
+	for _, out := range outputs {
+		if out.Overflow != OverflowSample || cap(out.Chan) < 1 {
+			continue
+		}
+
+		if u := float64(len(out.Chan)) / float64(cap(out.Chan)); u > usage {
+			usage = u
+		}
+	}
+
+	return usage
+}
+
+// nextSampleRate doubles the rate above the high watermark
+// and halves it below the low watermark.
+func nextSampleRate(rate uint64, usage float64) uint64 {
+	switch {
+	case usage >= highWatermark && rate == 0:
+		return 1
+	case usage >= highWatermark && rate < maxSampleRate:
+		return rate * 2
+	case usage <= lowWatermark:
+		return rate / 2
+	}
+
+	return rate
+}
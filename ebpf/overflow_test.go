@@ -0,0 +1,180 @@
+package ebpf
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValidateOverflow(t *testing.T) {
+	for _, p := range []string{"drop-newest", "drop-oldest", "block", "sample"} {
+		assert.NoError(t, ValidateOverflow(p))
+	}
+
+	assert.Error(t, ValidateOverflow("foo"))
+}
+
+func TestSend(t *testing.T) {
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	newOutput := func(policy string) Output {
+		return Output{Chan: make(chan *bytes.Buffer, 2), Overflow: policy}
+	}
+
+	b := &BPF{counters: newCounters()}
+	p := &probe{quit: make(chan struct{})}
+
+	// drop-newest
+	out := newOutput(OverflowDropNewest)
+	for _, s := range []string{"a", "b", "c"} {
+		b.send(p, bufPool, out, bytes.NewBufferString(s), zap.NewNop())
+	}
+	assert.Equal(t, "a", (<-out.Chan).String())
+	assert.Equal(t, "b", (<-out.Chan).String())
+
+	// drop-oldest
+	out = newOutput(OverflowDropOldest)
+	for _, s := range []string{"a", "b", "c"} {
+		b.send(p, bufPool, out, bytes.NewBufferString(s), zap.NewNop())
+	}
+	assert.Equal(t, "b", (<-out.Chan).String())
+	assert.Equal(t, "c", (<-out.Chan).String())
+
+	// block
+	out = newOutput(OverflowBlock)
+	done := make(chan struct{})
+	go func() {
+		for _, s := range []string{"a", "b", "c"} {
+			b.send(p, bufPool, out, bytes.NewBufferString(s), zap.NewNop())
+		}
+		close(done)
+	}()
+	assert.Equal(t, "a", (<-out.Chan).String())
+	<-done
+	assert.Len(t, out.Chan, 2)
+
+	// it drops once the probe quits
+	close(p.quit)
+	b.send(p, bufPool, out, bytes.NewBufferString("d"), zap.NewNop())
+
+	c := b.Counters()
+	assert.Equal(t, uint64(1), c[OverflowDropNewest])
+	assert.Equal(t, uint64(1), c[OverflowDropOldest])
+	assert.Equal(t, uint64(1), c[OverflowBlock])
+	assert.Equal(t, uint64(0), c[OverflowSample])
+	assert.Equal(t, uint64(3), b.Dropped())
+}
+
+// mockPerfMap polls like bpf.PerfMap, it can't be
+// stopped while it's blocked on the perf channel.
+type mockPerfMap struct {
+	stop chan bool
+}
+
+func newMockPerfMap(ch chan []byte) *mockPerfMap {
+	pm := &mockPerfMap{stop: make(chan bool)}
+
+	go func() {
+		for {
+			select {
+			case <-pm.stop:
+				return
+			default:
+			}
+
+			ch <- []byte("event")
+		}
+	}()
+
+	return pm
+}
+
+func (pm *mockPerfMap) Stop() { pm.stop <- true }
+
+func TestDrainBlocked(t *testing.T) {
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	b := &BPF{counters: newCounters()}
+	p := &probe{quit: make(chan struct{})}
+
+	// the egress is stalled
+	out := Output{Chan: make(chan *bytes.Buffer), Overflow: OverflowBlock}
+	ch := make(chan []byte, 2)
+
+	p.perfMaps = []perfReader{newMockPerfMap(ch)}
+	p.perfChs = []chan []byte{ch}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for range ch {
+			b.send(p, bufPool, out, bufPool.Get().(*bytes.Buffer), zap.NewNop())
+		}
+	}()
+
+	// waits until the perf channel is full
+	for len(ch) < cap(ch) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.drain()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * blockTimeout):
+		t.Fatal("drain is blocked by the stalled egress")
+	}
+
+	assert.Greater(t, b.Counters()[OverflowBlock], uint64(0))
+}
+
+func TestNextSampleRate(t *testing.T) {
+	assert.Equal(t, uint64(1), nextSampleRate(0, 0.8))
+	assert.Equal(t, uint64(4), nextSampleRate(2, 0.8))
+	assert.Equal(t, uint64(maxSampleRate), nextSampleRate(maxSampleRate, 1))
+	assert.Equal(t, uint64(4), nextSampleRate(4, 0.5))
+	assert.Equal(t, uint64(2), nextSampleRate(4, 0.1))
+	assert.Equal(t, uint64(0), nextSampleRate(1, 0))
+
+	outputs := []Output{
+		{Chan: make(chan *bytes.Buffer, 4), Overflow: OverflowSample},
+		{Chan: make(chan *bytes.Buffer, 4), Overflow: OverflowDropNewest},
+	}
+	outputs[0].Chan <- nil
+	outputs[1].Chan <- nil
+	outputs[1].Chan <- nil
+	assert.Equal(t, 0.25, pressure(outputs))
+}
+
+func TestLost(t *testing.T) {
+	b := &BPF{counters: newCounters()}
+
+	ch := make(chan uint64, 2)
+	ch <- 3
+	ch <- 2
+	close(ch)
+
+	b.lost(ch)
+
+	assert.Equal(t, uint64(5), b.PerfLost())
+	assert.Equal(t, uint64(5), b.Dropped())
+	for _, v := range b.Counters() {
+		assert.Equal(t, uint64(0), v)
+	}
+}
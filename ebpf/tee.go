@@ -0,0 +1,152 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/expr"
+)
+
+// Output represents an egress of a tracepoint with its
+// overflow policy, field projection and filter.
+type Output struct {
+	Name     string
+	Chan     chan *bytes.Buffer
+	Overflow string
+	Fields   []string // projection, all the fields if it's empty
+	Filter   *expr.Expr
+}
+
+func (o Output) plain() bool {
+	return len(o.Fields) < 1 && o.Filter == nil
+}
+
+// Route represents a routing rule of a tracepoint, the events
+// which match the expression are handed over to the outputs.
+type Route struct {
+	Match   *expr.Expr // nil matches all
+	Outputs []Output
+}
+
+// outputs returns all the outputs of the tracepoint.
+func (tp TP) outputs() []Output {
+	outputs := append([]Output{}, tp.Outputs...)
+	for _, r := range tp.Routes {
+		outputs = append(outputs, r.Outputs...)
+	}
+
+	return outputs
+}
+
+// tee hands over the event to the egresses, each egress owns its own
+// copy of the buffer so it can be put back to the pool independently.
+func (b *BPF) tee(p *probe, tp TP, buf *bytes.Buffer, logger *zap.Logger) {
+	if len(tp.Routes) < 1 && len(tp.Outputs) == 1 && tp.Outputs[0].plain() {
+		b.send(p, tp.BufPool, tp.Outputs[0], buf, logger)
+		return
+	}
+
+	var (
+		values map[string]json.RawMessage
+		failed bool
+	)
+
+	// decode decodes the event once, the outputs which need the
+	// fields are skipped if it fails but the plain ones get it.
+	decode := func() bool {
+		if values != nil || failed {
+			return !failed
+		}
+
+		if err := json.Unmarshal(buf.Bytes(), &values); err != nil {
+			logger.Error("ebpf", zap.Error(err))
+			failed = true
+		}
+
+		return !failed
+	}
+
+	outputs := tp.Outputs
+	if len(tp.Routes) > 0 {
+		outputs = nil
+		for _, r := range tp.Routes {
+			if r.Match == nil {
+				outputs = r.Outputs
+				break
+			}
+
+			if !decode() {
+				continue
+			}
+
+			if filter(r.Match, values, logger) {
+				outputs = r.Outputs
+				break
+			}
+		}
+	}
+
+	for _, out := range outputs {
+		if !out.plain() && !decode() {
+			continue
+		}
+
+		if out.Filter != nil && !filter(out.Filter, values, logger) {
+			continue
+		}
+
+		nb := tp.BufPool.Get().(*bytes.Buffer)
+		nb.Reset()
+
+		if len(out.Fields) > 0 {
+			project(values, out.Fields, nb)
+		} else {
+			nb.Write(buf.Bytes())
+		}
+
+		b.send(p, tp.BufPool, out, nb, logger)
+	}
+
+	tp.BufPool.Put(buf)
+}
+
+// filter evaluates the filter expression over the event's fields.
+func filter(e *expr.Expr, values map[string]json.RawMessage, logger *zap.Logger) bool {
+	fields := map[string]interface{}{}
+	for _, name := range e.Vars() {
+		var v interface{}
+		json.Unmarshal(values[name], &v)
+		fields[name] = v
+	}
+
+	ok, err := e.Bool(fields)
+	if err != nil {
+		logger.Warn("ebpf", zap.String("filter", e.String()), zap.Error(err))
+		return false
+	}
+
+	return ok
+}
+
+// project writes the requested fields in order and the timestamp.
+func project(values map[string]json.RawMessage, fields []string, buf *bytes.Buffer) {
+	buf.WriteRune('{')
+	for _, name := range fields {
+		v, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		buf.WriteRune('"')
+		buf.WriteString(name)
+		buf.WriteString(`":`)
+		buf.Write(v)
+		buf.WriteRune(',')
+	}
+
+	buf.WriteString(`"Timestamp":`)
+	buf.Write(values["Timestamp"])
+	buf.WriteRune('}')
+}
@@ -0,0 +1,122 @@
+package ebpf
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/expr"
+)
+
+func TestTee(t *testing.T) {
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	filter, err := expr.Parse(`SRTT > 10 && DAddr != "127.0.0.1"`)
+	assert.NoError(t, err)
+
+	tp := TP{
+		BufPool: bufPool,
+		Outputs: []Output{
+			{Name: "all", Chan: make(chan *bytes.Buffer, 2)},
+			{Name: "projected", Chan: make(chan *bytes.Buffer, 2), Fields: []string{"SRTT", "Loss"}},
+			{Name: "filtered", Chan: make(chan *bytes.Buffer, 2), Filter: filter},
+		},
+	}
+
+	b := &BPF{counters: newCounters()}
+	p := &probe{quit: make(chan struct{})}
+
+	events := []string{
+		`{"SRTT":12,"DAddr":"10.0.0.1","Loss":0.5,"Timestamp":1609564925}`,
+		`{"SRTT":5,"DAddr":"10.0.0.2","Loss":0,"Timestamp":1609564926}`,
+	}
+
+	for _, e := range events {
+		b.tee(p, tp, bytes.NewBufferString(e), zap.NewNop())
+	}
+
+	assert.Len(t, tp.Outputs[0].Chan, 2)
+	assert.Equal(t, events[0], (<-tp.Outputs[0].Chan).String())
+	assert.Equal(t, events[1], (<-tp.Outputs[0].Chan).String())
+
+	assert.Len(t, tp.Outputs[1].Chan, 2)
+	assert.Equal(t, `{"SRTT":12,"Loss":0.5,"Timestamp":1609564925}`, (<-tp.Outputs[1].Chan).String())
+	assert.Equal(t, `{"SRTT":5,"Loss":0,"Timestamp":1609564926}`, (<-tp.Outputs[1].Chan).String())
+
+	assert.Len(t, tp.Outputs[2].Chan, 1)
+	assert.Equal(t, events[0], (<-tp.Outputs[2].Chan).String())
+}
+
+func TestTeeRoutes(t *testing.T) {
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	match, err := expr.Parse(`TotalRetrans > 0 || DPort == 443`)
+	assert.NoError(t, err)
+
+	priority := Output{Name: "priority", Chan: make(chan *bytes.Buffer, 2)}
+	archive := Output{Name: "archive", Chan: make(chan *bytes.Buffer, 4)}
+	sampled := Output{Name: "sampled", Chan: make(chan *bytes.Buffer, 2)}
+
+	tp := TP{
+		BufPool: bufPool,
+		Routes: []Route{
+			{Match: match, Outputs: []Output{priority, archive}},
+			{Outputs: []Output{sampled, archive}},
+		},
+	}
+
+	b := &BPF{counters: newCounters()}
+	p := &probe{quit: make(chan struct{})}
+
+	events := []string{
+		`{"TotalRetrans":1,"DPort":80,"Timestamp":1609564925}`,
+		`{"TotalRetrans":0,"DPort":443,"Timestamp":1609564926}`,
+		`{"TotalRetrans":0,"DPort":80,"Timestamp":1609564927}`,
+	}
+
+	for _, e := range events {
+		b.tee(p, tp, bytes.NewBufferString(e), zap.NewNop())
+	}
+
+	assert.Len(t, priority.Chan, 2)
+	assert.Len(t, sampled.Chan, 1)
+	assert.Len(t, archive.Chan, 3)
+	assert.Equal(t, events[2], (<-sampled.Chan).String())
+	assert.Len(t, tp.outputs(), 4)
+}
+
+func TestTeeInvalid(t *testing.T) {
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	tp := TP{
+		BufPool: bufPool,
+		Outputs: []Output{
+			{Name: "projected", Chan: make(chan *bytes.Buffer, 1), Fields: []string{"SRTT"}},
+			{Name: "all", Chan: make(chan *bytes.Buffer, 1)},
+		},
+	}
+
+	b := &BPF{counters: newCounters()}
+	p := &probe{quit: make(chan struct{})}
+
+	// the plain outputs get the event which can't be decoded
+	b.tee(p, tp, bytes.NewBufferString(`{"SRTT":`), zap.NewNop())
+
+	assert.Len(t, tp.Outputs[0].Chan, 0)
+	assert.Len(t, tp.Outputs[1].Chan, 1)
+}
@@ -38,8 +38,12 @@ func initializer(ipv int, index int, f FieldAttrs) string {
 }
 
 const source = `
+	{{if .Adaptive}}
+	BPF_ARRAY(sample_rate{{.Suffix}}, u64, 1);
+	{{- end}}
+
 	{{if .Fields4}}
-	{{if ne .Sample 0}}
+	{{if or (ne .Sample 0) .Adaptive}}
 	BPF_HASH(ipv4_sample, struct sock *, u64, 100000);
 	{{- end}}
 
@@ -56,7 +60,7 @@ const source = `
 	{{- end}}
 
 	{{if .Fields6}}
-	{{if ne .Sample 0}}
+	{{if or (ne .Sample 0) .Adaptive}}
 	BPF_HASH(ipv6_sample, struct sock *, u64, 100000);
 	{{- end}}
 
@@ -88,6 +92,15 @@ const source = `
 
 		struct sock *sk = (struct sock *)args->skaddr;
 
+		{{if .Adaptive}}
+		u32 rate_key = 0;
+		u64 rate = {{.Sample}};
+		u64 *adaptive_rate = sample_rate{{.Suffix}}.lookup(&rate_key);
+		if (adaptive_rate && *adaptive_rate > rate) {
+			rate = *adaptive_rate;
+		}
+		{{end}}
+
 		{{if .TCPInfo}}
 		struct tcp_sock *tcpi = tcp_sk(sk);
 		{{end}}
@@ -124,7 +137,8 @@ const source = `
 			{{- end}}
 			{{- end}}
 
-			{{if ne .Sample 0}}
+			{{if or (ne .Sample 0) .Adaptive}}
+			{{if .Adaptive}}if (rate > 0) { {{end}}
 			u64 *count;
 			u64 zero = 0;
 			count = ipv4_sample.lookup_or_try_init(&sk, &zero);
@@ -134,13 +148,13 @@ const source = `
 				return 0;
 			}
 
-			if (*count < {{.Sample}}) {
+			if (*count < {{.SampleRate}}) {
 				ipv4_sample.increment(sk);
 				return 0;
 			}
 			
 			ipv4_sample.delete(&sk);	
-			
+			{{if .Adaptive}} } {{end}}
 			{{- end}}
 
 			ipv4_events{{.Suffix}}.perf_submit(args, &data4, sizeof(data4));
@@ -181,7 +195,8 @@ const source = `
 			{{- end}}
 			{{- end}}
 
-			{{if ne .Sample 0}}
+			{{if or (ne .Sample 0) .Adaptive}}
+			{{if .Adaptive}}if (rate > 0) { {{end}}
 			u64 *count;
 			u64 zero = 0;
 			count = ipv6_sample.lookup_or_try_init(&sk, &zero);
@@ -190,11 +205,12 @@ const source = `
 				ipv6_sample.increment(sk);
 				return 0;
 			}
-			if (*count < {{.Sample}}) {
+			if (*count < {{.SampleRate}}) {
 				ipv6_sample.increment(sk);
 				return 0;
 			}
 			ipv6_sample.delete(&sk);
+			{{if .Adaptive}} } {{end}}
 			{{- end}}
 
 			ipv6_events{{.Suffix}}.perf_submit(args, &data6, sizeof(data6));
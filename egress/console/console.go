@@ -10,12 +10,22 @@ import (
 )
 
 // New encodes the tcp fields on the console.
-func New(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
+func New(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+
 		for {
-			v := <-ch
-			fmt.Println(string(v.Bytes()[1 : v.Len()-1]))
-			bufpool.Put(v)
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return
+				}
+				fmt.Println(string(v.Bytes()[1 : v.Len()-1]))
+				bufpool.Put(v)
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
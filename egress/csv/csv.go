@@ -67,14 +67,14 @@ func (c *csv) cleanup() {
 }
 
 // Start encodes and writes tcp fields to a specific file in csv format
-func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
 	var (
 		c   = &csv{buffer: new(bytes.Buffer)}
 		err error
 	)
 
 	cfg := config.FromContext(ctx)
-	err = c.init(cfg.Egress[tp.Egress].Config, cfg.Fields[tp.Fields])
+	err = c.init(cfg.Egress[tp.Egress].Config, cfg.GetEgressOutFields(tp))
 	if err != nil {
 		return err
 	}
@@ -82,13 +82,22 @@ func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch cha
 	c.header()
 	c.flush()
 
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		defer c.cleanup()
-		var buf *bytes.Buffer
+
+		var (
+			buf *bytes.Buffer
+			ok  bool
+		)
 
 		for {
 			select {
-			case buf = <-ch:
+			case buf, ok = <-ch:
+				if !ok {
+					return
+				}
 			case <-ctx.Done():
 				return
 			}
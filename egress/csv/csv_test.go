@@ -48,7 +48,7 @@ func TestStart(t *testing.T) {
 
 	ctx = cfg.WithContext(ctx)
 
-	go Start(ctx, tp, bufPool, ch)
+	go Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 
 	b := new(bytes.Buffer)
 	b.WriteString(`{"F1":5,"F2":6,"Timestamp":1609564925}`)
@@ -74,6 +74,51 @@ func TestStart(t *testing.T) {
 		},
 	}
 	ctx = cfg.WithContext(context.Background())
-	err = Start(ctx, tp, bufPool, ch)
+	err = Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.Error(t, err)
 }
+
+func TestStartDrain(t *testing.T) {
+	tp := config.Tracepoint{
+		Egress: "myegress",
+		Fields: "myfields",
+	}
+	ch := make(chan *bytes.Buffer, 2)
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	filename := os.TempDir() + "/testfile_drain.csv"
+	defer os.Remove(filename)
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"myegress": {
+				Type: "csv",
+				Config: map[string]interface{}{
+					"filename": filename,
+				},
+			},
+		},
+		Fields: map[string][]config.Field{
+			"myfields": {
+				{Name: "F1"},
+			},
+		},
+	}
+
+	ch <- bytes.NewBufferString(`{"F1":5,"Timestamp":1609564925}`)
+	ch <- bytes.NewBufferString(`{"F1":7,"Timestamp":1609564926}`)
+	close(ch)
+
+	wg := new(sync.WaitGroup)
+	err := Start(cfg.WithContext(context.Background()), tp, bufPool, ch, wg)
+	assert.NoError(t, err)
+	wg.Wait()
+
+	fb, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "F1,timestamp\n5,1609564925\n7,1609564926\n", string(fb))
+}
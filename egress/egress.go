@@ -3,18 +3,28 @@ package egress
 import (
 	"bytes"
 	"context"
+	"strings"
 	"sync"
 
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/egress/console"
 	"github.com/mehrdadrad/tcpdog/egress/csv"
 	"github.com/mehrdadrad/tcpdog/egress/grpc"
+	"github.com/mehrdadrad/tcpdog/egress/journald"
 	"github.com/mehrdadrad/tcpdog/egress/jsonl"
 	"github.com/mehrdadrad/tcpdog/egress/kafka"
+	"github.com/mehrdadrad/tcpdog/egress/mqtt"
+	"github.com/mehrdadrad/tcpdog/egress/nats"
+	"github.com/mehrdadrad/tcpdog/egress/otlp"
+	"github.com/mehrdadrad/tcpdog/egress/socket"
+	"github.com/mehrdadrad/tcpdog/egress/statsd"
+	"github.com/mehrdadrad/tcpdog/egress/syslog"
 )
 
 // Start starts an output based on the output type at configuration.
-func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
+// The egress drains the channel once it's closed, flushes and then
+// calls wg.Done, the context cancellation stops it immediately.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
 	var err error
 
 	cfg := config.FromContext(ctx)
@@ -22,18 +32,59 @@ func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch cha
 
 	switch egress.Type {
 	case "kafka":
-		err = kafka.Start(ctx, tp, bufpool, ch)
+		err = kafka.Start(ctx, tp, bufpool, ch, wg)
+	case "nats":
+		err = nats.Start(ctx, tp, bufpool, ch, wg)
+	case "mqtt":
+		err = mqtt.Start(ctx, tp, bufpool, ch, wg)
+	case "otlp":
+		err = otlp.Start(ctx, tp, bufpool, ch, wg)
+	case "statsd":
+		err = statsd.Start(ctx, tp, bufpool, ch, wg)
 	case "grpc-pb":
-		err = grpc.Start(ctx, tp, bufpool, ch)
+		err = grpc.Start(ctx, tp, bufpool, ch, wg)
 	case "grpc-spb":
-		err = grpc.StartStructPB(ctx, tp, bufpool, ch)
+		err = grpc.StartStructPB(ctx, tp, bufpool, ch, wg)
 	case "csv":
-		err = csv.Start(ctx, tp, bufpool, ch)
+		err = csv.Start(ctx, tp, bufpool, ch, wg)
 	case "jsonl":
-		err = jsonl.Start(ctx, tp, bufpool, ch)
+		err = jsonl.Start(ctx, tp, bufpool, ch, wg)
+	case "syslog":
+		err = syslog.Start(ctx, tp, bufpool, ch, wg)
+	case "journald":
+		err = journald.Start(ctx, tp, bufpool, ch, wg)
+	case "socket":
+		err = socket.Start(ctx, tp, bufpool, ch, wg)
 	default:
-		err = console.New(ctx, tp, bufpool, ch)
+		err = console.New(ctx, tp, bufpool, ch, wg)
 	}
 
 	return err
 }
+
+// Exclusive returns true if the egress holds a resource which can't be
+// shared with its replacement, e.g. a listening socket, a spool directory,
+// a file or an mqtt session.
+func Exclusive(egress config.EgressConfig) bool {
+	switch egress.Type {
+	case "socket", "csv", "jsonl", "mqtt":
+		return true
+	case "kafka", "grpc-pb", "grpc-spb":
+		for key := range egress.Config {
+			if strings.EqualFold(key, "spool") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Stats returns the egresses' delivery counters.
+func Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"kafka": kafka.Stats(),
+		"nats":  nats.Stats(),
+		"mqtt":  mqtt.Stats(),
+	}
+}
@@ -0,0 +1,61 @@
+// Package egresstest provides the common setup of the egress tests.
+package egresstest
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// StartFunc represents the start function of an egress.
+type StartFunc func(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error
+
+// Start starts the egress for the sock:inet_sock_set_state tracepoint
+// with the requested fields, the scheme is the mock logger scheme.
+func Start(t *testing.T, start StartFunc, egress, scheme string, conf map[string]interface{}, fields ...config.Field) (chan *bytes.Buffer, *sync.WaitGroup) {
+	tp := config.Tracepoint{
+		Name:   "sock:inet_sock_set_state",
+		Egress: "myegress",
+		Fields: "myfields",
+	}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"myegress": {Type: egress, Config: conf},
+		},
+		Fields: map[string][]config.Field{
+			"myfields": fields,
+		},
+	}
+
+	cfg.SetMockLogger(scheme)
+	ctx := cfg.WithContext(context.Background())
+
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	ch := make(chan *bytes.Buffer, 10)
+	wg := new(sync.WaitGroup)
+
+	assert.NoError(t, start(ctx, tp, bufPool, ch, wg))
+
+	return ch, wg
+}
+
+// Send sends the events, then it closes the channel
+// and waits for the egress to drain them.
+func Send(ch chan *bytes.Buffer, wg *sync.WaitGroup, events ...string) {
+	for _, event := range events {
+		ch <- bytes.NewBufferString(event)
+	}
+	close(ch)
+	wg.Wait()
+}
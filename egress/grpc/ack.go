@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+// ackTimeout is the time to wait for the remaining
+// acknowledgements once the stream is closed.
+var ackTimeout = 5 * time.Second
+
+// unacked keeps the events which have been sent but not acknowledged
+// yet, it's shared across the connections so the events can be resent
+// after reconnect if the spool isn't configured.
+type unacked struct {
+	sync.Mutex
+	seq     uint64
+	batches []inflight
+	events  int
+	window  int // max unacknowledged events in memory
+}
+
+type inflight struct {
+	seq uint64
+	raw [][]byte
+}
+
+// add adds a batch and returns its sequence number and the number
+// of the events which have been dropped as the window is full.
+func (u *unacked) add(raw [][]byte) (uint64, int) {
+	u.Lock()
+	defer u.Unlock()
+
+	var dropped int
+
+	u.seq++
+	u.batches = append(u.batches, inflight{seq: u.seq, raw: raw})
+	u.events += len(raw)
+
+	for u.events > u.window && len(u.batches) > 1 {
+		dropped += len(u.batches[0].raw)
+		u.events -= len(u.batches[0].raw)
+		u.batches = u.batches[1:]
+	}
+
+	return u.seq, dropped
+}
+
+// ack removes the batches up to the sequence number,
+// the server acknowledges them in order.
+func (u *unacked) ack(seq uint64) {
+	u.Lock()
+	defer u.Unlock()
+
+	i := 0
+	for ; i < len(u.batches) && u.batches[i].seq <= seq; i++ {
+		u.events -= len(u.batches[i].raw)
+	}
+
+	u.batches = u.batches[i:]
+}
+
+// pending returns the unacknowledged batches.
+func (u *unacked) pending() []inflight {
+	u.Lock()
+	defer u.Unlock()
+
+	return append([]inflight{}, u.batches...)
+}
+
+// take returns the unacknowledged events and removes them.
+func (u *unacked) take() [][]byte {
+	u.Lock()
+	defer u.Unlock()
+
+	var raw [][]byte
+	for _, b := range u.batches {
+		raw = append(raw, b.raw...)
+	}
+
+	u.batches = nil
+	u.events = 0
+
+	return raw
+}
+
+// ackSender sends the fields with sequence numbers, the server acknowledges
+// them once they have been enqueued. the unacknowledged events are spooled
+// if the stream fails, otherwise they're resent after reconnect.
+type ackSender struct {
+	stream  pb.TCPDog_TracepointAckClient
+	p       *helper.PB
+	size    int
+	spooled bool
+	batch   pb.FieldsSeq
+	raw     [][]byte
+	unacked *unacked
+	logger  *zap.Logger
+	done    chan struct{}
+}
+
+func newAckSender(stream pb.TCPDog_TracepointAckClient, p *helper.PB, gCfg *grpcConf, u *unacked, logger *zap.Logger) (*ackSender, error) {
+	s := &ackSender{
+		stream:  stream,
+		p:       p,
+		size:    gCfg.BatchSize,
+		spooled: gCfg.Spool.Path != "",
+		unacked: u,
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+
+	go s.recv()
+
+	// resends the unacknowledged events of the previous connection
+	for _, b := range u.pending() {
+		msg := &pb.FieldsSeq{Seq: b.seq}
+		for _, raw := range b.raw {
+			msg.Fields = append(msg.Fields, p.Unmarshal(bytes.NewBuffer(raw)))
+		}
+
+		if err := stream.Send(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *ackSender) send(buf *bytes.Buffer) error {
+	s.raw = append(s.raw, append([]byte{}, buf.Bytes()...))
+	s.batch.Fields = append(s.batch.Fields, s.p.Unmarshal(buf))
+
+	if len(s.batch.Fields) < s.size {
+		return nil
+	}
+
+	return s.flush()
+}
+
+func (s *ackSender) flush() error {
+	if len(s.batch.Fields) < 1 {
+		return nil
+	}
+
+	seq, dropped := s.unacked.add(s.raw)
+	if dropped > 0 {
+		s.logger.Warn("grpc", zap.String("msg", fmt.Sprintf("%d unacknowledged events have been dropped", dropped)))
+	}
+
+	s.batch.Seq = seq
+	s.raw = nil
+
+	err := s.stream.Send(&s.batch)
+	s.batch.Fields = s.batch.Fields[:0]
+
+	return err
+}
+
+// unsent returns the unacknowledged events to spool them,
+// they're kept in memory if the spool isn't configured.
+func (s *ackSender) unsent(_ []byte) [][]byte {
+	if len(s.raw) > 0 {
+		s.unacked.add(s.raw)
+		s.raw = nil
+		s.batch.Fields = s.batch.Fields[:0]
+	}
+
+	if !s.spooled {
+		return nil
+	}
+
+	return s.unacked.take()
+}
+
+// close closes the stream and waits for the remaining acknowledgements.
+func (s *ackSender) close() {
+	s.stream.CloseSend()
+
+	select {
+	case <-s.done:
+	case <-time.After(ackTimeout):
+	}
+}
+
+func (s *ackSender) recv() {
+	defer close(s.done)
+
+	for {
+		ack, err := s.stream.Recv()
+		if err != nil {
+			return
+		}
+
+		s.unacked.ack(ack.Seq)
+	}
+}
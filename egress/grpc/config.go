@@ -1,21 +1,56 @@
 package grpc
 
-import "github.com/mehrdadrad/tcpdog/config"
+import (
+	"fmt"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/spool"
+)
 
 type grpcConf struct {
-	Server    string
-	TLSConfig config.TLSConfig
+	Server        string
+	Compression   string // gzip or snappy
+	BatchSize     int    // fields per message (grpc-pb), disabled if it's less than 2
+	BatchInterval int    // millisecond, flushes the partial batch
+	SendTimeout   int    // millisecond, reconnects if a send takes longer
+	MaxMsgSize    int    // bytes
+	Ack           bool   // at-least-once delivery (grpc-pb)
+	AckWindow     int    // max unacknowledged events in memory
+	Keepalive     keepaliveConf
+	Token         string // bearer token, it requires tls
+	TLSConfig     config.TLSConfig
+	Spool         spool.Config
+}
+
+type keepaliveConf struct {
+	Time                int // seconds, keepalive is disabled if it's zero
+	Timeout             int // seconds
+	PermitWithoutStream bool
 }
 
 func gRPCConfig(cfg map[string]interface{}) (*grpcConf, error) {
 	// default config
 	gCfg := &grpcConf{
-		Server: "localhost:8085",
+		Server:        "localhost:8085",
+		BatchInterval: 1000,
+		MaxMsgSize:    4 << 20,
+		AckWindow:     10000,
+		Keepalive: keepaliveConf{
+			Timeout: 20,
+		},
 	}
 
 	if err := config.Transform(cfg, gCfg); err != nil {
 		return nil, err
 	}
 
+	if gCfg.BatchInterval < 1 {
+		return nil, fmt.Errorf("invalid batch interval: %d", gCfg.BatchInterval)
+	}
+
+	if gCfg.MaxMsgSize < 1 {
+		return nil, fmt.Errorf("invalid max message size: %d", gCfg.MaxMsgSize)
+	}
+
 	return gCfg, nil
 }
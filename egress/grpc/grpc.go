@@ -3,29 +3,208 @@ package grpc
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	pb "github.com/mehrdadrad/tcpdog/proto"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/grpc/snappy"
 	"github.com/mehrdadrad/tcpdog/egress/helper"
+	"github.com/mehrdadrad/tcpdog/egress/spool"
 )
 
+// sender sends the buffers through a grpc stream.
+type sender interface {
+	send(buf *bytes.Buffer) error
+	flush() error
+	// unsent returns the events which haven't been
+	// sent once the send or the flush failed.
+	unsent(b []byte) [][]byte
+	close()
+}
+
+type spbSender struct {
+	stream pb.TCPDog_TracepointSPBClient
+	spb    *helper.StructPB
+}
+
+func (s *spbSender) send(buf *bytes.Buffer) error {
+	return s.stream.Send(&pb.FieldsSPB{
+		Fields: s.spb.Unmarshal(buf),
+	})
+}
+
+func (s *spbSender) flush() error { return nil }
+
+func (s *spbSender) unsent(b []byte) [][]byte { return single(b) }
+
+func (s *spbSender) close() {
+	s.stream.CloseAndRecv()
+}
+
+type pbSender struct {
+	stream pb.TCPDog_TracepointClient
+	p      *helper.PB
+}
+
+func (s *pbSender) send(buf *bytes.Buffer) error {
+	return s.stream.Send(s.p.Unmarshal(buf))
+}
+
+func (s *pbSender) flush() error { return nil }
+
+func (s *pbSender) unsent(b []byte) [][]byte { return single(b) }
+
+func (s *pbSender) close() {
+	s.stream.CloseAndRecv()
+}
+
+func single(b []byte) [][]byte {
+	if b == nil {
+		return nil
+	}
+
+	return [][]byte{b}
+}
+
+// batchSender sends the fields in batches, it keeps the raw
+// events of the pending batch to spool them if the stream fails.
+type batchSender struct {
+	stream pb.TCPDog_TracepointBatchClient
+	p      *helper.PB
+	size   int
+	batch  pb.FieldsBatch
+	raw    [][]byte
+}
+
+func (s *batchSender) send(buf *bytes.Buffer) error {
+	s.raw = append(s.raw, append([]byte{}, buf.Bytes()...))
+	s.batch.Fields = append(s.batch.Fields, s.p.Unmarshal(buf))
+
+	if len(s.batch.Fields) < s.size {
+		return nil
+	}
+
+	return s.flush()
+}
+
+func (s *batchSender) flush() error {
+	if len(s.batch.Fields) < 1 {
+		return nil
+	}
+
+	if err := s.stream.Send(&s.batch); err != nil {
+		return err
+	}
+
+	s.batch.Fields = s.batch.Fields[:0]
+	s.raw = s.raw[:0]
+
+	return nil
+}
+
+func (s *batchSender) unsent(_ []byte) [][]byte { return s.raw }
+
+func (s *batchSender) close() {
+	s.stream.CloseAndRecv()
+}
+
+// timeoutSender cancels the stream if a send takes longer than the timeout.
+type timeoutSender struct {
+	sender
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func (s *timeoutSender) send(buf *bytes.Buffer) error {
+	t := time.AfterFunc(s.timeout, s.cancel)
+	defer t.Stop()
+
+	return s.sender.send(buf)
+}
+
+func (s *timeoutSender) flush() error {
+	t := time.AfterFunc(s.timeout, s.cancel)
+	defer t.Stop()
+
+	return s.sender.flush()
+}
+
 // StartStructPB sends fields to a grpc server with structpb type.
-func StartStructPB(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
-	var (
-		stream pb.TCPDog_TracepointSPBClient
-		conn   *grpc.ClientConn
-	)
+func StartStructPB(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	spb := helper.NewStructPB(config.FromContext(ctx).GetEgressOutFields(tp))
+
+	return start(ctx, tp, bufpool, ch, wg, func(ctx context.Context, conn *grpc.ClientConn, _ *grpcConf) (sender, error) {
+		stream, err := pb.NewTCPDogClient(conn).TracepointSPB(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &spbSender{stream: stream, spb: spb}, nil
+	})
+}
+
+// Start sends fields to a grpc server, the fields are sent in batches if
+// the batch size is configured. if the ack is enabled, the events are kept
+// until the server acknowledges them and they're resent after reconnect.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var u *unacked
+
+	p := helper.NewPB(config.FromContext(ctx).Derived[tp.Fields])
+	logger := config.FromContext(ctx).Logger()
+
+	return start(ctx, tp, bufpool, ch, wg, func(ctx context.Context, conn *grpc.ClientConn, gCfg *grpcConf) (sender, error) {
+		if gCfg.Ack {
+			if u == nil {
+				u = &unacked{window: gCfg.AckWindow}
+			}
+
+			stream, err := pb.NewTCPDogClient(conn).TracepointAck(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			return newAckSender(stream, p, gCfg, u, logger)
+		}
+
+		if gCfg.BatchSize > 1 {
+			stream, err := pb.NewTCPDogClient(conn).TracepointBatch(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			return &batchSender{stream: stream, p: p, size: gCfg.BatchSize}, nil
+		}
+
+		stream, err := pb.NewTCPDogClient(conn).Tracepoint(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &pbSender{stream: stream, p: p}, nil
+	})
+}
+
+// start connects to the grpc server and sends the buffers, it reconnects
+// with backoff. if the spool is configured, the buffers are written to the
+// spool while the server is unreachable and replayed in order on reconnect.
+func start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup,
+	newSender func(ctx context.Context, conn *grpc.ClientConn, gCfg *grpcConf) (sender, error)) error {
+	var sp *spool.Spool
 
 	cfg := config.FromContext(ctx)
 	logger := cfg.Logger()
 	backoff := helper.NewBackoff(logger)
+	hostname, _ := os.Hostname()
 
 	gCfg, err := gRPCConfig(cfg.Egress[tp.Egress].Config)
 	if err != nil {
@@ -37,147 +216,217 @@ func StartStructPB(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool
 		return err
 	}
 
+	if gCfg.Spool.Path != "" {
+		sp, err = spool.New(&gCfg.Spool)
+		if err != nil {
+			return err
+		}
+	}
+
+	wg.Add(1)
 	go func() {
+		var drainer *spool.Drainer
+
+		defer wg.Done()
+		defer func() {
+			if drainer != nil {
+				drainer.Stop()
+			}
+
+			if sp != nil {
+				sp.Close()
+			}
+		}()
+
 		for {
+			if sp != nil && drainer == nil {
+				drainer = sp.Drain(ch, bufpool, logger)
+			}
+
 			backoff.Next()
 
-			conn, err = grpc.Dial(gCfg.Server, opts...)
+			if ctx.Err() != nil {
+				return
+			}
+
+			conn, err := grpc.Dial(gCfg.Server, opts...)
 			if err != nil {
 				logger.Warn("grpc", zap.Error(err))
+				if spooled(drainer, logger) {
+					return
+				}
 				continue
 			}
 
-			client := pb.NewTCPDogClient(conn)
-			stream, err = client.TracepointSPB(ctx)
+			sctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx,
+				"hostname", hostname, "version", cfg.Version()))
+
+			s, err := newSender(sctx, conn, gCfg)
 			if err != nil {
 				logger.Warn("grpc", zap.Error(err))
+				cancel()
 				conn.Close()
+				if spooled(drainer, logger) {
+					return
+				}
 				continue
 			}
 
+			if gCfg.SendTimeout > 0 {
+				s = &timeoutSender{
+					sender:  s,
+					timeout: time.Duration(gCfg.SendTimeout) * time.Millisecond,
+					cancel:  cancel,
+				}
+			}
+
 			logger.Info("grpc", zap.String("msg",
 				fmt.Sprintf("%s has been connected to %s", tp.Egress, gCfg.Server)))
 
-			err = structpb(ctx, stream, tp, bufpool, ch)
+			if drainer != nil {
+				closed, err := replay(sp, drainer, s, logger)
+				drainer = nil
+				if err != nil {
+					logger.Warn("grpc", zap.Error(err))
+					cancel()
+					conn.Close()
+					continue
+				}
+
+				if closed {
+					s.close()
+					cancel()
+					conn.Close()
+					return
+				}
+			}
+
+			interval := time.Duration(gCfg.BatchInterval) * time.Millisecond
+			err = send(ctx, s, sp, bufpool, ch, interval)
+			cancel()
+			conn.Close()
 			if err != nil {
 				logger.Warn("grpc", zap.Error(err))
-				conn.Close()
 				continue
 			}
 
 			break
 		}
-
 	}()
 
 	return nil
 }
 
-func structpb(ctx context.Context, stream pb.TCPDog_TracepointSPBClient, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
-	var (
-		cfg = config.FromContext(ctx)
-		spb = helper.NewStructPB(cfg.Fields[tp.Fields])
-		buf *bytes.Buffer
-		err error
-	)
+// send sends the buffers until the channel is closed or the context is
+// canceled, the unsent events are written to the spool if it's configured.
+// the pending batch is flushed per interval.
+func send(ctx context.Context, s sender, sp *spool.Spool, bufpool *sync.Pool, ch chan *bytes.Buffer, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		select {
-		case buf = <-ch:
-			err = stream.Send(&pb.FieldsSPB{
-				Fields: spb.Unmarshal(buf),
-			})
-			if err != nil {
-				return err
+		case buf, ok := <-ch:
+			if !ok {
+				if err := s.flush(); err != nil {
+					write(sp, s.unsent(nil))
+					return err
+				}
+
+				s.close()
+				write(sp, s.unsent(nil))
+				return nil
 			}
 
-			bufpool.Put(buf)
-		case <-ctx.Done():
-			stream.CloseAndRecv()
-			return nil
-		}
-	}
-}
-
-func protobuf(ctx context.Context, stream pb.TCPDog_TracepointClient, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
-	var (
-		buf         *bytes.Buffer
-		hostname, _ = os.Hostname()
-	)
+			// unmarshal consumes the buffer
+			b := buf.Bytes()
 
-	for {
-		select {
-		case buf = <-ch:
-			m := pb.Fields{}
-			protojson.Unmarshal(buf.Bytes(), &m)
-			m.Hostname = &hostname
-			if err := stream.Send(&m); err != nil {
+			if err := s.send(buf); err != nil {
+				write(sp, s.unsent(b))
+				bufpool.Put(buf)
 				return err
 			}
 
 			bufpool.Put(buf)
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				write(sp, s.unsent(nil))
+				return err
+			}
 		case <-ctx.Done():
-			stream.CloseAndRecv()
+			s.flush()
+			s.close()
+			write(sp, s.unsent(nil))
 			return nil
 		}
 	}
 }
 
-// Start sends fields to a grpc server
-func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
-	var (
-		stream pb.TCPDog_TracepointClient
-		conn   *grpc.ClientConn
-	)
+// write writes the events to the spool if it's configured.
+func write(sp *spool.Spool, events [][]byte) {
+	if sp == nil {
+		return
+	}
 
-	cfg := config.FromContext(ctx)
-	logger := cfg.Logger()
-	backoff := helper.NewBackoff(logger)
+	for _, b := range events {
+		sp.Write(b)
+	}
+}
 
-	gCfg, err := gRPCConfig(cfg.Egress[tp.Egress].Config)
-	if err != nil {
-		return err
+// replay replays the spool while the drainer keeps the channel from
+// backing up, then it stops the drainer and replays the rest. it returns
+// true if the drainer has seen the channel closed.
+func replay(sp *spool.Spool, drainer *spool.Drainer, s sender, logger *zap.Logger) (bool, error) {
+	// the batch is flushed per event so a failed event remains in the spool
+	fn := func(b []byte) error {
+		if err := s.send(bytes.NewBuffer(b)); err != nil {
+			return err
+		}
+
+		return s.flush()
 	}
 
-	opts, err := dialOpts(gCfg)
+	n, err := sp.Replay(fn)
 	if err != nil {
-		return err
+		drainer.Stop()
+		return false, err
 	}
 
-	go func() {
-		for {
-			backoff.Next()
+	closed := drainer.Stop()
 
-			conn, err = grpc.Dial(gCfg.Server, opts...)
-			if err != nil {
-				logger.Warn("grpc", zap.Error(err))
-				continue
-			}
+	m, err := sp.Replay(fn)
+	if n+m > 0 {
+		logger.Info("grpc", zap.String("msg", fmt.Sprintf("%d spooled events have been replayed", n+m)))
+	}
 
-			client := pb.NewTCPDogClient(conn)
-			stream, err = client.Tracepoint(ctx)
-			if err != nil {
-				logger.Warn("grpc", zap.Error(err))
-				conn.Close()
-				continue
-			}
+	return closed, err
+}
 
-			logger.Info("grpc", zap.String("msg",
-				fmt.Sprintf("%s has been connected to %s", tp.Egress, gCfg.Server)))
+// spooled returns true if the channel has been closed and
+// the drainer wrote the remaining buffers to the spool.
+func spooled(drainer *spool.Drainer, logger *zap.Logger) bool {
+	if drainer == nil {
+		return false
+	}
 
-			err = protobuf(ctx, stream, bufpool, ch)
-			if err != nil {
-				logger.Warn("grpc", zap.Error(err))
-				conn.Close()
-				continue
-			}
+	select {
+	case <-drainer.Done():
+		logger.Info("grpc", zap.String("msg", "the remaining events have been spooled"))
+		return true
+	default:
+		return false
+	}
+}
 
-			break
-		}
+// bearer represents the bearer token credentials.
+type bearer string
 
-	}()
+func (b bearer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
 
-	return nil
+func (b bearer) RequireTransportSecurity() bool {
+	return true
 }
 
 func dialOpts(gCfg *grpcConf) ([]grpc.DialOption, error) {
@@ -194,5 +443,36 @@ func dialOpts(gCfg *grpcConf) ([]grpc.DialOption, error) {
 		opts = append(opts, grpc.WithInsecure())
 	}
 
+	callOpts := []grpc.CallOption{
+		grpc.MaxCallSendMsgSize(gCfg.MaxMsgSize),
+		grpc.MaxCallRecvMsgSize(gCfg.MaxMsgSize),
+	}
+
+	switch gCfg.Compression {
+	case "":
+	case gzip.Name, snappy.Name:
+		callOpts = append(callOpts, grpc.UseCompressor(gCfg.Compression))
+	default:
+		return nil, fmt.Errorf("unknown grpc compression: %s", gCfg.Compression)
+	}
+
+	opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+
+	if gCfg.Token != "" {
+		if !gCfg.TLSConfig.Enable {
+			return nil, errors.New("grpc token requires tls")
+		}
+
+		opts = append(opts, grpc.WithPerRPCCredentials(bearer(gCfg.Token)))
+	}
+
+	if gCfg.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(gCfg.Keepalive.Time) * time.Second,
+			Timeout:             time.Duration(gCfg.Keepalive.Timeout) * time.Second,
+			PermitWithoutStream: gCfg.Keepalive.PermitWithoutStream,
+		}))
+	}
+
 	return opts, nil
 }
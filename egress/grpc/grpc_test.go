@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"sync"
@@ -25,6 +26,7 @@ var (
 type server struct {
 	ch1 *pb.Fields
 	ch2 *pb.FieldsSPB
+	ch3 *pb.FieldsBatch
 }
 
 func (s *server) Tracepoint(srv pb.TCPDog_TracepointServer) error {
@@ -50,6 +52,21 @@ func (s *server) TracepointSPB(srv pb.TCPDog_TracepointSPBServer) error {
 	}
 }
 
+func (s *server) TracepointAck(srv pb.TCPDog_TracepointAckServer) error {
+	return nil
+}
+
+func (s *server) TracepointBatch(srv pb.TCPDog_TracepointBatchServer) error {
+	for {
+		f, err := srv.Recv()
+		if err != nil {
+			return err
+		}
+
+		s.ch3 = f
+	}
+}
+
 func TestGRPC(t *testing.T) {
 	l, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -65,6 +82,7 @@ func TestGRPC(t *testing.T) {
 
 	t.Run("StructPB", testStructPB)
 	t.Run("testProtoJSON", testProtoJSON)
+	t.Run("testBatch", testBatch)
 
 	t.Cleanup(func() { l.Close() })
 }
@@ -100,7 +118,7 @@ func testStructPB(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	ctx = cfg.WithContext(ctx)
 	ch <- bytes.NewBufferString(`{"F1":5,"F2":6,"Timestamp":1609564925}`)
-	err := StartStructPB(ctx, tp, bufPool, ch)
+	err := StartStructPB(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
@@ -147,7 +165,7 @@ func testProtoJSON(t *testing.T) {
 	}
 
 	ch <- bytes.NewBufferString(`{"SRTT":5,"AdvMSS":6,"Timestamp":1609564925}`)
-	err := Start(ctx, tp, bufPool, ch)
+	err := Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.NoError(t, err)
 
 	time.Sleep(time.Second)
@@ -163,3 +181,293 @@ func testProtoJSON(t *testing.T) {
 	cancel()
 	time.Sleep(time.Second)
 }
+
+func testBatch(t *testing.T) {
+	ch := make(chan *bytes.Buffer, 3)
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"foo": {
+				Type: "grpc",
+				Config: map[string]interface{}{
+					"server":        fmt.Sprintf(":%d", port),
+					"batchSize":     2,
+					"batchInterval": 100,
+					"sendTimeout":   1000,
+					"compression":   "snappy",
+					"keepalive":     map[string]interface{}{"time": 10},
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory3")
+
+	ctx := cfg.WithContext(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
+
+	tp := config.Tracepoint{
+		Egress: "foo",
+	}
+
+	for i := 1; i <= 3; i++ {
+		ch <- bytes.NewBufferString(fmt.Sprintf(`{"SRTT":%d,"Timestamp":1609564925}`, i))
+	}
+
+	err := Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
+	assert.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	// the last event is flushed by the batch interval
+	assert.NotNil(t, srv.ch3)
+	assert.Len(t, srv.ch3.Fields, 1)
+	assert.Equal(t, uint32(3), *srv.ch3.Fields[0].SRTT)
+
+	cancel()
+	time.Sleep(time.Second)
+}
+
+func TestGRPCConfig(t *testing.T) {
+	_, err := gRPCConfig(map[string]interface{}{"batchInterval": 0})
+	assert.Error(t, err)
+
+	_, err = gRPCConfig(map[string]interface{}{"maxMsgSize": 0})
+	assert.Error(t, err)
+}
+
+func TestDialOpts(t *testing.T) {
+	gCfg, err := gRPCConfig(map[string]interface{}{"compression": "gzip"})
+	assert.NoError(t, err)
+	_, err = dialOpts(gCfg)
+	assert.NoError(t, err)
+
+	gCfg.Compression = "foo"
+	_, err = dialOpts(gCfg)
+	assert.Error(t, err)
+
+	// the token requires tls
+	gCfg.Compression = ""
+	gCfg.Token = "foo"
+	_, err = dialOpts(gCfg)
+	assert.Error(t, err)
+
+	md, err := bearer("foo").GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer foo", md["authorization"])
+}
+
+type spoolServer struct {
+	sync.Mutex
+	srtt []uint32
+}
+
+func (s *spoolServer) Tracepoint(srv pb.TCPDog_TracepointServer) error {
+	for {
+		f, err := srv.Recv()
+		if err != nil {
+			return err
+		}
+
+		s.Lock()
+		s.srtt = append(s.srtt, *f.SRTT)
+		s.Unlock()
+	}
+}
+
+func (s *spoolServer) TracepointSPB(srv pb.TCPDog_TracepointSPBServer) error {
+	return nil
+}
+
+func (s *spoolServer) TracepointBatch(srv pb.TCPDog_TracepointBatchServer) error {
+	return nil
+}
+
+func (s *spoolServer) TracepointAck(srv pb.TCPDog_TracepointAckServer) error {
+	return nil
+}
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"foo": {
+				Type: "grpc",
+				Config: map[string]interface{}{
+					"server": addr,
+					"spool":  map[string]interface{}{"path": dir},
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory")
+
+	ctx := cfg.WithContext(context.Background())
+	tp := config.Tracepoint{Egress: "foo"}
+
+	// the server is unreachable, the events should be spooled
+	ch := make(chan *bytes.Buffer, 3)
+	for i := 1; i <= 3; i++ {
+		ch <- bytes.NewBufferString(fmt.Sprintf(`{"SRTT":%d}`, i))
+	}
+	close(ch)
+
+	wg := new(sync.WaitGroup)
+	err = Start(ctx, tp, bufPool, ch, wg)
+	assert.NoError(t, err)
+	wg.Wait()
+
+	// the server is reachable, the events should be replayed in order
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &spoolServer{}
+	gServer := grpc.NewServer()
+	pb.RegisterTCPDogServer(gServer, srv)
+	go gServer.Serve(l)
+	defer gServer.Stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch = make(chan *bytes.Buffer, 1)
+	ch <- bytes.NewBufferString(`{"SRTT":4}`)
+
+	err = Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
+	assert.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	srv.Lock()
+	assert.Equal(t, []uint32{1, 2, 3, 4}, srv.srtt)
+	srv.Unlock()
+}
+
+type ackServer struct {
+	spoolServer
+	streams int
+}
+
+// TracepointAck fails the first stream without acknowledgement.
+func (s *ackServer) TracepointAck(srv pb.TCPDog_TracepointAckServer) error {
+	s.Lock()
+	s.streams++
+	first := s.streams == 1
+	s.Unlock()
+
+	for {
+		f, err := srv.Recv()
+		if err != nil {
+			return err
+		}
+
+		s.Lock()
+		for _, fields := range f.Fields {
+			s.srtt = append(s.srtt, *fields.SRTT)
+		}
+		s.Unlock()
+
+		if first {
+			return fmt.Errorf("unavailable")
+		}
+
+		srv.Send(&pb.Ack{Seq: f.Seq})
+	}
+}
+
+func TestAck(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &ackServer{}
+	gServer := grpc.NewServer()
+	pb.RegisterTCPDogServer(gServer, srv)
+	go gServer.Serve(l)
+	defer gServer.Stop()
+
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"foo": {
+				Type: "grpc",
+				Config: map[string]interface{}{
+					"server": l.Addr().String(),
+					"ack":    true,
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory4")
+
+	ctx, cancel := context.WithCancel(cfg.WithContext(context.Background()))
+	defer cancel()
+
+	tp := config.Tracepoint{Egress: "foo"}
+
+	ch := make(chan *bytes.Buffer, 2)
+	ch <- bytes.NewBufferString(`{"SRTT":1}`)
+
+	err = Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
+	assert.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+	ch <- bytes.NewBufferString(`{"SRTT":2}`)
+
+	// the unacknowledged events are resent after reconnect
+	time.Sleep(4 * time.Second)
+
+	srv.Lock()
+	assert.Equal(t, []uint32{1, 1, 2}, srv.srtt)
+	srv.Unlock()
+}
+
+func TestUnacked(t *testing.T) {
+	u := &unacked{window: 3}
+
+	seq, dropped := u.add([][]byte{[]byte("a"), []byte("b")})
+	assert.Equal(t, uint64(1), seq)
+	assert.Equal(t, 0, dropped)
+
+	_, dropped = u.add([][]byte{[]byte("c"), []byte("d")})
+	assert.Equal(t, 2, dropped)
+
+	seq, _ = u.add([][]byte{[]byte("e")})
+	assert.Equal(t, uint64(3), seq)
+
+	u.ack(2)
+	assert.Len(t, u.pending(), 1)
+	assert.Equal(t, [][]byte{[]byte("e")}, u.take())
+	assert.Len(t, u.pending(), 0)
+}
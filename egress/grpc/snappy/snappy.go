@@ -0,0 +1,51 @@
+// Package snappy registers the snappy compressor for grpc,
+// it's imported by both the agent and the server.
+package snappy
+
+import (
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name registered for the snappy compressor.
+const Name = "snappy"
+
+func init() {
+	c := &compressor{}
+	c.poolCompressor.New = func() interface{} {
+		return &writer{Writer: snappy.NewBufferedWriter(nil), pool: &c.poolCompressor}
+	}
+	encoding.RegisterCompressor(c)
+}
+
+type writer struct {
+	*snappy.Writer
+	pool *sync.Pool
+}
+
+// Close flushes the writer and puts it back to the pool.
+func (w *writer) Close() error {
+	defer w.pool.Put(w)
+	return w.Writer.Close()
+}
+
+type compressor struct {
+	poolCompressor sync.Pool
+}
+
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	sw := c.poolCompressor.Get().(*writer)
+	sw.Reset(w)
+	return sw, nil
+}
+
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+func (c *compressor) Name() string {
+	return Name
+}
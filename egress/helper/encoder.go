@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+// GetEncoder returns a function to encode the events based on
+// the serialization, the json events are extended by the hostname.
+func GetEncoder(ser string, cfg *config.Config, tp config.Tracepoint) (func(buf *bytes.Buffer) ([]byte, error), error) {
+	switch ser {
+	case "json":
+		hostname, _ := os.Hostname()
+		tail := []byte(fmt.Sprintf("\"Hostname\":\"%s\"}", hostname))
+
+		return func(buf *bytes.Buffer) ([]byte, error) {
+			b := make([]byte, 0, buf.Len()+len(tail))
+			b = append(b, buf.Bytes()...)
+			b[len(b)-1] = ','
+			return append(b, tail...), nil
+		}, nil
+	case "spb":
+		spb := NewStructPB(cfg.GetEgressOutFields(tp))
+		return func(buf *bytes.Buffer) ([]byte, error) {
+			return proto.Marshal(&pb.FieldsSPB{Fields: spb.Unmarshal(buf)})
+		}, nil
+	case "pb":
+		p := NewPB(cfg.Derived[tp.Fields])
+		return func(buf *bytes.Buffer) ([]byte, error) {
+			return proto.Marshal(p.Unmarshal(buf))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown serialization: %s", ser)
+}
@@ -0,0 +1,31 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+func TestGetEncoder(t *testing.T) {
+	tp := config.Tracepoint{Fields: "myfields"}
+	cfg := &config.Config{
+		Fields: map[string][]config.Field{
+			"myfields": {{Name: "F1"}},
+		},
+	}
+
+	for _, ser := range []string{"json", "pb", "spb"} {
+		encode, err := GetEncoder(ser, cfg, tp)
+		assert.NoError(t, err)
+
+		b, err := encode(bytes.NewBufferString(`{"F1":5,"Timestamp":1609564925}`))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, b)
+	}
+
+	_, err := GetEncoder("foo", cfg, tp)
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"bytes"
+	"strconv"
+
+	pbstruct "github.com/golang/protobuf/ptypes/struct"
+)
+
+// Field represents a decoded event field as text.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Fields decodes the event to the fields in the configured order,
+// the hostname and the timestamp are appended at the end.
+func (s *StructPB) Fields(buf *bytes.Buffer) []Field {
+	r := s.Unmarshal(buf)
+
+	fields := make([]Field, 0, len(s.fieldsName)+2)
+	for _, name := range s.fieldsName {
+		fields = append(fields, Field{Name: name, Value: ValueString(r.Fields[name])})
+	}
+
+	return append(fields,
+		Field{Name: "Hostname", Value: ValueString(r.Fields["Hostname"])},
+		Field{Name: "Timestamp", Value: ValueString(r.Fields["Timestamp"])},
+	)
+}
+
+// ValueString returns the struct pb value as text.
+func ValueString(v *pbstruct.Value) string {
+	switch k := v.GetKind().(type) {
+	case *pbstruct.Value_StringValue:
+		return k.StringValue
+	case *pbstruct.Value_NumberValue:
+		return strconv.FormatFloat(k.NumberValue, 'f', -1, 64)
+	}
+
+	return ""
+}
+
+// Names returns the fields name in the configured order.
+func (s *StructPB) Names() []string {
+	return s.fieldsName
+}
@@ -2,16 +2,20 @@ package helper
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	pbstruct "github.com/golang/protobuf/ptypes/struct"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/mehrdadrad/tcpdog/config"
+	pb "github.com/mehrdadrad/tcpdog/proto"
 )
 
 var comma = []byte(",")[0]
@@ -32,6 +36,13 @@ type StructPB struct {
 	hostname   string
 }
 
+// PB represents the conversion between json bytes
+// to protobuf, derived fields are carried by the Ext map.
+type PB struct {
+	derived  [][]byte
+	hostname string
+}
+
 // NewStructPB constructs and initializes a struct pb.
 func NewStructPB(fields []config.Field) *StructPB {
 	s := &StructPB{}
@@ -80,12 +91,12 @@ func (s *StructPB) Unmarshal(buf *bytes.Buffer) *pbstruct.Struct {
 			if err != nil {
 				log.Fatal(err)
 			}
-			vi, err := strconv.Atoi(string(v[:len(v)-1]))
+			vf, err := strconv.ParseFloat(string(v[:len(v)-1]), 64)
 			if err != nil {
 				log.Fatal(err)
 			}
 			r.Fields[name] = &pbstruct.Value{
-				Kind: &pbstruct.Value_NumberValue{NumberValue: float64(vi)},
+				Kind: &pbstruct.Value_NumberValue{NumberValue: vf},
 			}
 		}
 	}
@@ -106,6 +117,78 @@ func (s *StructPB) Unmarshal(buf *bytes.Buffer) *pbstruct.Struct {
 	return r
 }
 
+// NewPB constructs a protobuf converter.
+func NewPB(derived []config.DerivedField) *PB {
+	p := &PB{}
+	p.hostname, _ = os.Hostname()
+
+	for _, d := range derived {
+		p.derived = append(p.derived, []byte(fmt.Sprintf("\"%s\":", d.Name)))
+	}
+
+	return p
+}
+
+// Unmarshal decodes bytes to protobuf message
+func (p *PB) Unmarshal(buf *bytes.Buffer) *pb.Fields {
+	m := &pb.Fields{}
+	protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(buf.Bytes(), m)
+	m.Hostname = &p.hostname
+
+	if len(p.derived) > 0 {
+		m.Ext = make(map[string]float64, len(p.derived))
+	}
+
+	for _, key := range p.derived {
+		b := buf.Bytes()
+		i := bytes.Index(b, key)
+		if i < 0 {
+			continue
+		}
+
+		b = b[i+len(key):]
+		if j := bytes.IndexAny(b, ",}"); j > 0 {
+			v, _ := strconv.ParseFloat(string(b[:j]), 64)
+			m.Ext[string(key[1:len(key)-2])] = v
+		}
+	}
+
+	return m
+}
+
+// Loop hands over the events to the write function until the channel is
+// closed or the context is done. the events are dropped with a backoff
+// while it's failing and the fail function is called per failure (e.g.
+// to reconnect at the next write).
+func Loop(ctx context.Context, name string, logger *zap.Logger, bufpool *sync.Pool,
+	ch chan *bytes.Buffer, write func(buf *bytes.Buffer) error, fail func()) {
+	backoff := NewBackoff(logger)
+
+	for {
+		select {
+		case buf, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			err := write(buf)
+			bufpool.Put(buf)
+
+			if err != nil {
+				logger.Error(name, zap.Error(err))
+
+				backoff.Next()
+				if fail != nil {
+					fail()
+				}
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // NewBackoff constructs a new backoff
 func NewBackoff(logger *zap.Logger) *Backoff {
 	return &Backoff{logger: logger}
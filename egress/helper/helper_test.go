@@ -38,6 +38,34 @@ func TestPBStructUnmarshal(t *testing.T) {
 
 }
 
+func TestStructPBFields(t *testing.T) {
+	spb := NewStructPB(cfg.Fields["myfields"])
+	spb.hostname = "fakehost"
+	buf := bytes.NewBufferString(`{"Task":"curl","Fake1":1,"Fake2":0.5,"Timestamp":1609720926}`)
+
+	assert.Equal(t, []Field{
+		{"Task", "curl"},
+		{"Fake1", "1"},
+		{"Fake2", "0.5"},
+		{"Hostname", "fakehost"},
+		{"Timestamp", "1609720926"},
+	}, spb.Fields(buf))
+}
+
+func TestPBUnmarshal(t *testing.T) {
+	p := NewPB([]config.DerivedField{{Name: "LossRate"}, {Name: "Goodput"}})
+	p.hostname = "fakehost"
+	buf := bytes.NewBufferString(`{"Task":"curl","RTT":5,"LossRate":0.125,"Goodput":1200,"Timestamp":1609720926}`)
+	r := p.Unmarshal(buf)
+
+	assert.Equal(t, "curl", r.GetTask())
+	assert.Equal(t, uint32(5), r.GetRTT())
+	assert.Equal(t, uint64(1609720926), r.GetTimestamp())
+	assert.Equal(t, "fakehost", r.GetHostname())
+	assert.Equal(t, 0.125, r.Ext["LossRate"])
+	assert.Equal(t, 1200.0, r.Ext["Goodput"])
+}
+
 func TestBackoff(t *testing.T) {
 	cfg := config.Config{}
 	cfg.SetMockLogger("memory")
@@ -67,3 +95,19 @@ func BenchmarkPBStructUnmarshal(b *testing.B) {
 		spb.Unmarshal(buf)
 	}
 }
+
+func TestStats(t *testing.T) {
+	var s Stats
+
+	assert.Len(t, s.Get(), 0)
+
+	s.Count("foo", true)
+	s.Count("foo", true)
+	s.Count("foo", false)
+	s.Count("bar", false)
+
+	assert.Equal(t, map[string]Counters{
+		"foo": {Delivered: 2, Failed: 1},
+		"bar": {Failed: 1},
+	}, s.Get())
+}
@@ -0,0 +1,51 @@
+package helper
+
+import "sync"
+
+// Counters represents the delivery counters of a topic or a subject.
+type Counters struct {
+	Delivered uint64
+	Failed    uint64
+}
+
+// Stats represents the delivery counters per topic or subject,
+// the zero value is ready to use.
+type Stats struct {
+	mu       sync.Mutex
+	counters map[string]*Counters
+}
+
+// Count counts a delivered or a failed message.
+func (s *Stats) Count(key string, delivered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counters == nil {
+		s.counters = map[string]*Counters{}
+	}
+
+	c, ok := s.counters[key]
+	if !ok {
+		c = &Counters{}
+		s.counters[key] = c
+	}
+
+	if delivered {
+		c.Delivered++
+	} else {
+		c.Failed++
+	}
+}
+
+// Get returns the delivery counters.
+func (s *Stats) Get() map[string]Counters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := make(map[string]Counters, len(s.counters))
+	for key, c := range s.counters {
+		m[key] = *c
+	}
+
+	return m
+}
@@ -0,0 +1,36 @@
+package helper
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+// GetUnmarshal returns a function to unmarshal the events based on
+// the serialization, it returns nil if the serialization is unknown.
+func GetUnmarshal(ser string) func(b []byte) (interface{}, error) {
+	switch ser {
+	case "json":
+		return func(b []byte) (interface{}, error) {
+			m := map[string]interface{}{}
+			err := json.Unmarshal(b, &m)
+			return m, err
+		}
+	case "spb":
+		return func(b []byte) (interface{}, error) {
+			p := pb.FieldsSPB{}
+			err := proto.Unmarshal(b, &p)
+			return &p, err
+		}
+	case "pb":
+		return func(b []byte) (interface{}, error) {
+			p := pb.Fields{}
+			err := proto.Unmarshal(b, &p)
+			return &p, err
+		}
+	}
+
+	return nil
+}
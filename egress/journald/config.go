@@ -0,0 +1,45 @@
+package journald
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents journald egress configuration
+type Config struct {
+	Socket     string
+	Identifier string // SYSLOG_IDENTIFIER
+	Priority   string // emerg, alert, crit, err, warning, notice, info or debug
+	Prefix     string // the fields name prefix
+}
+
+var priorities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+func journaldConfig(cfg map[string]interface{}) *Config {
+	c := &Config{
+		Socket:     "/run/systemd/journal/socket",
+		Identifier: "tcpdog",
+		Priority:   "info",
+		Prefix:     "TCPDOG_",
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	return c
+}
+
+func (c *Config) priority() (int, error) {
+	p, ok := priorities[c.Priority]
+	if !ok {
+		return 0, fmt.Errorf("unknown priority: %s", c.Priority)
+	}
+
+	return p, nil
+}
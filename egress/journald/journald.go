@@ -0,0 +1,190 @@
+package journald
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+// shmDir is where the large entries are written to be passed by
+// the file descriptor, journald doesn't accept large datagrams.
+var shmDir = "/dev/shm"
+
+type journald struct {
+	conn    *net.UnixConn
+	addr    *net.UnixAddr
+	prefix  string
+	names   map[string]string // field name to journal field name
+	static  []byte            // PRIORITY, SYSLOG_IDENTIFIER and the tracepoint
+	spb     *helper.StructPB
+	buffer  *bytes.Buffer
+	bufpool *sync.Pool
+	logger  *zap.Logger
+}
+
+// Start starts writing the requested fields to the journal, the fields
+// are written as the native journal fields by the configured prefix.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg  = config.FromContext(ctx)
+		jCfg = journaldConfig(cfg.Egress[tp.Egress].Config)
+	)
+
+	priority, err := jCfg.priority()
+	if err != nil {
+		return err
+	}
+
+	j := &journald{
+		addr:    &net.UnixAddr{Name: jCfg.Socket, Net: "unixgram"},
+		prefix:  jCfg.Prefix,
+		names:   map[string]string{},
+		spb:     helper.NewStructPB(cfg.GetEgressOutFields(tp)),
+		buffer:  new(bytes.Buffer),
+		bufpool: bufpool,
+		logger:  cfg.Logger(),
+	}
+
+	static := new(bytes.Buffer)
+	writeField(static, "PRIORITY", strconv.Itoa(priority))
+	writeField(static, "SYSLOG_IDENTIFIER", jCfg.Identifier)
+	writeField(static, j.name("Tracepoint"), tp.Name)
+	j.static = static.Bytes()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		j.loop(ctx, ch)
+	}()
+
+	return nil
+}
+
+func (j *journald) loop(ctx context.Context, ch chan *bytes.Buffer) {
+	defer j.close()
+
+	// the events are dropped while it's unavailable
+	helper.Loop(ctx, "journald", j.logger, j.bufpool, ch, func(buf *bytes.Buffer) error {
+		j.format(j.spb.Fields(buf))
+		return j.write()
+	}, j.close)
+}
+
+// format formats the fields as a journal entry, the message
+// contains the fields as key value pairs.
+func (j *journald) format(fields []helper.Field) {
+	var msg strings.Builder
+
+	j.buffer.Reset()
+	j.buffer.Write(j.static)
+
+	for i, f := range fields {
+		writeField(j.buffer, j.name(f.Name), f.Value)
+
+		if i > 0 {
+			msg.WriteRune(' ')
+		}
+		msg.WriteString(f.Name + "=" + f.Value)
+	}
+
+	writeField(j.buffer, "MESSAGE", msg.String())
+}
+
+// write sends the entry, the entry is passed by a file descriptor
+// if it's too large for a datagram.
+func (j *journald) write() error {
+	// it's an unconnected socket which is bound by the kernel
+	if j.conn == nil {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+
+		j.conn = conn
+	}
+
+	_, err := j.conn.WriteToUnix(j.buffer.Bytes(), j.addr)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		return j.writeFd()
+	}
+
+	return err
+}
+
+func (j *journald) writeFd() error {
+	f, err := ioutil.TempFile(shmDir, "tcpdog-journal-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// it's kept open until it's passed
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+
+	if _, err := f.Write(j.buffer.Bytes()); err != nil {
+		return err
+	}
+
+	_, _, err = j.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), j.addr)
+
+	return err
+}
+
+func (j *journald) close() {
+	if j.conn != nil {
+		j.conn.Close()
+		j.conn = nil
+	}
+}
+
+// name returns the journal field name, it's uppercase and
+// it contains only letters, digits and underscores.
+func (j *journald) name(field string) string {
+	if name, ok := j.names[field]; ok {
+		return name
+	}
+
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, j.prefix+field)
+
+	j.names[field] = name
+
+	return name
+}
+
+// writeField writes a field by the native protocol, the value is
+// length-prefixed if it contains a newline.
+func writeField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteString("=" + value + "\n")
+		return
+	}
+
+	buf.WriteRune('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
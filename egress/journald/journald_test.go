@@ -0,0 +1,98 @@
+package journald
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/egresstest"
+)
+
+func TestStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpdog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ch, wg := egresstest.Start(t, Start, "journald", "memory", map[string]interface{}{"socket": socket},
+		config.Field{Name: "Task"}, config.Field{Name: "SRTT"})
+
+	ch <- bytes.NewBufferString(`{"Task":"curl","SRTT":5,"Timestamp":1609564925}`)
+
+	b := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(b)
+	assert.NoError(t, err)
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, "PRIORITY=6\n"+
+		"SYSLOG_IDENTIFIER=tcpdog\n"+
+		"TCPDOG_TRACEPOINT=sock:inet_sock_set_state\n"+
+		"TCPDOG_TASK=curl\n"+
+		"TCPDOG_SRTT=5\n"+
+		"TCPDOG_HOSTNAME="+hostname+"\n"+
+		"TCPDOG_TIMESTAMP=1609564925\n"+
+		"MESSAGE=Task=curl SRTT=5 Hostname="+hostname+" Timestamp=1609564925\n", string(b[:n]))
+
+	close(ch)
+	wg.Wait()
+}
+
+func TestWriteFd(t *testing.T) {
+	shmDir = os.TempDir()
+
+	dir, err := ioutil.TempDir("", "tcpdog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "socket"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	j := &journald{addr: addr, buffer: bytes.NewBufferString("MESSAGE=foo\n")}
+	j.conn, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	assert.NoError(t, err)
+	defer j.close()
+
+	assert.NoError(t, j.writeFd())
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	assert.NoError(t, err)
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	assert.NoError(t, err)
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	assert.NoError(t, err)
+
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+
+	f.Seek(0, 0)
+	b, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "MESSAGE=foo\n", string(b))
+}
+
+func TestWriteField(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeField(buf, "MESSAGE", "a\nb")
+	assert.Equal(t, "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n", buf.String())
+
+	j := &journald{prefix: "TCPDOG_", names: map[string]string{}}
+	assert.Equal(t, "TCPDOG_LOSS_RATE", j.name("Loss-Rate"))
+}
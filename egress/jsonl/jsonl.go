@@ -70,14 +70,14 @@ func (j *jsonl) cleanup() {
 }
 
 // Start encodes and writes tcp fields to a specific file in jsonl format
-func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
 	var (
 		j   = &jsonl{buffer: new(bytes.Buffer)}
 		err error
 	)
 
 	cfg := config.FromContext(ctx)
-	err = j.init(cfg.Egress[tp.Egress].Config, cfg.Fields[tp.Fields])
+	err = j.init(cfg.Egress[tp.Egress].Config, cfg.GetEgressOutFields(tp))
 	if err != nil {
 		return err
 	}
@@ -85,13 +85,22 @@ func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch cha
 	j.header()
 	j.flush()
 
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		defer j.cleanup()
-		var buf *bytes.Buffer
+
+		var (
+			buf *bytes.Buffer
+			ok  bool
+		)
 
 		for {
 			select {
-			case buf = <-ch:
+			case buf, ok = <-ch:
+				if !ok {
+					return
+				}
 			case <-ctx.Done():
 				return
 			}
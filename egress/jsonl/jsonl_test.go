@@ -48,7 +48,7 @@ func TestStart(t *testing.T) {
 
 	ctx = cfg.WithContext(ctx)
 
-	go Start(ctx, tp, bufPool, ch)
+	go Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 
 	b := new(bytes.Buffer)
 	b.WriteString(`{"F1":5,"F2":6,"Timestamp":1609564925}`)
@@ -74,6 +74,6 @@ func TestStart(t *testing.T) {
 		},
 	}
 	ctx = cfg.WithContext(context.Background())
-	err = Start(ctx, tp, bufPool, ch)
+	err = Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.Error(t, err)
 }
@@ -1,28 +1,39 @@
 package kafka
 
 import (
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/spool"
+	"github.com/mehrdadrad/tcpdog/sasl"
 )
 
 // Config represents Kafka configuration
 type Config struct {
 	Workers        int
-	Topic          string
+	Topic          string // template, e.g. tcpdog.{tracepoint}.{inet}
+	Key            []string
 	Brokers        []string
+	Version        string
 	Serialization  string
 	Compression    string
 	RetryMax       int
 	RequestSizeMax int32
 	RetryBackoff   int
+	RequiredAcks   string // none, leader or all
+	Idempotent     bool
 
-	SASLUsername string
-	SASLPassword string
+	SASLMechanism string // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER
+	SASLUsername  string
+	SASLPassword  string
+	SASLTokenFile string
 
 	TLSConfig config.TLSConfig
+
+	Spool spool.Config
 }
 
 func kafkaConfig(cfg map[string]interface{}) *Config {
@@ -30,9 +41,11 @@ func kafkaConfig(cfg map[string]interface{}) *Config {
 		Brokers:        []string{"localhost:9092"},
 		Topic:          "tcpdog",
 		Serialization:  "json",
+		Version:        "1.0.0",
 		RequestSizeMax: 104857600,
 		RetryMax:       3,
 		RetryBackoff:   250, // Millisecond
+		RequiredAcks:   "leader",
 		Workers:        2,
 	}
 
@@ -46,11 +59,51 @@ func kafkaConfig(cfg map[string]interface{}) *Config {
 func saramaConfig(kCfg *Config) (*sarama.Config, error) {
 	sConfig := sarama.NewConfig()
 
+	version, err := sarama.ParseKafkaVersion(kCfg.Version)
+	if err != nil {
+		return nil, err
+	}
+
 	sConfig.ClientID = "tcpdog"
+	// the record headers require kafka 0.11 and later
+	sConfig.Version = version
 	sConfig.Producer.Retry.Max = kCfg.RetryMax
 	sConfig.Producer.Retry.Backoff = time.Duration(kCfg.RetryBackoff) * time.Millisecond
+	sConfig.Producer.Return.Successes = true
+	sConfig.Producer.Return.Errors = true
 	sarama.MaxRequestSize = kCfg.RequestSizeMax
 
+	switch kCfg.RequiredAcks {
+	case "none":
+		sConfig.Producer.RequiredAcks = sarama.NoResponse
+	case "leader":
+		sConfig.Producer.RequiredAcks = sarama.WaitForLocal
+	case "all":
+		sConfig.Producer.RequiredAcks = sarama.WaitForAll
+	default:
+		return nil, fmt.Errorf("invalid required acks: %s", kCfg.RequiredAcks)
+	}
+
+	// the idempotent producer requires kafka 0.11 and later
+	if kCfg.Idempotent {
+		sConfig.Producer.Idempotent = true
+		sConfig.Producer.RequiredAcks = sarama.WaitForAll
+		sConfig.Net.MaxOpenRequests = 1
+		if sConfig.Producer.Retry.Max < 1 {
+			sConfig.Producer.Retry.Max = 1
+		}
+	}
+
+	err = sasl.Set(sConfig, sasl.Config{
+		Mechanism: kCfg.SASLMechanism,
+		Username:  kCfg.SASLUsername,
+		Password:  kCfg.SASLPassword,
+		TokenFile: kCfg.SASLTokenFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	if kCfg.TLSConfig.Enable {
 		tlsConfig, err := config.GetTLS(&kCfg.TLSConfig)
 		if err != nil {
@@ -68,6 +121,11 @@ func saramaConfig(kCfg *Config) (*sarama.Config, error) {
 		sConfig.Producer.Compression = sarama.CompressionLZ4
 	case "snappy":
 		sConfig.Producer.Compression = sarama.CompressionSnappy
+	case "zstd":
+		if !version.IsAtLeast(sarama.V2_1_0_0) {
+			return nil, fmt.Errorf("zstd compression requires kafka version 2.1.0 and later, the version is %s", kCfg.Version)
+		}
+		sConfig.Producer.Compression = sarama.CompressionZSTD
 	default:
 		sConfig.Producer.Compression = sarama.CompressionNone
 	}
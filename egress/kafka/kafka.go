@@ -3,35 +3,53 @@ package kafka
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/egress/helper"
+	"github.com/mehrdadrad/tcpdog/egress/spool"
 	pb "github.com/mehrdadrad/tcpdog/proto"
 )
 
+// spoolReplayInterval is the interval to replay the spooled messages.
+var spoolReplayInterval = 10 * time.Second
+
+var errFailing = errors.New("kafka deliveries are failing")
+
 type kafka struct {
 	producer sarama.AsyncProducer
 	bufpool  *sync.Pool
 	dCh      chan *bytes.Buffer
-	bCh      chan []byte
+	mCh      chan *sarama.ProducerMessage
 	jsonTail []byte
+	sp       *spool.Spool
+	msg      *message
+	failing  int32         // set while the deliveries fail
+	done     chan struct{} // closed once the producer has been drained
 }
 
 // Start starts producing the requested fields to kafka cluster.
-func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer) error {
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
 	var (
 		cfg  = config.FromContext(ctx)
 		kCfg = kafkaConfig(cfg.Egress[tp.Egress].Config)
 	)
 
+	switch kCfg.Serialization {
+	case "json", "spb", "pb":
+	default:
+		return fmt.Errorf("unknown serialization: %s", kCfg.Serialization)
+	}
+
 	sCfg, err := saramaConfig(kCfg)
 	if err != nil {
 		return err
@@ -47,35 +65,182 @@ func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch cha
 		dCh:     ch,
 	}
 
+	k.msg, err = newMessage(kCfg, tp, cfg.GetEgressOutFields(tp))
+	if err != nil {
+		return err
+	}
+
+	if kCfg.Spool.Path != "" {
+		k.sp, err = spool.New(&kCfg.Spool)
+		if err != nil {
+			return err
+		}
+	}
+
 	k.producer, err = sarama.NewAsyncProducer(kCfg.Brokers, sCfg)
 	if err != nil {
+		if k.sp != nil {
+			k.sp.Close()
+		}
 		return err
 	}
 
 	k.hostname()
 
+	k.done = make(chan struct{})
+	go k.consume(cfg.Logger())
+
+	wg.Add(1)
+
 	switch kCfg.Serialization {
 	case "spb":
-		k.bCh = make(chan []byte, 1000)
-		for i := 0; i < kCfg.Workers; i++ {
-			go k.workerSPB(ctx, cfg.Fields[tp.Fields])
-		}
-		k.protobufLoop(ctx, kCfg.Topic)
+		k.mCh = make(chan *sarama.ProducerMessage, 1000)
+		k.startWorkers(kCfg.Workers, func() {
+			k.workerSPB(ctx, cfg.GetEgressOutFields(tp))
+		})
+		k.protobufLoop(ctx, wg)
 
 	case "pb":
-		k.bCh = make(chan []byte, 1000)
-		for i := 0; i < kCfg.Workers; i++ {
-			go k.workerPB(ctx, cfg.Fields[tp.Fields])
-		}
-		k.protobufLoop(ctx, kCfg.Topic)
+		k.mCh = make(chan *sarama.ProducerMessage, 1000)
+		k.startWorkers(kCfg.Workers, func() {
+			k.workerPB(ctx, cfg.Derived[tp.Fields])
+		})
+		k.protobufLoop(ctx, wg)
 
 	case "json":
-		k.jsonLoop(ctx, kCfg.Topic)
+		k.jsonLoop(ctx, wg)
 	}
 
 	return nil
 }
 
+// startWorkers runs the serialization workers, the encoded channel
+// is closed once all of them returned so the producer loop can flush.
+func (k *kafka) startWorkers(n int, worker func()) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(k.mCh)
+	}()
+}
+
+// consume consumes the producer's errors and successes until the
+// producer has been closed, the failed messages have already been
+// retried by the producer so they are written to the spool.
+func (k *kafka) consume(logger *zap.Logger) {
+	defer close(k.done)
+
+	errCh, successCh := k.producer.Errors(), k.producer.Successes()
+
+	for errCh != nil || successCh != nil {
+		select {
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+
+			atomic.StoreInt32(&k.failing, 1)
+			if err.Msg != nil {
+				stats.Count(err.Msg.Topic, false)
+			}
+			k.onError(err, logger)
+
+		case msg, ok := <-successCh:
+			if !ok {
+				successCh = nil
+				continue
+			}
+
+			atomic.StoreInt32(&k.failing, 0)
+			stats.Count(msg.Topic, true)
+		}
+	}
+}
+
+// close flushes the buffered messages and closes the producer, it
+// waits until the in-flight messages are delivered or spooled.
+func (k *kafka) close() {
+	k.producer.AsyncClose()
+	<-k.done
+
+	if k.sp != nil {
+		k.sp.Close()
+	}
+}
+
+// onError logs the producer error and writes the failed message to the spool.
+func (k *kafka) onError(err *sarama.ProducerError, logger *zap.Logger) {
+	logger.Error("kafka", zap.Error(err))
+
+	if k.sp == nil || err.Msg == nil {
+		return
+	}
+
+	b, err2 := k.msg.encode(err.Msg)
+	if err2 != nil {
+		logger.Error("spool", zap.Error(err2))
+		return
+	}
+
+	if err := k.sp.Write(b); err != nil {
+		logger.Error("spool", zap.Error(err))
+	}
+}
+
+// replay produces the spooled messages in order, it sends only
+// one message while the deliveries fail to probe the broker.
+func (k *kafka) replay(logger *zap.Logger) {
+	if k.sp.Empty() {
+		return
+	}
+
+	var sent int
+
+	n, _ := k.sp.Replay(func(b []byte) error {
+		if sent > 0 && atomic.LoadInt32(&k.failing) == 1 {
+			return errFailing
+		}
+
+		msg, err := k.msg.decode(b)
+		if err != nil {
+			// skips the invalid message
+			logger.Error("spool", zap.Error(err))
+			return nil
+		}
+
+		k.producer.Input() <- msg
+		sent++
+
+		return nil
+	})
+
+	if n > 0 {
+		logger.Info("kafka", zap.String("msg", fmt.Sprintf("%d spooled messages have been replayed", n)))
+	}
+}
+
+// replayTicker returns a ticker channel to replay the spool,
+// it's nil if the spool isn't configured.
+func (k *kafka) replayTicker() (<-chan time.Time, func()) {
+	if k.sp == nil {
+		return nil, func() {}
+	}
+
+	t := time.NewTicker(spoolReplayInterval)
+
+	return t.C, t.Stop
+}
+
 // struct protobuf worker
 func (k *kafka) workerSPB(ctx context.Context, fields []config.Field) {
 	spb := helper.NewStructPB(fields)
@@ -83,7 +248,14 @@ func (k *kafka) workerSPB(ctx context.Context, fields []config.Field) {
 
 	for {
 		select {
-		case buf := <-k.dCh:
+		case buf, ok := <-k.dCh:
+			if !ok {
+				return
+			}
+
+			// unmarshal consumes the buffer
+			raw := buf.Bytes()
+
 			a := &pb.FieldsSPB{
 				Fields: spb.Unmarshal(buf),
 			}
@@ -93,7 +265,7 @@ func (k *kafka) workerSPB(ctx context.Context, fields []config.Field) {
 				logger.Error("kafka", zap.Error(err))
 			}
 
-			k.bCh <- b
+			k.mCh <- k.msg.new(raw, b)
 			k.bufpool.Put(buf)
 		case <-ctx.Done():
 			return
@@ -103,22 +275,23 @@ func (k *kafka) workerSPB(ctx context.Context, fields []config.Field) {
 }
 
 // protobuf worker
-func (k *kafka) workerPB(ctx context.Context, fields []config.Field) {
+func (k *kafka) workerPB(ctx context.Context, derived []config.DerivedField) {
 	logger := config.FromContext(ctx).Logger()
-	hostname, _ := os.Hostname()
+	p := helper.NewPB(derived)
 
 	for {
 		select {
-		case buf := <-k.dCh:
-			m := pb.Fields{}
-			protojson.Unmarshal(buf.Bytes(), &m)
-			m.Hostname = &hostname
-			b, err := proto.Marshal(&m)
+		case buf, ok := <-k.dCh:
+			if !ok {
+				return
+			}
+
+			b, err := proto.Marshal(p.Unmarshal(buf))
 			if err != nil {
 				logger.Error("kafka", zap.Error(err))
 			}
 
-			k.bCh <- b
+			k.mCh <- k.msg.new(buf.Bytes(), b)
 			k.bufpool.Put(buf)
 		case <-ctx.Done():
 			return
@@ -126,49 +299,62 @@ func (k *kafka) workerPB(ctx context.Context, fields []config.Field) {
 	}
 }
 
-func (k *kafka) jsonLoop(ctx context.Context, topic string) {
+func (k *kafka) jsonLoop(ctx context.Context, wg *sync.WaitGroup) {
 	logger := config.FromContext(ctx).Logger()
 
 	go func() {
+		defer wg.Done()
+
+		tick, stop := k.replayTicker()
+		defer stop()
+
 		for {
 			select {
-			case buf := <-k.dCh:
-				select {
-				case k.producer.Input() <- &sarama.ProducerMessage{
-					Topic: topic,
-					Value: sarama.ByteEncoder(k.addHostname(buf)),
-				}:
-				case err := <-k.producer.Errors():
-					logger.Error("kafka", zap.Error(err))
+			case buf, ok := <-k.dCh:
+				if !ok {
+					k.close()
+					return
 				}
 
+				k.producer.Input() <- k.msg.new(buf.Bytes(), k.addHostname(buf))
 				k.bufpool.Put(buf)
 
+			case <-tick:
+				k.replay(logger)
+
 			case <-ctx.Done():
+				k.close()
 				return
 			}
 		}
 	}()
 }
 
-func (k *kafka) protobufLoop(ctx context.Context, topic string) {
+func (k *kafka) protobufLoop(ctx context.Context, wg *sync.WaitGroup) {
 	logger := config.FromContext(ctx).Logger()
 
 	go func() {
+		defer wg.Done()
+
+		tick, stop := k.replayTicker()
+		defer stop()
+
 		for {
 			select {
 			//  protobuf (pb) and struct protobuf (spb) serializations
-			case b := <-k.bCh:
-				select {
-				case k.producer.Input() <- &sarama.ProducerMessage{
-					Topic: topic,
-					Value: sarama.ByteEncoder(b),
-				}:
-				case err := <-k.producer.Errors():
-					logger.Error("kafka", zap.Error(err))
+			case msg, ok := <-k.mCh:
+				if !ok {
+					k.close()
+					return
 				}
 
+				k.producer.Input() <- msg
+
+			case <-tick:
+				k.replay(logger)
+
 			case <-ctx.Done():
+				k.close()
 				return
 			}
 		}
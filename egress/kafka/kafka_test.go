@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 
@@ -31,7 +32,7 @@ func TestStartJSON(t *testing.T) {
 
 	seedBroker := sarama.NewMockBroker(t, 1)
 	defer seedBroker.Close()
-	seedBroker.Returns(new(sarama.MetadataResponse))
+	seedBroker.Returns(&sarama.MetadataResponse{Version: 5})
 
 	cfg := config.Config{
 		Egress: map[string]config.EgressConfig{
@@ -57,9 +58,10 @@ func TestStartJSON(t *testing.T) {
 		},
 	}
 
+	cfg.SetMockLogger("memory")
 	ctx = cfg.WithContext(ctx)
 
-	err := Start(ctx, tp, bufPool, ch)
+	err := Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.NoError(t, err)
 
 	data := `{"F1":5,"F2":6,"Timestamp":1609564925}`
@@ -99,7 +101,7 @@ func TestStartSPB(t *testing.T) {
 
 	seedBroker := sarama.NewMockBroker(t, 1)
 	defer seedBroker.Close()
-	seedBroker.Returns(new(sarama.MetadataResponse))
+	seedBroker.Returns(&sarama.MetadataResponse{Version: 5})
 
 	cfg := config.Config{
 		Egress: map[string]config.EgressConfig{
@@ -125,9 +127,10 @@ func TestStartSPB(t *testing.T) {
 		},
 	}
 
+	cfg.SetMockLogger("memory")
 	ctx = cfg.WithContext(ctx)
 
-	err := Start(ctx, tp, bufPool, ch)
+	err := Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.NoError(t, err)
 
 	data := `{"F1":5,"F2":6,"Timestamp":1609564925}`
@@ -157,7 +160,7 @@ func TestStartPB(t *testing.T) {
 
 	seedBroker := sarama.NewMockBroker(t, 1)
 	defer seedBroker.Close()
-	seedBroker.Returns(new(sarama.MetadataResponse))
+	seedBroker.Returns(&sarama.MetadataResponse{Version: 5})
 
 	cfg := config.Config{
 		Egress: map[string]config.EgressConfig{
@@ -183,9 +186,10 @@ func TestStartPB(t *testing.T) {
 		},
 	}
 
+	cfg.SetMockLogger("memory")
 	ctx = cfg.WithContext(ctx)
 
-	err := Start(ctx, tp, bufPool, ch)
+	err := Start(ctx, tp, bufPool, ch, new(sync.WaitGroup))
 	assert.NoError(t, err)
 
 	data := `{"F1":5,"F2":6,"Timestamp":1609564925}`
@@ -199,6 +203,29 @@ func TestStartPB(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 }
 
+func TestStartSerialization(t *testing.T) {
+	tp := config.Tracepoint{Egress: "myegress"}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"myegress": {
+				Type:   "kafka",
+				Config: map[string]interface{}{"Serialization": "foo"},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory")
+	ctx := cfg.WithContext(context.Background())
+
+	wg := new(sync.WaitGroup)
+	err := Start(ctx, tp, nil, nil, wg)
+	assert.Error(t, err)
+
+	// nothing is running
+	wg.Wait()
+}
+
 func TestWorkerSPB(t *testing.T) {
 	bufPool := &sync.Pool{
 		New: func() interface{} {
@@ -208,8 +235,9 @@ func TestWorkerSPB(t *testing.T) {
 
 	k := kafka{
 		dCh:     make(chan *bytes.Buffer, 1),
-		bCh:     make(chan []byte, 1),
+		mCh:     make(chan *sarama.ProducerMessage, 1),
 		bufpool: bufPool,
+		msg:     &message{},
 	}
 
 	cfg := config.Config{}
@@ -220,7 +248,7 @@ func TestWorkerSPB(t *testing.T) {
 
 	time.Sleep(time.Second)
 
-	b := <-k.bCh
+	b, _ := (<-k.mCh).Value.Encode()
 	spb := pb.FieldsSPB{}
 	err := proto.Unmarshal(b, &spb)
 	assert.NoError(t, err)
@@ -244,19 +272,20 @@ func TestWorkerPB(t *testing.T) {
 
 	k := kafka{
 		dCh:     make(chan *bytes.Buffer, 1),
-		bCh:     make(chan []byte, 1),
+		mCh:     make(chan *sarama.ProducerMessage, 1),
 		bufpool: bufPool,
+		msg:     &message{},
 	}
 
 	cfg := config.Config{}
 	ctx := cfg.WithContext(context.Background())
 
-	go k.workerPB(ctx, []config.Field{{Name: "RTT"}, {Name: "AdvMSS"}})
-	k.dCh <- bytes.NewBufferString(`{"RTT":5,"AdvMSS":1400,"Timestamp":1609564925}`)
+	go k.workerPB(ctx, []config.DerivedField{{Name: "LossRate"}})
+	k.dCh <- bytes.NewBufferString(`{"RTT":5,"AdvMSS":1400,"LossRate":0.25,"Timestamp":1609564925}`)
 
 	time.Sleep(time.Second)
 
-	b := <-k.bCh
+	b, _ := (<-k.mCh).Value.Encode()
 	p := pb.Fields{}
 	err := proto.Unmarshal(b, &p)
 	assert.NoError(t, err)
@@ -264,4 +293,112 @@ func TestWorkerPB(t *testing.T) {
 	assert.Equal(t, uint32(5), *p.RTT)
 	assert.Equal(t, uint32(1400), *p.AdvMSS)
 	assert.Equal(t, uint64(1609564925), *p.Timestamp)
+	assert.Equal(t, 0.25, p.Ext["LossRate"])
+}
+
+func TestMessage(t *testing.T) {
+	kCfg := &Config{
+		Topic:         "tcpdog.{tracepoint}.{inet}",
+		Key:           []string{"DAddr", "DPort"},
+		Serialization: "json",
+	}
+
+	tp := config.Tracepoint{
+		Name:   "sock:inet_sock_set_state",
+		Egress: "myegress",
+		INet:   []int{4, 6},
+	}
+
+	fields := []config.Field{{Name: "DAddr"}, {Name: "DPort"}}
+
+	m, err := newMessage(kCfg, tp, fields)
+	assert.NoError(t, err)
+
+	msg := m.new([]byte(`{"DAddr":"10.0.0.1","DPort":443,"Timestamp":1609564925}`), []byte("foo"))
+	assert.Equal(t, "tcpdog.sock_inet_sock_set_state.ipv4", msg.Topic)
+	key, _ := msg.Key.Encode()
+	assert.Equal(t, "10.0.0.1,443", string(key))
+	assert.Len(t, msg.Headers, 4)
+	assert.Equal(t, "json", string(msg.Headers[3].Value))
+
+	msg = m.new([]byte(`{"DAddr":"fe80::1","DPort":80,"Timestamp":1609564925}`), []byte("foo"))
+	assert.Equal(t, "tcpdog.sock_inet_sock_set_state.ipv6", msg.Topic)
+
+	// spool encoding
+	b, err := m.encode(msg)
+	assert.NoError(t, err)
+	msg2, err := m.decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Topic, msg2.Topic)
+	assert.Equal(t, msg.Key, msg2.Key)
+	assert.Equal(t, msg.Value, msg2.Value)
+
+	// errors
+	_, err = newMessage(kCfg, tp, nil)
+	assert.Error(t, err)
+
+	kCfg.Topic = "tcpdog.{foo}"
+	_, err = newMessage(kCfg, tp, fields)
+	assert.Error(t, err)
+}
+
+func TestSaramaConfig(t *testing.T) {
+	kCfg := kafkaConfig(map[string]interface{}{
+		"Compression":   "zstd",
+		"Version":       "2.1.0",
+		"Idempotent":    true,
+		"SASLMechanism": "SCRAM-SHA-256",
+		"SASLUsername":  "user",
+		"SASLPassword":  "pass",
+	})
+
+	sConfig, err := saramaConfig(kCfg)
+	assert.NoError(t, err)
+	assert.NoError(t, sConfig.Validate())
+	assert.Equal(t, sarama.WaitForAll, sConfig.Producer.RequiredAcks)
+	assert.Equal(t, 1, sConfig.Net.MaxOpenRequests)
+	assert.True(t, sConfig.Net.SASL.Enable)
+
+	// zstd requires kafka 2.1
+	kCfg.Version = "1.0.0"
+	_, err = saramaConfig(kCfg)
+	assert.EqualError(t, err, "zstd compression requires kafka version 2.1.0 and later, the version is 1.0.0")
+	kCfg.Version = "2.1.0"
+
+	kCfg.RequiredAcks = "foo"
+	_, err = saramaConfig(kCfg)
+	assert.Error(t, err)
+}
+
+func TestConsume(t *testing.T) {
+	sConfig := sarama.NewConfig()
+	sConfig.Producer.Return.Successes = true
+
+	producer := mocks.NewAsyncProducer(t, sConfig)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(sarama.ErrOutOfBrokers)
+
+	cfg := config.Config{}
+	cfg.SetMockLogger("memory")
+
+	k := kafka{
+		producer: producer,
+		msg:      &message{},
+		done:     make(chan struct{}),
+	}
+
+	// the counters are global, it asserts on the difference
+	before := Stats()["consume"]
+
+	go k.consume(cfg.Logger())
+
+	producer.Input() <- &sarama.ProducerMessage{Topic: "consume", Value: sarama.StringEncoder("foo")}
+	producer.Input() <- &sarama.ProducerMessage{Topic: "consume", Value: sarama.StringEncoder("bar")}
+
+	k.close()
+
+	after := Stats()["consume"]
+	assert.Equal(t, uint64(1), after.Delivered-before.Delivered)
+	assert.Equal(t, uint64(1), after.Failed-before.Failed)
+	assert.Equal(t, int32(1), k.failing)
 }
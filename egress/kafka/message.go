@@ -0,0 +1,217 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// schemaVersion is the version of the message schema
+// which is carried by the schema_version header.
+const schemaVersion = "1"
+
+var invalidTopicChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// message represents the kafka message properties,
+// the topic template and the key are resolved per event.
+type message struct {
+	topic4  string
+	topic6  string
+	inet    [][]byte // address fields to resolve {inet}
+	keys    [][]byte
+	headers []sarama.RecordHeader
+}
+
+// newMessage parses the topic template and the key fields, the template
+// supports {tracepoint}, {egress}, {hostname} and {inet} placeholders.
+func newMessage(kCfg *Config, tp config.Tracepoint, fields []config.Field) (*message, error) {
+	hostname, _ := os.Hostname()
+
+	m := &message{
+		headers: []sarama.RecordHeader{
+			{Key: []byte("hostname"), Value: []byte(hostname)},
+			{Key: []byte("tracepoint"), Value: []byte(tp.Name)},
+			{Key: []byte("schema_version"), Value: []byte(schemaVersion)},
+			{Key: []byte("serialization"), Value: []byte(kCfg.Serialization)},
+		},
+	}
+
+	captured := map[string]bool{}
+	for _, f := range fields {
+		captured[f.Name] = true
+	}
+
+	topic := strings.NewReplacer(
+		"{tracepoint}", tp.Name,
+		"{egress}", tp.Egress,
+		"{hostname}", hostname,
+	).Replace(kCfg.Topic)
+
+	m.topic4 = strings.Replace(topic, "{inet}", "ipv4", -1)
+	m.topic6 = strings.Replace(topic, "{inet}", "ipv6", -1)
+
+	if strings.Contains(topic, "{inet}") {
+		switch {
+		case len(tp.INet) == 1 && tp.INet[0] == 6:
+			m.topic4 = m.topic6
+		case len(tp.INet) == 1:
+			m.topic6 = m.topic4
+		default:
+			for _, name := range []string{"SAddr", "DAddr"} {
+				if captured[name] {
+					m.inet = append(m.inet, []byte(fmt.Sprintf(`"%s":`, name)))
+				}
+			}
+
+			if len(m.inet) < 1 {
+				return nil, errors.New("topic {inet} requires SAddr or DAddr to be captured")
+			}
+		}
+	}
+
+	if strings.ContainsAny(m.topic4, "{}") {
+		return nil, fmt.Errorf("unknown topic placeholder: %s", kCfg.Topic)
+	}
+
+	m.topic4 = invalidTopicChars.ReplaceAllString(m.topic4, "_")
+	m.topic6 = invalidTopicChars.ReplaceAllString(m.topic6, "_")
+
+	for _, name := range kCfg.Key {
+		if !captured[name] && name != "Hostname" {
+			return nil, fmt.Errorf("key field %s is not captured", name)
+		}
+
+		m.keys = append(m.keys, []byte(fmt.Sprintf(`"%s":`, name)))
+	}
+
+	return m, nil
+}
+
+// new returns a producer message, raw is the json encoded event.
+func (m *message) new(raw, value []byte) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:   m.topic(raw),
+		Value:   sarama.ByteEncoder(value),
+		Headers: m.headers,
+	}
+
+	if len(m.keys) > 0 {
+		msg.Key = sarama.ByteEncoder(m.key(raw))
+	}
+
+	return msg
+}
+
+func (m *message) topic(raw []byte) string {
+	for _, name := range m.inet {
+		if v := fieldValue(raw, name); v != nil {
+			if bytes.IndexByte(v, ':') > -1 {
+				return m.topic6
+			}
+			return m.topic4
+		}
+	}
+
+	return m.topic4
+}
+
+// key joins the key fields' values by comma.
+func (m *message) key(raw []byte) []byte {
+	var key []byte
+
+	for i, name := range m.keys {
+		if i > 0 {
+			key = append(key, ',')
+		}
+
+		if bytes.Equal(name, []byte(`"Hostname":`)) {
+			key = append(key, m.headers[0].Value...)
+			continue
+		}
+
+		key = append(key, fieldValue(raw, name)...)
+	}
+
+	return key
+}
+
+// encode encodes the message topic, key and value for the spool.
+func (m *message) encode(msg *sarama.ProducerMessage) ([]byte, error) {
+	var key, value []byte
+
+	if msg.Key != nil {
+		key, _ = msg.Key.Encode()
+	}
+
+	if msg.Value == nil {
+		return nil, errors.New("empty message")
+	}
+
+	value, err := msg.Value.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 4, 4+len(msg.Topic)+4+len(key)+len(value))
+	binary.BigEndian.PutUint32(b, uint32(len(msg.Topic)))
+	b = append(b, msg.Topic...)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(key)))
+	b = append(b, key...)
+	b = append(b, value...)
+
+	return b, nil
+}
+
+// decode decodes a spooled message.
+func (m *message) decode(b []byte) (*sarama.ProducerMessage, error) {
+	if len(b) < 4 {
+		return nil, errors.New("invalid spooled message")
+	}
+
+	n := int(binary.BigEndian.Uint32(b))
+	if len(b) < 8+n {
+		return nil, errors.New("invalid spooled message")
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:   string(b[4 : 4+n]),
+		Headers: m.headers,
+	}
+	b = b[4+n:]
+
+	n = int(binary.BigEndian.Uint32(b))
+	if len(b) < 4+n {
+		return nil, errors.New("invalid spooled message")
+	}
+
+	if n > 0 {
+		msg.Key = sarama.ByteEncoder(b[4 : 4+n])
+	}
+	msg.Value = sarama.ByteEncoder(b[4+n:])
+
+	return msg, nil
+}
+
+// fieldValue returns a field's value from the json encoded event.
+func fieldValue(raw, name []byte) []byte {
+	i := bytes.Index(raw, name)
+	if i < 0 {
+		return nil
+	}
+
+	v := raw[i+len(name):]
+	if j := bytes.IndexAny(v, ",}"); j > -1 {
+		v = v[:j]
+	}
+
+	return bytes.Trim(v, `"`)
+}
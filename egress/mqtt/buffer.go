@@ -0,0 +1,45 @@
+package mqtt
+
+// buffer holds the events while the broker isn't reachable,
+// the oldest event is dropped once it's full.
+type buffer struct {
+	items [][]byte
+	size  int
+}
+
+func newBuffer(size int) *buffer {
+	return &buffer{size: size}
+}
+
+// push appends the event, it returns false if the oldest event has been dropped.
+func (b *buffer) push(item []byte) bool {
+	if b.size < 1 {
+		return false
+	}
+
+	b.items = append(b.items, item)
+	if len(b.items) > b.size {
+		b.items[0] = nil
+		b.items = b.items[1:]
+		return false
+	}
+
+	return true
+}
+
+// unshift puts back the event at the front.
+func (b *buffer) unshift(item []byte) {
+	b.items = append([][]byte{item}, b.items...)
+}
+
+func (b *buffer) pop() []byte {
+	item := b.items[0]
+	b.items[0] = nil
+	b.items = b.items[1:]
+
+	return item
+}
+
+func (b *buffer) len() int {
+	return len(b.items)
+}
@@ -0,0 +1,88 @@
+package mqtt
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents mqtt egress configuration
+type Config struct {
+	Brokers       []string
+	Topic         string // supports {tracepoint}, {egress} and {hostname}
+	QoS           byte   // 0, 1 or 2
+	Retained      bool
+	ClientID      string // it should be unique per egress
+	Serialization string // json, pb or spb
+	KeepAlive     int    // seconds
+	AckTimeout    int    // millisecond
+	MaxPending    int    // max unacknowledged messages (qos 1 and 2)
+	BufferSize    int    // max buffered events while it's offline
+	StoreDir      string // persists the qos 1 and 2 in-flight messages if it's set
+
+	Username string
+	Password string
+
+	TLSConfig config.TLSConfig
+}
+
+// mqttConfig returns the egress configuration, the default client id
+// has a random suffix as the broker disconnects the existing client
+// with the same id.
+func mqttConfig(name string, cfg map[string]interface{}) *Config {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	c := &Config{
+		Brokers:       []string{"tcp://localhost:1883"},
+		Topic:         "tcpdog/{hostname}/{tracepoint}",
+		QoS:           1,
+		ClientID:      fmt.Sprintf("tcpdog-%s-%x", name, suffix),
+		Serialization: "json",
+		KeepAlive:     30,
+		AckTimeout:    5000,
+		MaxPending:    1000,
+		BufferSize:    10000,
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	return c
+}
+
+func clientOptions(mCfg *Config) (*paho.ClientOptions, error) {
+	opts := paho.NewClientOptions().
+		SetClientID(mCfg.ClientID).
+		SetUsername(mCfg.Username).
+		SetPassword(mCfg.Password).
+		SetKeepAlive(time.Duration(mCfg.KeepAlive) * time.Second).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute)
+
+	for _, broker := range mCfg.Brokers {
+		opts.AddBroker(broker)
+	}
+
+	if mCfg.StoreDir != "" {
+		opts.SetStore(paho.NewFileStore(mCfg.StoreDir))
+	}
+
+	if mCfg.TLSConfig.Enable {
+		tlsConfig, err := config.GetTLS(&mCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
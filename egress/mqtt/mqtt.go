@@ -0,0 +1,256 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+var (
+	invalidTopicChars = regexp.MustCompile(`[+#/\x00]`)
+	errAckTimeout     = errors.New("acknowledgement timeout")
+)
+
+// quiesce is the time in millisecond to wait for
+// the in-flight messages at disconnect.
+const quiesce = 250
+
+type mqtt struct {
+	client    paho.Client
+	topic     string
+	qos       byte
+	retained  bool
+	timeout   time.Duration
+	pending   chan struct{} // unacknowledged messages
+	buffer    *buffer       // buffered events while it's offline
+	connected chan struct{}
+	encode    func(buf *bytes.Buffer) ([]byte, error)
+	bufpool   *sync.Pool
+	logger    *zap.Logger
+}
+
+// Start starts publishing the requested fields to mqtt, the events are
+// buffered while the broker isn't reachable and they are published
+// once it has been reconnected.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg  = config.FromContext(ctx)
+		mCfg = mqttConfig(tp.Egress, cfg.Egress[tp.Egress].Config)
+		err  error
+	)
+
+	if mCfg.QoS > 2 {
+		return fmt.Errorf("qos %d is not supported", mCfg.QoS)
+	}
+
+	if mCfg.MaxPending < 1 {
+		mCfg.MaxPending = 1
+	}
+
+	m := &mqtt{
+		topic:     topic(mCfg.Topic, tp),
+		qos:       mCfg.QoS,
+		retained:  mCfg.Retained,
+		timeout:   time.Duration(mCfg.AckTimeout) * time.Millisecond,
+		pending:   make(chan struct{}, mCfg.MaxPending),
+		buffer:    newBuffer(mCfg.BufferSize),
+		connected: make(chan struct{}, 1),
+		bufpool:   bufpool,
+		logger:    cfg.Logger(),
+	}
+
+	if strings.ContainsAny(m.topic, "{}") {
+		return fmt.Errorf("unknown topic placeholder: %s", mCfg.Topic)
+	}
+
+	m.encode, err = helper.GetEncoder(mCfg.Serialization, cfg, tp)
+	if err != nil {
+		return err
+	}
+
+	opts, err := clientOptions(mCfg)
+	if err != nil {
+		return err
+	}
+
+	opts.SetOnConnectHandler(func(paho.Client) {
+		select {
+		case m.connected <- struct{}{}:
+		default:
+		}
+	})
+
+	opts.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		m.logger.Error("mqtt", zap.Error(err))
+	})
+
+	// it retries in the background until it's connected
+	m.client = paho.NewClient(opts)
+	m.client.Connect()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.loop(ctx, ch)
+	}()
+
+	return nil
+}
+
+// topic resolves the topic template.
+func topic(template string, tp config.Tracepoint) string {
+	hostname, _ := os.Hostname()
+
+	return strings.NewReplacer(
+		"{tracepoint}", invalidTopicChars.ReplaceAllString(tp.Name, "_"),
+		"{egress}", invalidTopicChars.ReplaceAllString(tp.Egress, "_"),
+		"{hostname}", invalidTopicChars.ReplaceAllString(hostname, "_"),
+	).Replace(template)
+}
+
+func (m *mqtt) loop(ctx context.Context, ch chan *bytes.Buffer) {
+	defer m.close()
+
+	for {
+		select {
+		case buf, ok := <-ch:
+			if !ok {
+				m.flush()
+				return
+			}
+
+			b, err := m.encode(buf)
+			m.bufpool.Put(buf)
+
+			if err != nil {
+				m.logger.Error("mqtt", zap.Error(err))
+				continue
+			}
+
+			m.send(b)
+
+		case <-m.connected:
+			m.drain()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send publishes the event, it buffers the event if it's offline,
+// the buffered events are published first to keep the order.
+func (m *mqtt) send(b []byte) {
+	if m.buffer.len() > 0 {
+		m.drain()
+	}
+
+	if m.buffer.len() > 0 || !m.publish(b) {
+		if !m.buffer.push(b) {
+			stats.Count(m.topic, false)
+		}
+	}
+}
+
+// drain publishes the buffered events until it's offline.
+func (m *mqtt) drain() {
+	for m.buffer.len() > 0 {
+		b := m.buffer.pop()
+		if !m.publish(b) {
+			m.buffer.unshift(b)
+			return
+		}
+	}
+}
+
+// publish publishes the event, it returns false if
+// the event hasn't been handed over to the client.
+func (m *mqtt) publish(b []byte) bool {
+	if !m.client.IsConnectionOpen() {
+		return false
+	}
+
+	// the max pending bounds the unacknowledged messages
+	m.pending <- struct{}{}
+
+	token := m.client.Publish(m.topic, m.qos, m.retained, b)
+
+	select {
+	case <-token.Done():
+		if token.Error() != nil {
+			<-m.pending
+			return false
+		}
+	default:
+	}
+
+	go func() {
+		defer func() { <-m.pending }()
+
+		if !token.WaitTimeout(m.timeout) {
+			stats.Count(m.topic, false)
+			m.logger.Error("mqtt", zap.String("topic", m.topic), zap.Error(errAckTimeout))
+			return
+		}
+
+		if err := token.Error(); err != nil {
+			stats.Count(m.topic, false)
+			m.logger.Error("mqtt", zap.String("topic", m.topic), zap.Error(err))
+			return
+		}
+
+		stats.Count(m.topic, true)
+	}()
+
+	return true
+}
+
+// flush publishes the buffered events once the channel has been closed,
+// it waits for the connection up to the ack timeout if it's offline.
+func (m *mqtt) flush() {
+	if m.buffer.len() > 0 && !m.client.IsConnectionOpen() {
+		select {
+		case <-m.connected:
+		case <-time.After(m.timeout):
+		}
+	}
+
+	m.drain()
+	m.wait()
+}
+
+// wait waits for the pending acknowledgements.
+func (m *mqtt) wait() {
+	deadline := time.After(m.timeout)
+
+	for i := 0; i < cap(m.pending); i++ {
+		select {
+		case m.pending <- struct{}{}:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func (m *mqtt) close() {
+	m.client.Disconnect(quiesce)
+
+	if n := m.buffer.len(); n > 0 {
+		for i := 0; i < n; i++ {
+			stats.Count(m.topic, false)
+		}
+
+		m.logger.Warn("mqtt", zap.String("topic", m.topic), zap.Int("dropped", n))
+	}
+}
@@ -0,0 +1,130 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/mqtt/mqtttest"
+)
+
+func TestStart(t *testing.T) {
+	for _, qos := range []byte{1, 2} {
+		testStart(t, qos)
+	}
+}
+
+func testStart(t *testing.T, qos byte) {
+	broker, err := mqtttest.NewBroker()
+	assert.NoError(t, err)
+	defer broker.Close()
+
+	name := fmt.Sprintf("qos%d", qos)
+
+	tp := config.Tracepoint{
+		Name:   "sock:inet_sock_set_state",
+		Egress: name,
+		Fields: "myfields",
+	}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			name: {
+				Type: "mqtt",
+				Config: map[string]interface{}{
+					"brokers": []string{broker.URL()},
+					"topic":   "tcpdog/{egress}/{tracepoint}",
+					"qos":     qos,
+				},
+			},
+		},
+		Fields: map[string][]config.Field{
+			"myfields": {{Name: "F1"}},
+		},
+	}
+
+	cfg.SetMockLogger("memory" + name)
+	ctx := cfg.WithContext(context.Background())
+
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	ch := make(chan *bytes.Buffer, 2)
+	wg := new(sync.WaitGroup)
+
+	// the counters are global, it asserts on the difference
+	topic := "tcpdog/" + name + "/sock:inet_sock_set_state"
+	before := Stats()[topic]
+
+	err = Start(ctx, tp, bufPool, ch, wg)
+	assert.NoError(t, err)
+
+	// the events are buffered until it's connected
+	ch <- bytes.NewBufferString(`{"F1":5,"Timestamp":1609564925}`)
+	ch <- bytes.NewBufferString(`{"F1":6,"Timestamp":1609564926}`)
+	close(ch)
+	wg.Wait()
+
+	msgs := broker.Messages()
+	assert.Len(t, msgs, 2)
+
+	for i, msg := range msgs {
+		m := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(msg.Payload, &m))
+		hostname, _ := os.Hostname()
+		assert.Equal(t, hostname, m["Hostname"])
+		assert.Equal(t, float64(5+i), m["F1"])
+		assert.Equal(t, topic, msg.Topic)
+		assert.Equal(t, qos, msg.QoS)
+	}
+
+	after := Stats()[topic]
+	assert.Equal(t, uint64(2), after.Delivered-before.Delivered)
+	assert.Equal(t, uint64(0), after.Failed-before.Failed)
+
+	// qos 3 is not defined
+	cfg.Egress[name].Config["qos"] = 3
+	assert.Error(t, Start(ctx, tp, bufPool, make(chan *bytes.Buffer), wg))
+}
+
+func TestConfig(t *testing.T) {
+	c1 := mqttConfig("foo", nil)
+	c2 := mqttConfig("foo", nil)
+	assert.True(t, regexp.MustCompile("^tcpdog-foo-[0-9a-f]{8}$").MatchString(c1.ClientID))
+	assert.NotEqual(t, c1.ClientID, c2.ClientID)
+
+	c1 = mqttConfig("foo", map[string]interface{}{"clientid": "bar"})
+	assert.Equal(t, "bar", c1.ClientID)
+}
+
+func TestTopic(t *testing.T) {
+	assert.Equal(t, "tcpdog/foo_bar_/myfields", topic("tcpdog/{egress}/myfields", config.Tracepoint{Egress: "foo/bar#"}))
+}
+
+func TestBuffer(t *testing.T) {
+	b := newBuffer(2)
+
+	assert.True(t, b.push([]byte("1")))
+	assert.True(t, b.push([]byte("2")))
+	assert.False(t, b.push([]byte("3")))
+	assert.Equal(t, 2, b.len())
+
+	assert.Equal(t, []byte("2"), b.pop())
+	b.unshift([]byte("2"))
+	assert.Equal(t, []byte("2"), b.pop())
+	assert.Equal(t, []byte("3"), b.pop())
+	assert.Equal(t, 0, b.len())
+
+	assert.False(t, newBuffer(0).push([]byte("1")))
+}
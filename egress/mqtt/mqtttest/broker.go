@@ -0,0 +1,245 @@
+// Package mqtttest provides an in-memory MQTT broker for testing, it
+// supports the MQTT 3.1.1 qos 0, 1 and 2 flows without persistent sessions.
+package mqtttest
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// Message represents a published message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+}
+
+// Broker represents a test broker.
+type Broker struct {
+	l       net.Listener
+	mu      sync.Mutex
+	clients map[*client]bool
+	msgs    []Message
+	acks    int
+}
+
+type client struct {
+	conn  net.Conn
+	wmu   sync.Mutex
+	subs  map[string]byte
+	msgID uint16
+}
+
+// NewBroker starts a test broker on a random local port.
+func NewBroker() (*Broker, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broker{
+		l:       l,
+		clients: map[*client]bool{},
+	}
+
+	go b.accept()
+
+	return b, nil
+}
+
+// URL returns the broker url.
+func (b *Broker) URL() string {
+	return "tcp://" + b.l.Addr().String()
+}
+
+// Messages returns the published messages.
+func (b *Broker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message{}, b.msgs...)
+}
+
+// Acks returns the number of the delivered messages
+// which have been acknowledged by the subscribers.
+func (b *Broker) Acks() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.acks
+}
+
+// Publish publishes a message to the subscribers.
+func (b *Broker) Publish(topic string, qos byte, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.route(topic, qos, payload)
+}
+
+// Close stops the broker and closes the connections.
+func (b *Broker) Close() {
+	b.l.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.clients {
+		c.conn.Close()
+	}
+}
+
+func (b *Broker) accept() {
+	for {
+		conn, err := b.l.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &client{conn: conn, subs: map[string]byte{}}
+
+		b.mu.Lock()
+		b.clients[c] = true
+		b.mu.Unlock()
+
+		go b.serve(c)
+	}
+}
+
+func (b *Broker) serve(c *client) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+
+		c.conn.Close()
+	}()
+
+	for {
+		cp, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return
+		}
+
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			ack.ReturnCode = packets.Accepted
+			c.write(ack)
+		case *packets.PublishPacket:
+			b.mu.Lock()
+			b.msgs = append(b.msgs, Message{Topic: p.TopicName, Payload: p.Payload, QoS: p.Qos})
+			b.route(p.TopicName, p.Qos, p.Payload)
+			b.mu.Unlock()
+
+			switch p.Qos {
+			case 1:
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				c.write(ack)
+			case 2:
+				rec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				rec.MessageID = p.MessageID
+				c.write(rec)
+			}
+		case *packets.PubackPacket:
+			b.mu.Lock()
+			b.acks++
+			b.mu.Unlock()
+		case *packets.PubrecPacket:
+			b.mu.Lock()
+			b.acks++
+			b.mu.Unlock()
+
+			rel := packets.NewControlPacket(packets.Pubrel).(*packets.PubrelPacket)
+			rel.MessageID = p.MessageID
+			c.write(rel)
+		case *packets.PubrelPacket:
+			comp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			comp.MessageID = p.MessageID
+			c.write(comp)
+		case *packets.SubscribePacket:
+			b.mu.Lock()
+			for i, topic := range p.Topics {
+				c.subs[topic] = p.Qoss[i]
+			}
+			b.mu.Unlock()
+
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			c.write(ack)
+		case *packets.UnsubscribePacket:
+			b.mu.Lock()
+			for _, topic := range p.Topics {
+				delete(c.subs, topic)
+			}
+			b.mu.Unlock()
+
+			ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			ack.MessageID = p.MessageID
+			c.write(ack)
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// route delivers the message to the subscribers by the min qos.
+func (b *Broker) route(topic string, qos byte, payload []byte) {
+	for c := range b.clients {
+		for filter, subQoS := range c.subs {
+			if !match(filter, topic) {
+				continue
+			}
+
+			p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+			p.TopicName = topic
+			p.Payload = payload
+			p.Qos = qos
+			if subQoS < qos {
+				p.Qos = subQoS
+			}
+
+			if p.Qos > 0 {
+				c.msgID++
+				p.MessageID = c.msgID
+			}
+
+			c.write(p)
+
+			break
+		}
+	}
+}
+
+func (c *client) write(p packets.ControlPacket) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	p.Write(c.conn)
+}
+
+// match returns true if the topic matches the filter,
+// the filter supports + and # wildcards.
+func match(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+
+	return len(f) == len(t)
+}
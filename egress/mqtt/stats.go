@@ -0,0 +1,11 @@
+package mqtt
+
+import "github.com/mehrdadrad/tcpdog/egress/helper"
+
+// stats represents the delivery counters per topic.
+var stats helper.Stats
+
+// Stats returns the delivery counters per topic.
+func Stats() map[string]helper.Counters {
+	return stats.Get()
+}
@@ -0,0 +1,83 @@
+package nats
+
+import (
+	"log"
+	"time"
+
+	natsio "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents nats egress configuration
+type Config struct {
+	Servers       []string
+	Subject       string // supports {tracepoint}, {egress} and {hostname}
+	Serialization string // json, pb or spb
+	JetStream     bool   // waits for the stream acknowledgements
+	AckTimeout    int    // millisecond
+	MaxPending    int    // max unacknowledged messages (jetstream)
+
+	Username  string
+	Password  string
+	Token     string
+	CredsFile string
+
+	TLSConfig config.TLSConfig
+}
+
+func natsConfig(cfg map[string]interface{}) *Config {
+	c := &Config{
+		Servers:       []string{"nats://localhost:4222"},
+		Subject:       "tcpdog.{tracepoint}",
+		Serialization: "json",
+		AckTimeout:    5000,
+		MaxPending:    1000,
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	return c
+}
+
+// clientOptions returns the connection options, the client reconnects
+// in the background and it keeps retrying if the first connect fails.
+func clientOptions(nCfg *Config, logger *zap.Logger) ([]natsio.Option, error) {
+	opts := []natsio.Option{
+		natsio.Name("tcpdog"),
+		natsio.Timeout(time.Duration(nCfg.AckTimeout) * time.Millisecond),
+		natsio.MaxReconnects(-1),
+		natsio.RetryOnFailedConnect(true),
+		natsio.DisconnectErrHandler(func(_ *natsio.Conn, err error) {
+			if err != nil {
+				logger.Error("nats", zap.Error(err))
+			}
+		}),
+	}
+
+	if nCfg.Username != "" {
+		opts = append(opts, natsio.UserInfo(nCfg.Username, nCfg.Password))
+	}
+
+	if nCfg.Token != "" {
+		opts = append(opts, natsio.Token(nCfg.Token))
+	}
+
+	if nCfg.CredsFile != "" {
+		opts = append(opts, natsio.UserCredentials(nCfg.CredsFile))
+	}
+
+	if nCfg.TLSConfig.Enable {
+		tlsConfig, err := config.GetTLS(&nCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, natsio.Secure(tlsConfig))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,199 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	natsio "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+var invalidSubjectChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+type nats struct {
+	conn    *natsio.Conn
+	js      jetstream.JetStream
+	subject string
+	timeout time.Duration
+	futures chan jetstream.PubAckFuture // unacknowledged messages (jetstream)
+	done    chan struct{}               // closed once the acknowledgements have been counted
+	expired chan struct{}               // closed once the ack timeout exceeded at shutdown
+	encode  func(buf *bytes.Buffer) ([]byte, error)
+	bufpool *sync.Pool
+	logger  *zap.Logger
+}
+
+// Start starts publishing the requested fields to nats, the messages
+// are published to a jetstream subject and acknowledged if it's enabled.
+// the client reconnects in the background, the messages are buffered
+// while it's reconnecting.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg  = config.FromContext(ctx)
+		nCfg = natsConfig(cfg.Egress[tp.Egress].Config)
+		err  error
+	)
+
+	if nCfg.MaxPending < 1 {
+		nCfg.MaxPending = 1
+	}
+
+	n := &nats{
+		subject: subject(nCfg.Subject, tp),
+		timeout: time.Duration(nCfg.AckTimeout) * time.Millisecond,
+		bufpool: bufpool,
+		logger:  cfg.Logger(),
+	}
+
+	if strings.ContainsAny(n.subject, "{}") {
+		return fmt.Errorf("unknown subject placeholder: %s", nCfg.Subject)
+	}
+
+	n.encode, err = helper.GetEncoder(nCfg.Serialization, cfg, tp)
+	if err != nil {
+		return err
+	}
+
+	opts, err := clientOptions(nCfg, n.logger)
+	if err != nil {
+		return err
+	}
+
+	n.conn, err = natsio.Connect(strings.Join(nCfg.Servers, ","), opts...)
+	if err != nil {
+		return err
+	}
+
+	if nCfg.JetStream {
+		n.js, err = jetstream.New(n.conn)
+		if err != nil {
+			n.conn.Close()
+			return err
+		}
+
+		n.futures = make(chan jetstream.PubAckFuture, nCfg.MaxPending)
+		n.done = make(chan struct{})
+		n.expired = make(chan struct{})
+
+		go n.wait()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.loop(ctx, ch)
+	}()
+
+	return nil
+}
+
+// subject resolves the subject template.
+func subject(template string, tp config.Tracepoint) string {
+	hostname, _ := os.Hostname()
+
+	return strings.NewReplacer(
+		"{tracepoint}", invalidSubjectChars.ReplaceAllString(tp.Name, "_"),
+		"{egress}", invalidSubjectChars.ReplaceAllString(tp.Egress, "_"),
+		"{hostname}", invalidSubjectChars.ReplaceAllString(hostname, "_"),
+	).Replace(template)
+}
+
+func (n *nats) loop(ctx context.Context, ch chan *bytes.Buffer) {
+	defer n.close()
+
+	// the events are dropped while it's failing
+	helper.Loop(ctx, "nats", n.logger, n.bufpool, ch, func(buf *bytes.Buffer) error {
+		b, err := n.encode(buf)
+		if err != nil {
+			n.logger.Error("nats", zap.Error(err))
+			return nil
+		}
+
+		if err := n.publish(b); err != nil {
+			stats.Count(n.subject, false)
+			return err
+		}
+
+		return nil
+	}, nil)
+}
+
+// publish publishes the message, the jetstream acknowledgement
+// is counted once it has been received.
+func (n *nats) publish(b []byte) error {
+	// core nats doesn't acknowledge, it's counted once it's written
+	if n.js == nil {
+		if err := n.conn.Publish(n.subject, b); err != nil {
+			return err
+		}
+
+		stats.Count(n.subject, true)
+
+		return nil
+	}
+
+	f, err := n.js.PublishAsync(n.subject, b)
+	if err != nil {
+		return err
+	}
+
+	// the max pending bounds the unacknowledged messages
+	n.futures <- f
+
+	return nil
+}
+
+// wait counts the jetstream acknowledgements in order.
+func (n *nats) wait() {
+	defer close(n.done)
+
+	for f := range n.futures {
+		n.ack(f)
+	}
+}
+
+// ack waits for an acknowledgement up to the ack timeout.
+func (n *nats) ack(f jetstream.PubAckFuture) {
+	timer := time.NewTimer(n.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-f.Ok():
+		stats.Count(n.subject, true)
+	case err := <-f.Err():
+		stats.Count(n.subject, false)
+		n.logger.Error("nats", zap.Error(err))
+	case <-timer.C:
+		stats.Count(n.subject, false)
+		n.logger.Error("nats", zap.Error(natsio.ErrTimeout))
+	case <-n.expired:
+		stats.Count(n.subject, false)
+	}
+}
+
+// close waits for the pending acknowledgements up to the ack timeout,
+// the unacknowledged messages are counted as failed once it's exceeded.
+func (n *nats) close() {
+	if n.js != nil {
+		close(n.futures)
+
+		select {
+		case <-n.done:
+		case <-time.After(n.timeout):
+			close(n.expired)
+			<-n.done
+		}
+	}
+
+	n.conn.Close()
+}
@@ -0,0 +1,84 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/nats/natstest"
+)
+
+func TestStart(t *testing.T) {
+	srv, err := natstest.NewServer()
+	assert.NoError(t, err)
+	defer srv.Close()
+
+	assert.NoError(t, srv.AddStream("TCPDOG", "tcpdog.>"))
+
+	tp := config.Tracepoint{
+		Name:   "sock:inet_sock_set_state",
+		Egress: "myegress",
+		Fields: "myfields",
+	}
+
+	cfg := config.Config{
+		Egress: map[string]config.EgressConfig{
+			"myegress": {
+				Type: "nats",
+				Config: map[string]interface{}{
+					"servers":   []string{srv.URL()},
+					"jetstream": true,
+				},
+			},
+		},
+		Fields: map[string][]config.Field{
+			"myfields": {{Name: "F1"}},
+		},
+	}
+
+	cfg.SetMockLogger("memory")
+	ctx := cfg.WithContext(context.Background())
+
+	bufPool := &sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+
+	ch := make(chan *bytes.Buffer, 2)
+	wg := new(sync.WaitGroup)
+
+	// the counters are global, it asserts on the difference
+	before := Stats()["tcpdog.sock_inet_sock_set_state"]
+
+	err = Start(ctx, tp, bufPool, ch, wg)
+	assert.NoError(t, err)
+
+	ch <- bytes.NewBufferString(`{"F1":5,"Timestamp":1609564925}`)
+	ch <- bytes.NewBufferString(`{"F1":6,"Timestamp":1609564926}`)
+	close(ch)
+	wg.Wait()
+
+	msgs := srv.Messages("TCPDOG")
+	assert.Len(t, msgs, 2)
+
+	m := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(msgs[0], &m))
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, m["Hostname"])
+	assert.Equal(t, float64(5), m["F1"])
+
+	after := Stats()["tcpdog.sock_inet_sock_set_state"]
+	assert.Equal(t, uint64(2), after.Delivered-before.Delivered)
+	assert.Equal(t, uint64(0), after.Failed-before.Failed)
+}
+
+func TestSubject(t *testing.T) {
+	assert.Equal(t, "tcpdog.foo_bar.myfields", subject("tcpdog.{egress}.myfields", config.Tracepoint{Egress: "foo bar"}))
+}
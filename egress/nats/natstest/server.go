@@ -0,0 +1,143 @@
+// Package natstest provides an embedded NATS server
+// with JetStream enabled for testing.
+package natstest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Server represents a test server.
+type Server struct {
+	srv *server.Server
+	dir string
+}
+
+// NewServer starts a test server on a random local port.
+func NewServer() (*Server, error) {
+	dir, err := ioutil.TempDir("", "natstest")
+	if err != nil {
+		return nil, err
+	}
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  dir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	go srv.Start()
+
+	if !srv.ReadyForConnections(5 * time.Second) {
+		srv.Shutdown()
+		os.RemoveAll(dir)
+		return nil, errors.New("natstest: server is not ready")
+	}
+
+	return &Server{srv: srv, dir: dir}, nil
+}
+
+// URL returns the server url.
+func (s *Server) URL() string {
+	return s.srv.ClientURL()
+}
+
+// Close stops the server and removes its storage.
+func (s *Server) Close() {
+	s.srv.Shutdown()
+	s.srv.WaitForShutdown()
+	os.RemoveAll(s.dir)
+}
+
+// AddStream adds a stream with the subjects.
+func (s *Server) AddStream(name string, subjects ...string) error {
+	return s.jetstream(func(ctx context.Context, js jetstream.JetStream) error {
+		_, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: name, Subjects: subjects})
+		return err
+	})
+}
+
+// Messages returns the stored messages of a stream.
+func (s *Server) Messages(name string) [][]byte {
+	var msgs [][]byte
+
+	s.jetstream(func(ctx context.Context, js jetstream.JetStream) error {
+		stream, err := js.Stream(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		info, err := stream.Info(ctx)
+		if err != nil {
+			return err
+		}
+
+		for seq := info.State.FirstSeq; seq > 0 && seq <= info.State.LastSeq; seq++ {
+			m, err := stream.GetMsg(ctx, seq)
+			if err != nil {
+				return err
+			}
+
+			msgs = append(msgs, m.Data)
+		}
+
+		return nil
+	})
+
+	return msgs
+}
+
+// Acked returns the number of the acknowledged messages of a consumer.
+func (s *Server) Acked(stream, consumer string) int {
+	var acked int
+
+	s.jetstream(func(ctx context.Context, js jetstream.JetStream) error {
+		c, err := js.Consumer(ctx, stream, consumer)
+		if err != nil {
+			return err
+		}
+
+		info, err := c.Info(ctx)
+		if err != nil {
+			return err
+		}
+
+		acked = int(info.Delivered.Consumer) - info.NumAckPending
+
+		return nil
+	})
+
+	return acked
+}
+
+func (s *Server) jetstream(fn func(ctx context.Context, js jetstream.JetStream) error) error {
+	conn, err := natsio.Connect(s.URL())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return fn(ctx, js)
+}
@@ -0,0 +1,11 @@
+package nats
+
+import "github.com/mehrdadrad/tcpdog/egress/helper"
+
+// stats represents the delivery counters per subject.
+var stats helper.Stats
+
+// Stats returns the delivery counters per subject.
+func Stats() map[string]helper.Counters {
+	return stats.Get()
+}
@@ -0,0 +1,77 @@
+package otlp
+
+import (
+	"log"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents otlp egress configuration
+type Config struct {
+	Endpoint    string // host:port (grpc) or url (http)
+	Protocol    string // grpc or http
+	Signal      string // logs or metrics
+	Headers     map[string]string
+	Compression string      // gzip
+	Timeout     int         // millisecond
+	Interval    int         // millisecond, the export interval
+	BatchSize   int         // max log records per export
+	Attributes  []string    // metrics attributes, e.g. DPort and Task
+	Histograms  []Histogram // metrics
+
+	TLSConfig config.TLSConfig
+}
+
+// Histogram represents a histogram of a field.
+type Histogram struct {
+	Name   string
+	Field  string
+	Unit   string
+	Bounds []float64
+}
+
+var defaultEndpoint = map[string]string{
+	"grpc": "localhost:4317",
+	"http": "http://localhost:4318",
+}
+
+// defaultHistograms are used if there isn't any configured histogram.
+var defaultHistograms = []Histogram{
+	{
+		Name:   "tcpdog.rtt",
+		Field:  "RTT",
+		Unit:   "us",
+		Bounds: []float64{1000, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000},
+	},
+	{
+		Name:   "tcpdog.retransmits",
+		Field:  "TotalRetrans",
+		Unit:   "1",
+		Bounds: []float64{0, 1, 2, 5, 10, 25, 50, 100},
+	},
+}
+
+func otlpConfig(cfg map[string]interface{}) *Config {
+	c := &Config{
+		Protocol:   "grpc",
+		Signal:     "logs",
+		Timeout:    10000,
+		Interval:   5000,
+		BatchSize:  512,
+		Attributes: []string{"DPort"},
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	if c.Histograms == nil {
+		c.Histograms = defaultHistograms
+	}
+
+	if c.Endpoint == "" {
+		c.Endpoint = defaultEndpoint[c.Protocol]
+	}
+
+	return c
+}
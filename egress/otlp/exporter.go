@@ -0,0 +1,206 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+	"google.golang.org/grpc/metadata"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+var (
+	grpcMethods = map[string]string{
+		"logs":    "/opentelemetry.proto.collector.logs.v1.LogsService/Export",
+		"metrics": "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export",
+	}
+	httpPaths = map[string]string{
+		"logs":    "/v1/logs",
+		"metrics": "/v1/metrics",
+	}
+)
+
+// exporter sends an encoded export request to the collector.
+type exporter interface {
+	export(ctx context.Context, req []byte) error
+	close()
+}
+
+func newExporter(oCfg *Config) (exporter, error) {
+	switch oCfg.Protocol {
+	case "grpc":
+		return newGRPCExporter(oCfg)
+	case "http":
+		return newHTTPExporter(oCfg)
+	}
+
+	return nil, fmt.Errorf("unknown protocol: %s", oCfg.Protocol)
+}
+
+// rawCodec passes the encoded messages through.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = data
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+type grpcExporter struct {
+	conn   *grpc.ClientConn
+	method string
+	md     metadata.MD
+	opts   []grpc.CallOption
+}
+
+func newGRPCExporter(oCfg *Config) (*grpcExporter, error) {
+	var opts []grpc.DialOption
+
+	if oCfg.TLSConfig.Enable {
+		creds, err := config.GetCreds(&oCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	callOpts := []grpc.CallOption{grpc.ForceCodec(rawCodec{})}
+
+	switch oCfg.Compression {
+	case "":
+	case "gzip":
+		callOpts = append(callOpts, grpc.UseCompressor("gzip"))
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", oCfg.Compression)
+	}
+
+	// it connects in the background
+	conn, err := grpc.Dial(oCfg.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcExporter{
+		conn:   conn,
+		method: grpcMethods[oCfg.Signal],
+		md:     metadata.New(oCfg.Headers),
+		opts:   callOpts,
+	}, nil
+}
+
+func (g *grpcExporter) export(ctx context.Context, req []byte) error {
+	var resp []byte
+
+	ctx = metadata.NewOutgoingContext(ctx, g.md)
+
+	return g.conn.Invoke(ctx, g.method, &req, &resp, g.opts...)
+}
+
+func (g *grpcExporter) close() {
+	g.conn.Close()
+}
+
+type httpExporter struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	gzip    bool
+}
+
+func newHTTPExporter(oCfg *Config) (*httpExporter, error) {
+	h := &httpExporter{
+		client:  &http.Client{},
+		url:     strings.TrimSuffix(oCfg.Endpoint, "/") + httpPaths[oCfg.Signal],
+		headers: oCfg.Headers,
+	}
+
+	if !strings.Contains(oCfg.Endpoint, "://") {
+		if oCfg.TLSConfig.Enable {
+			h.url = "https://" + h.url
+		} else {
+			h.url = "http://" + h.url
+		}
+	}
+
+	switch oCfg.Compression {
+	case "":
+	case "gzip":
+		h.gzip = true
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", oCfg.Compression)
+	}
+
+	if oCfg.TLSConfig.Enable {
+		tlsConfig, err := config.GetTLS(&oCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		h.client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	return h, nil
+}
+
+func (h *httpExporter) export(ctx context.Context, req []byte) error {
+	body := req
+
+	if h.gzip {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(req)
+		w.Close()
+		body = buf.Bytes()
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	if h.gzip {
+		r.Header.Set("Content-Encoding", "gzip")
+	}
+
+	for k, v := range h.headers {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: %s: %s", resp.Status, b)
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+func (h *httpExporter) close() {
+	h.client.CloseIdleConnections()
+}
@@ -0,0 +1,145 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+type otlp struct {
+	exporter exporter
+	signal   signal
+	timeout  time.Duration
+	interval time.Duration
+	spb      *helper.StructPB
+	bufpool  *sync.Pool
+	logger   *zap.Logger
+}
+
+// Start starts exporting the requested fields to an OpenTelemetry collector,
+// the events are exported as the log records or they are aggregated as the
+// histogram metrics.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg    = config.FromContext(ctx)
+		oCfg   = otlpConfig(cfg.Egress[tp.Egress].Config)
+		fields = cfg.GetEgressOutFields(tp)
+		err    error
+	)
+
+	if oCfg.BatchSize < 1 {
+		oCfg.BatchSize = 1
+	}
+
+	if oCfg.Interval < 1 {
+		return fmt.Errorf("invalid interval: %d", oCfg.Interval)
+	}
+
+	o := &otlp{
+		timeout:  time.Duration(oCfg.Timeout) * time.Millisecond,
+		interval: time.Duration(oCfg.Interval) * time.Millisecond,
+		spb:      helper.NewStructPB(fields),
+		bufpool:  bufpool,
+		logger:   cfg.Logger(),
+	}
+
+	hostname, _ := os.Hostname()
+	res := resource(
+		"host.name", hostname,
+		"service.name", "tcpdog",
+		"service.version", cfg.Version(),
+		"tcpdog.tracepoint", tp.Name,
+	)
+	scp := scope("tcpdog", cfg.Version())
+
+	switch oCfg.Signal {
+	case "logs":
+		o.signal = &logs{
+			res:       res,
+			scp:       scp,
+			body:      tp.Name,
+			names:     o.spb.Names(),
+			batchSize: oCfg.BatchSize,
+		}
+	case "metrics":
+		var names []string
+		for _, f := range fields {
+			names = append(names, f.Name)
+		}
+
+		o.signal, err = newMetrics(res, scp, oCfg.Attributes, oCfg.Histograms, names)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown signal: %s", oCfg.Signal)
+	}
+
+	o.exporter, err = newExporter(oCfg)
+	if err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.loop(ctx, ch)
+	}()
+
+	return nil
+}
+
+func (o *otlp) loop(ctx context.Context, ch chan *bytes.Buffer) {
+	ticker := time.NewTicker(o.interval)
+
+	defer func() {
+		ticker.Stop()
+		o.exporter.close()
+	}()
+
+	for {
+		select {
+		case buf, ok := <-ch:
+			if !ok {
+				o.export()
+				return
+			}
+
+			o.signal.add(o.spb.Unmarshal(buf))
+			o.bufpool.Put(buf)
+
+			if o.signal.full() {
+				o.export()
+			}
+
+		case <-ticker.C:
+			o.export()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// export exports the collected events, the events are
+// dropped if the collector isn't available.
+func (o *otlp) export() {
+	req := o.signal.request()
+	if req == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	if err := o.exporter.export(ctx, req); err != nil {
+		o.logger.Error("otlp", zap.Error(err))
+	}
+}
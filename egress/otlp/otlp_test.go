@@ -0,0 +1,210 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/egresstest"
+)
+
+// lookup returns the values of the field path, the
+// intermediate fields are the embedded messages.
+func lookup(b []byte, path ...protowire.Number) [][]byte {
+	var values [][]byte
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(b)
+			v = b[:n]
+		case protowire.Fixed64Type:
+			v, n = b[:8], 8
+		default:
+			panic("unexpected wire type")
+		}
+		b = b[n:]
+
+		if num != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			values = append(values, v)
+			continue
+		}
+
+		values = append(values, lookup(v, path[1:]...)...)
+	}
+
+	return values
+}
+
+// attributes decodes the KeyValues as strings and integers.
+func attributes(kvs [][]byte) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, kv := range kvs {
+		key := string(lookup(kv, 1)[0])
+		if s := lookup(kv, 2, 1); len(s) > 0 {
+			m[key] = string(s[0])
+			continue
+		}
+
+		v, _ := protowire.ConsumeVarint(lookup(kv, 2, 3)[0])
+		m[key] = int64(v)
+	}
+
+	return m
+}
+
+func fixed64(b []byte) uint64 {
+	v, _ := protowire.ConsumeFixed64(b)
+	return v
+}
+
+func start(t *testing.T, scheme string, conf map[string]interface{}, events ...string) {
+	ch, wg := egresstest.Start(t, Start, "otlp", scheme, conf,
+		config.Field{Name: "Task"}, config.Field{Name: "DPort"}, config.Field{Name: "RTT"})
+	egresstest.Send(ch, wg, events...)
+}
+
+func TestStartLogs(t *testing.T) {
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+
+		zr, err := gzip.NewReader(r.Body)
+		assert.NoError(t, err)
+		body, _ = ioutil.ReadAll(zr)
+	}))
+	defer srv.Close()
+
+	start(t, "memory", map[string]interface{}{
+		"protocol":    "http",
+		"endpoint":    srv.URL,
+		"compression": "gzip",
+		"headers":     map[string]string{"X-Foo": "bar"},
+	},
+		`{"Task":"curl","DPort":443,"RTT":5000,"Timestamp":1609564925}`,
+		`{"Task":"wget","DPort":80,"RTT":7500,"Timestamp":1609564926}`,
+	)
+
+	res := attributes(lookup(body, 1, 1, 1))
+	assert.Equal(t, "tcpdog", res["service.name"])
+	assert.Equal(t, "sock:inet_sock_set_state", res["tcpdog.tracepoint"])
+	assert.Contains(t, res, "host.name")
+
+	records := lookup(body, 1, 2, 2)
+	assert.Len(t, records, 2)
+
+	assert.Equal(t, uint64(1609564925*time.Second), fixed64(lookup(records[0], 1)[0]))
+	assert.Equal(t, "sock:inet_sock_set_state", string(lookup(records[0], 5, 1)[0]))
+
+	attrs := attributes(lookup(records[0], 6))
+	assert.Equal(t, "curl", attrs["Task"])
+	assert.Equal(t, int64(443), attrs["DPort"])
+	assert.Equal(t, int64(5000), attrs["RTT"])
+}
+
+// serverCodec passes the raw request through at the test server.
+type serverCodec struct{ rawCodec }
+
+func (serverCodec) String() string {
+	return "proto"
+}
+
+func TestStartMetrics(t *testing.T) {
+	var (
+		method string
+		body   []byte
+	)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := grpc.NewServer(
+		grpc.CustomCodec(serverCodec{}),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			method, _ = grpc.MethodFromServerStream(stream)
+			if err := stream.RecvMsg(&body); err != nil {
+				return err
+			}
+			return stream.SendMsg(&[]byte{})
+		}),
+	)
+	go server.Serve(l)
+	defer server.Stop()
+
+	start(t, "memory1", map[string]interface{}{
+		"endpoint": l.Addr().String(),
+		"signal":   "metrics",
+	},
+		`{"Task":"curl","DPort":443,"RTT":5000,"Timestamp":1609564925}`,
+		`{"Task":"curl","DPort":443,"RTT":7500,"Timestamp":1609564926}`,
+		`{"Task":"wget","DPort":80,"RTT":120,"Timestamp":1609564926}`,
+	)
+
+	assert.Equal(t, grpcMethods["metrics"], method)
+
+	// the retransmits histogram is skipped, its field isn't available
+	metrics := lookup(body, 1, 2, 2)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "tcpdog.rtt", string(lookup(metrics[0], 1)[0]))
+
+	points := lookup(metrics[0], 9, 1)
+	assert.Len(t, points, 2)
+
+	for _, p := range points {
+		attrs := attributes(lookup(p, 9))
+		assert.Len(t, attrs, 1)
+
+		counts := lookup(p, 6)[0]
+		switch attrs["DPort"] {
+		case int64(443):
+			assert.Equal(t, uint64(2), fixed64(lookup(p, 4)[0]))
+			assert.Equal(t, 12500.0, math.Float64frombits(fixed64(lookup(p, 5)[0])))
+			// 5000 <= 5000, 5000 < 7500 <= 10000
+			assert.Equal(t, uint64(1), fixed64(counts[8:16]))
+			assert.Equal(t, uint64(1), fixed64(counts[16:24]))
+		case int64(80):
+			assert.Equal(t, uint64(1), fixed64(lookup(p, 4)[0]))
+			assert.Equal(t, uint64(1), fixed64(counts[0:8]))
+		default:
+			t.Fatal("unexpected attributes")
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := otlpConfig(map[string]interface{}{"protocol": "http"})
+	assert.Equal(t, "http://localhost:4318", c.Endpoint)
+	assert.Len(t, c.Histograms, 2)
+
+	c = otlpConfig(map[string]interface{}{
+		"histograms": []map[string]interface{}{{"name": "foo", "field": "SRTT"}},
+	})
+	assert.Equal(t, "localhost:4317", c.Endpoint)
+	assert.Equal(t, []Histogram{{Name: "foo", Field: "SRTT"}}, c.Histograms)
+
+	_, err := newMetrics(nil, nil, nil, c.Histograms, []string{"RTT"})
+	assert.Error(t, err)
+}
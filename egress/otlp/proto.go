@@ -0,0 +1,157 @@
+package otlp
+
+import (
+	"math"
+
+	pbstruct "github.com/golang/protobuf/ptypes/struct"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The OTLP messages are encoded by the wire format directly, the field
+// numbers follow opentelemetry-proto v1.0.0.
+
+const (
+	severityInfo     = 9 // SEVERITY_NUMBER_INFO
+	temporalityDelta = 1 // AGGREGATION_TEMPORALITY_DELTA
+)
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	return appendFixed64(b, num, math.Float64bits(v))
+}
+
+// anyValue encodes an AnyValue, the integral numbers are encoded as int.
+func anyValue(v *pbstruct.Value) []byte {
+	switch k := v.GetKind().(type) {
+	case *pbstruct.Value_StringValue:
+		return appendString(nil, 1, k.StringValue)
+	case *pbstruct.Value_NumberValue:
+		n := k.NumberValue
+		if n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+			return appendVarint(nil, 3, uint64(int64(n)))
+		}
+		return appendDouble(nil, 4, n)
+	}
+
+	return nil
+}
+
+// keyValue encodes a KeyValue.
+func keyValue(key string, value []byte) []byte {
+	b := appendString(nil, 1, key)
+	return appendMessage(b, 2, value)
+}
+
+// resource encodes a Resource by the key value pairs.
+func resource(pairs ...string) []byte {
+	var b []byte
+	for i := 0; i+1 < len(pairs); i += 2 {
+		b = appendMessage(b, 1, keyValue(pairs[i], appendString(nil, 1, pairs[i+1])))
+	}
+
+	return b
+}
+
+// scope encodes an InstrumentationScope.
+func scope(name, version string) []byte {
+	b := appendString(nil, 1, name)
+	return appendString(b, 2, version)
+}
+
+// logRecord encodes a LogRecord, the attributes are encoded KeyValues.
+func logRecord(ts, observed uint64, body string, attrs [][]byte) []byte {
+	b := appendFixed64(nil, 1, ts)
+	b = appendVarint(b, 2, severityInfo)
+	b = appendString(b, 3, "INFO")
+	b = appendMessage(b, 5, appendString(nil, 1, body))
+	for _, attr := range attrs {
+		b = appendMessage(b, 6, attr)
+	}
+
+	return appendFixed64(b, 11, observed)
+}
+
+// logsRequest encodes an ExportLogsServiceRequest.
+func logsRequest(res, scp []byte, records [][]byte) []byte {
+	sl := appendMessage(nil, 1, scp)
+	for _, r := range records {
+		sl = appendMessage(sl, 2, r)
+	}
+
+	rl := appendMessage(nil, 1, res)
+	rl = appendMessage(rl, 2, sl)
+
+	return appendMessage(nil, 1, rl)
+}
+
+// histogramPoint encodes a HistogramDataPoint, the attributes are
+// already encoded as the repeated attributes field.
+func histogramPoint(attrs []byte, start, ts uint64, p *point, bounds []float64) []byte {
+	b := appendFixed64(nil, 2, start)
+	b = appendFixed64(b, 3, ts)
+	b = appendFixed64(b, 4, p.count)
+	b = appendDouble(b, 5, p.sum)
+
+	var counts []byte
+	for _, c := range p.counts {
+		counts = protowire.AppendFixed64(counts, c)
+	}
+	b = appendMessage(b, 6, counts)
+
+	var explicit []byte
+	for _, bound := range bounds {
+		explicit = protowire.AppendFixed64(explicit, math.Float64bits(bound))
+	}
+	b = appendMessage(b, 7, explicit)
+
+	b = append(b, attrs...)
+	b = appendDouble(b, 11, p.min)
+
+	return appendDouble(b, 12, p.max)
+}
+
+// histogramMetric encodes a Metric which carries a delta Histogram.
+func histogramMetric(name, unit string, points [][]byte) []byte {
+	var h []byte
+	for _, p := range points {
+		h = appendMessage(h, 1, p)
+	}
+	h = appendVarint(h, 2, temporalityDelta)
+
+	b := appendString(nil, 1, name)
+	b = appendString(b, 3, unit)
+
+	return appendMessage(b, 9, h)
+}
+
+// metricsRequest encodes an ExportMetricsServiceRequest.
+func metricsRequest(res, scp []byte, metrics [][]byte) []byte {
+	sm := appendMessage(nil, 1, scp)
+	for _, m := range metrics {
+		sm = appendMessage(sm, 2, m)
+	}
+
+	rm := appendMessage(nil, 1, res)
+	rm = appendMessage(rm, 2, sm)
+
+	return appendMessage(nil, 1, rm)
+}
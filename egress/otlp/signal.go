@@ -0,0 +1,180 @@
+package otlp
+
+import (
+	"fmt"
+	"time"
+
+	pbstruct "github.com/golang/protobuf/ptypes/struct"
+)
+
+// signal collects the events and encodes them as an export request.
+type signal interface {
+	add(r *pbstruct.Struct)
+	full() bool
+	// request returns the export request and resets
+	// the signal, it returns nil if it's empty.
+	request() []byte
+}
+
+type logs struct {
+	res, scp  []byte
+	body      string
+	names     []string
+	batchSize int
+	records   [][]byte
+}
+
+func (l *logs) add(r *pbstruct.Struct) {
+	attrs := make([][]byte, 0, len(l.names))
+	for _, name := range l.names {
+		if v, ok := r.Fields[name]; ok {
+			attrs = append(attrs, keyValue(name, anyValue(v)))
+		}
+	}
+
+	ts := uint64(r.Fields["Timestamp"].GetNumberValue()) * uint64(time.Second)
+	l.records = append(l.records, logRecord(ts, uint64(time.Now().UnixNano()), l.body, attrs))
+}
+
+func (l *logs) full() bool {
+	return len(l.records) >= l.batchSize
+}
+
+func (l *logs) request() []byte {
+	if len(l.records) < 1 {
+		return nil
+	}
+
+	req := logsRequest(l.res, l.scp, l.records)
+	l.records = l.records[:0]
+
+	return req
+}
+
+// metrics aggregates the histograms per the attributes
+// values, the histograms are exported as delta.
+type metrics struct {
+	res, scp   []byte
+	attributes []string
+	histograms []Histogram
+	series     map[string][]*point
+	start      time.Time
+}
+
+type point struct {
+	counts        []uint64
+	count         uint64
+	sum, min, max float64
+}
+
+func newMetrics(res, scp []byte, attributes []string, histograms []Histogram, fields []string) (*metrics, error) {
+	available := map[string]bool{}
+	for _, name := range fields {
+		available[name] = true
+	}
+
+	m := &metrics{
+		res:        res,
+		scp:        scp,
+		attributes: attributes,
+		series:     map[string][]*point{},
+		start:      time.Now(),
+	}
+
+	// the histograms of the unavailable fields are skipped
+	for _, h := range histograms {
+		if available[h.Field] {
+			m.histograms = append(m.histograms, h)
+		}
+	}
+
+	if len(m.histograms) < 1 {
+		return nil, fmt.Errorf("there isn't any histogram field in the fields")
+	}
+
+	return m, nil
+}
+
+func (m *metrics) add(r *pbstruct.Struct) {
+	// the encoded data point attributes are the series key
+	var attrs []byte
+	for _, name := range m.attributes {
+		if v, ok := r.Fields[name]; ok {
+			attrs = appendMessage(attrs, 9, keyValue(name, anyValue(v)))
+		}
+	}
+
+	points, ok := m.series[string(attrs)]
+	if !ok {
+		points = make([]*point, len(m.histograms))
+		m.series[string(attrs)] = points
+	}
+
+	for i, h := range m.histograms {
+		v, ok := r.Fields[h.Field]
+		if !ok {
+			continue
+		}
+
+		if points[i] == nil {
+			points[i] = &point{counts: make([]uint64, len(h.Bounds)+1)}
+		}
+
+		points[i].observe(v.GetNumberValue(), h.Bounds)
+	}
+}
+
+func (p *point) observe(v float64, bounds []float64) {
+	i := 0
+	for i < len(bounds) && v > bounds[i] {
+		i++
+	}
+
+	p.counts[i]++
+
+	if p.count == 0 || v < p.min {
+		p.min = v
+	}
+
+	if p.count == 0 || v > p.max {
+		p.max = v
+	}
+
+	p.count++
+	p.sum += v
+}
+
+func (m *metrics) full() bool {
+	return false
+}
+
+func (m *metrics) request() []byte {
+	if len(m.series) < 1 {
+		return nil
+	}
+
+	var (
+		now     = time.Now()
+		start   = uint64(m.start.UnixNano())
+		ts      = uint64(now.UnixNano())
+		metrics [][]byte
+	)
+
+	for i, h := range m.histograms {
+		var points [][]byte
+		for attrs, p := range m.series {
+			if p[i] != nil {
+				points = append(points, histogramPoint([]byte(attrs), start, ts, p[i], h.Bounds))
+			}
+		}
+
+		if len(points) > 0 {
+			metrics = append(metrics, histogramMetric(h.Name, h.Unit, points))
+		}
+	}
+
+	m.series = map[string][]*point{}
+	m.start = now
+
+	return metricsRequest(m.res, m.scp, metrics)
+}
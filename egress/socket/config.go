@@ -0,0 +1,34 @@
+package socket
+
+import (
+	"log"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents socket egress configuration
+type Config struct {
+	Unix         string // unix socket path
+	Mode         string // unix socket file mode
+	TCP          string // tcp listen address
+	Framing      string // json, csv or pb, the subscribers can switch it
+	QueueSize    int    // events per subscriber
+	SlowConsumer string // disconnect or drop
+	WriteTimeout int    // millisecond, zero disables it
+}
+
+func socketConfig(cfg map[string]interface{}) *Config {
+	c := &Config{
+		Mode:         "0600",
+		Framing:      "json",
+		QueueSize:    1000,
+		SlowConsumer: "disconnect",
+		WriteTimeout: 5000,
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	return c
+}
@@ -0,0 +1,412 @@
+package socket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+var framings = []string{"json", "csv", "pb"}
+
+type hub struct {
+	mu        sync.Mutex
+	subs      map[*subscriber]bool
+	listeners []net.Listener
+	closed    bool
+
+	framing    string // default framing
+	queueSize  int
+	disconnect bool // disconnects the slow consumers otherwise drops their events
+	timeout    time.Duration
+	encoders   map[string]func(raw []byte) ([]byte, error)
+	csvHeader  []byte
+
+	wg      sync.WaitGroup // subscribers' writers
+	logger  *zap.Logger
+	bufpool *sync.Pool
+}
+
+type subscriber struct {
+	conn    net.Conn
+	queue   chan []byte
+	framing string
+	dropped uint64
+}
+
+// Start starts serving the events to the subscribers of a unix socket and/or
+// a tcp listener, each subscriber has its own queue and framing. a subscriber
+// can switch the framing by sending json, csv or pb as a line.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg  = config.FromContext(ctx)
+		sCfg = socketConfig(cfg.Egress[tp.Egress].Config)
+		err  error
+	)
+
+	h := &hub{
+		subs:      map[*subscriber]bool{},
+		framing:   sCfg.Framing,
+		queueSize: sCfg.QueueSize,
+		timeout:   time.Duration(sCfg.WriteTimeout) * time.Millisecond,
+		logger:    cfg.Logger(),
+		bufpool:   bufpool,
+	}
+
+	switch sCfg.SlowConsumer {
+	case "disconnect":
+		h.disconnect = true
+	case "drop":
+	default:
+		return fmt.Errorf("unknown slow consumer policy: %s", sCfg.SlowConsumer)
+	}
+
+	if !validFraming(h.framing) {
+		return fmt.Errorf("unknown framing: %s", h.framing)
+	}
+
+	if h.queueSize < 1 {
+		h.queueSize = 1
+	}
+
+	h.encoders, h.csvHeader, err = encoders(cfg, tp)
+	if err != nil {
+		return err
+	}
+
+	if sCfg.Unix == "" && sCfg.TCP == "" {
+		return fmt.Errorf("there isn't any unix socket or tcp address")
+	}
+
+	if sCfg.Unix != "" {
+		if err := h.listenUnix(sCfg.Unix, sCfg.Mode); err != nil {
+			return err
+		}
+	}
+
+	if sCfg.TCP != "" {
+		if err := h.listen("tcp", sCfg.TCP); err != nil {
+			h.closeListeners()
+			return err
+		}
+	}
+
+	for _, l := range h.listeners {
+		go h.accept(l)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.loop(ctx, ch)
+	}()
+
+	return nil
+}
+
+// encoders returns the framing encoders and the csv header, the
+// json is newline delimited and the pb is varint length-prefixed.
+func encoders(cfg *config.Config, tp config.Tracepoint) (map[string]func([]byte) ([]byte, error), []byte, error) {
+	jsonEncode, err := helper.GetEncoder("json", cfg, tp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pbEncode, err := helper.GetEncoder("pb", cfg, tp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spb := helper.NewStructPB(cfg.GetEgressOutFields(tp))
+
+	header := csvLine(append(append([]string{}, spb.Names()...), "Hostname", "Timestamp"))
+
+	return map[string]func([]byte) ([]byte, error){
+		"json": func(raw []byte) ([]byte, error) {
+			b, err := jsonEncode(bytes.NewBuffer(raw))
+			return append(b, '\n'), err
+		},
+		"csv": func(raw []byte) ([]byte, error) {
+			var values []string
+			for _, f := range spb.Fields(bytes.NewBuffer(raw)) {
+				values = append(values, f.Value)
+			}
+			return csvLine(values), nil
+		},
+		"pb": func(raw []byte) ([]byte, error) {
+			b, err := pbEncode(bytes.NewBuffer(raw))
+			if err != nil {
+				return nil, err
+			}
+
+			prefix := make([]byte, binary.MaxVarintLen64)
+			n := binary.PutUvarint(prefix, uint64(len(b)))
+			return append(prefix[:n], b...), nil
+		},
+	}, header, nil
+}
+
+func csvLine(values []string) []byte {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	w.Write(values)
+	w.Flush()
+
+	return buf.Bytes()
+}
+
+func validFraming(framing string) bool {
+	for _, f := range framings {
+		if f == framing {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *hub) listen(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+
+	h.listeners = append(h.listeners, l)
+
+	return nil
+}
+
+// listenUnix listens on the unix socket with the file mode, a stale
+// socket is removed but any other file or a live socket is kept.
+func (h *hub) listenUnix(path, mode string) error {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode: %s", mode)
+	}
+
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and it's not a socket", path)
+		}
+
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return fmt.Errorf("%s is in use", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if err := h.listen("unix", path); err != nil {
+		return err
+	}
+
+	// the listener isn't accepting yet
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		h.closeListeners()
+		return err
+	}
+
+	return nil
+}
+
+func (h *hub) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		h.subscribe(conn)
+	}
+}
+
+func (h *hub) subscribe(conn net.Conn) {
+	s := &subscriber{
+		conn:    conn,
+		queue:   make(chan []byte, h.queueSize),
+		framing: h.framing,
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		conn.Close()
+		return
+	}
+
+	h.subs[s] = true
+	if s.framing == "csv" {
+		s.queue <- h.csvHeader
+	}
+
+	h.wg.Add(1)
+	h.mu.Unlock()
+
+	h.logger.Info("socket", zap.String("subscriber", conn.RemoteAddr().String()), zap.String("msg", "subscribed"))
+
+	go func() {
+		defer h.wg.Done()
+		h.write(s)
+	}()
+
+	go h.read(s)
+}
+
+// read reads the framing requests until the subscriber disconnects.
+func (h *hub) read(s *subscriber) {
+	scanner := bufio.NewScanner(s.conn)
+
+	for scanner.Scan() {
+		framing := strings.TrimSpace(scanner.Text())
+		if !validFraming(framing) {
+			continue
+		}
+
+		h.mu.Lock()
+		if h.subs[s] && s.framing != framing {
+			s.framing = framing
+			if framing == "csv" {
+				h.enqueue(s, h.csvHeader)
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.unsubscribe(s, true)
+}
+
+// write writes the queued events until the queue is closed.
+func (h *hub) write(s *subscriber) {
+	defer s.conn.Close()
+
+	for b := range s.queue {
+		if h.timeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(h.timeout))
+		}
+
+		if _, err := s.conn.Write(b); err != nil {
+			h.unsubscribe(s, true)
+			return
+		}
+	}
+}
+
+// unsubscribe removes the subscriber and closes its queue, the
+// connection is closed immediately if it's requested otherwise
+// it's closed once the queued events have been written.
+func (h *hub) unsubscribe(s *subscriber, closeConn bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(s, closeConn)
+}
+
+func (h *hub) remove(s *subscriber, closeConn bool) {
+	if !h.subs[s] {
+		return
+	}
+
+	delete(h.subs, s)
+	close(s.queue)
+
+	if closeConn {
+		s.conn.Close()
+	}
+
+	h.logger.Info("socket", zap.String("subscriber", s.conn.RemoteAddr().String()),
+		zap.String("msg", "unsubscribed"), zap.Uint64("dropped", s.dropped))
+}
+
+// enqueue queues the event, the slow consumer is disconnected
+// or the event is dropped if its queue is full.
+func (h *hub) enqueue(s *subscriber, b []byte) {
+	select {
+	case s.queue <- b:
+	default:
+		s.dropped++
+		if h.disconnect {
+			h.remove(s, true)
+		}
+	}
+}
+
+// broadcast encodes the event once per the framing and queues it.
+func (h *hub) broadcast(raw []byte) {
+	encoded := map[string][]byte{}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		b, ok := encoded[s.framing]
+		if !ok {
+			var err error
+			b, err = h.encoders[s.framing](raw)
+			if err != nil {
+				h.logger.Error("socket", zap.Error(err))
+				continue
+			}
+
+			encoded[s.framing] = b
+		}
+
+		h.enqueue(s, b)
+	}
+}
+
+func (h *hub) loop(ctx context.Context, ch chan *bytes.Buffer) {
+	for {
+		select {
+		case buf, ok := <-ch:
+			if !ok {
+				h.close(false)
+				return
+			}
+
+			h.broadcast(buf.Bytes())
+			h.bufpool.Put(buf)
+
+		case <-ctx.Done():
+			h.close(true)
+			return
+		}
+	}
+}
+
+// close stops the listeners and removes the subscribers, the queued
+// events are written unless it's requested to close immediately.
+func (h *hub) close(immediate bool) {
+	h.closeListeners()
+
+	h.mu.Lock()
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s, immediate)
+	}
+	h.mu.Unlock()
+
+	h.wg.Wait()
+}
+
+func (h *hub) closeListeners() {
+	for _, l := range h.listeners {
+		l.Close()
+	}
+}
@@ -0,0 +1,225 @@
+package socket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/egresstest"
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+var events = []string{
+	`{"Task":"curl","DPort":443,"Timestamp":1609564925}`,
+	`{"Task":"wget","DPort":80,"Timestamp":1609564926}`,
+}
+
+func start(t *testing.T, scheme string, conf map[string]interface{}) (chan *bytes.Buffer, *sync.WaitGroup) {
+	return egresstest.Start(t, Start, "socket", scheme, conf, config.Field{Name: "Task"}, config.Field{Name: "DPort"})
+}
+
+// send sends the events and waits for the egress to drain them.
+func send(ch chan *bytes.Buffer, wg *sync.WaitGroup) {
+	// waits for the subscribers registration
+	time.Sleep(100 * time.Millisecond)
+
+	egresstest.Send(ch, wg, events...)
+}
+
+func TestStartUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpdog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tcpdog.sock")
+
+	ch, wg := start(t, "memory", map[string]interface{}{"unix": path, "mode": "0640"})
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	send(ch, wg)
+
+	b, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"Task":"curl"`)
+	assert.Contains(t, lines[0], `"Hostname":`)
+	assert.Contains(t, lines[1], `"Task":"wget"`)
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpdog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	h := &hub{}
+
+	// a regular file is kept
+	path := filepath.Join(dir, "tcpdog.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("foo"), 0644))
+	assert.Error(t, h.listenUnix(path, "0600"))
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+
+	// a live socket is kept
+	path = filepath.Join(dir, "tcpdog.sock")
+	l, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	assert.Error(t, h.listenUnix(path, "0600"))
+
+	// a stale socket is replaced
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	assert.NoError(t, h.listenUnix(path, "0600"))
+	h.closeListeners()
+
+	assert.Error(t, h.listenUnix(path, "rw"))
+}
+
+func TestStartTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	ch, wg := start(t, "memory1", map[string]interface{}{"tcp": addr})
+
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("csv\n"))
+	assert.NoError(t, err)
+
+	send(ch, wg)
+
+	b, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "Task,DPort,Hostname,Timestamp", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "curl,443,"))
+	assert.True(t, strings.HasSuffix(lines[2], ",1609564926"))
+}
+
+func TestStartPB(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	ch, wg := start(t, "memory2", map[string]interface{}{"tcp": addr, "framing": "pb"})
+
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	send(ch, wg)
+
+	r := bufio.NewReader(conn)
+
+	var tasks []string
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			break
+		}
+
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		assert.NoError(t, err)
+
+		m := &pb.Fields{}
+		assert.NoError(t, proto.Unmarshal(b, m))
+		tasks = append(tasks, m.GetTask())
+	}
+
+	assert.Equal(t, []string{"curl", "wget"}, tasks)
+}
+
+func TestSlowConsumer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	h := &hub{
+		subs:       map[*subscriber]bool{},
+		framing:    "json",
+		queueSize:  1,
+		disconnect: true,
+		encoders: map[string]func([]byte) ([]byte, error){
+			"json": func(raw []byte) ([]byte, error) { return raw, nil },
+		},
+		logger: zap.NewNop(),
+	}
+
+	s := &subscriber{conn: server, queue: make(chan []byte, 1), framing: "json"}
+	h.subs[s] = true
+
+	h.broadcast([]byte("foo"))
+	assert.Len(t, h.subs, 1)
+
+	// the queue is full and there isn't any writer
+	h.broadcast([]byte("bar"))
+	assert.Len(t, h.subs, 0)
+	assert.Equal(t, uint64(1), s.dropped)
+
+	// the drop policy keeps the subscriber
+	h.disconnect = false
+	s = &subscriber{conn: server, queue: make(chan []byte, 1), framing: "json"}
+	h.subs[s] = true
+
+	h.broadcast([]byte("foo"))
+	h.broadcast([]byte("bar"))
+	assert.Len(t, h.subs, 1)
+	assert.Equal(t, uint64(1), s.dropped)
+}
+
+func TestConfig(t *testing.T) {
+	c := socketConfig(map[string]interface{}{"unix": "/tmp/tcpdog.sock"})
+	assert.Equal(t, "/tmp/tcpdog.sock", c.Unix)
+	assert.Equal(t, "json", c.Framing)
+	assert.Equal(t, 1000, c.QueueSize)
+	assert.Equal(t, "disconnect", c.SlowConsumer)
+	assert.Equal(t, "0600", c.Mode)
+}
+
+func TestWriteNoTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	h := &hub{subs: map[*subscriber]bool{}, logger: zap.NewNop()}
+	s := &subscriber{conn: server, queue: make(chan []byte, 1)}
+	h.subs[s] = true
+
+	s.queue <- []byte("foo")
+	close(s.queue)
+
+	go h.write(s)
+
+	b, err := ioutil.ReadAll(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+}
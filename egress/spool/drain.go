@@ -0,0 +1,66 @@
+package spool
+
+import (
+	"bytes"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Drainer writes the egress channel to the spool while
+// the destination is unreachable.
+type Drainer struct {
+	stop   chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// Drain writes the channel's buffers to the spool in background
+// until it's stopped or the channel is closed.
+func (s *Spool) Drain(ch chan *bytes.Buffer, bufpool *sync.Pool, logger *zap.Logger) *Drainer {
+	d := &Drainer{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(d.done)
+
+		for {
+			select {
+			case buf, ok := <-ch:
+				if !ok {
+					d.closed = true
+					return
+				}
+
+				if err := s.Write(buf.Bytes()); err != nil {
+					logger.Error("spool", zap.Error(err))
+				}
+
+				bufpool.Put(buf)
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+
+	return d
+}
+
+// Stop stops draining and returns true if the channel has been closed.
+func (d *Drainer) Stop() bool {
+	select {
+	case <-d.done:
+	default:
+		close(d.stop)
+		<-d.done
+	}
+
+	return d.closed
+}
+
+// Done returns a channel that's closed once the drainer returned.
+func (d *Drainer) Done() <-chan struct{} {
+	return d.done
+}
@@ -0,0 +1,372 @@
+package spool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSize = 16 << 20
+	headerSize  = 16 // length, crc32 and timestamp
+	cursorFile  = "cursor"
+	segmentExt  = ".spool"
+)
+
+// Config represents spool configuration.
+type Config struct {
+	Path    string
+	MaxSize int // megabytes
+	MaxAge  int // seconds
+}
+
+// Spool represents an on-disk write-ahead spool, the records are
+// appended to the segment files and replayed in the same order.
+type Spool struct {
+	sync.Mutex
+
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+
+	segments []*segment
+	w        *os.File // the last segment
+	r        *os.File // the first segment
+	rOff     int64
+	size     int64
+	nextID   uint64
+	dropped  uint64
+}
+
+type segment struct {
+	id      uint64
+	size    int64
+	modTime time.Time
+}
+
+// New opens or creates a spool at the configured path.
+func New(cfg *Config) (*Spool, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("spool path is not configured")
+	}
+
+	if err := os.MkdirAll(cfg.Path, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{
+		path:    cfg.Path,
+		maxSize: int64(cfg.MaxSize) << 20,
+		maxAge:  time.Duration(cfg.MaxAge) * time.Second,
+		nextID:  1,
+	}
+
+	if s.maxSize <= 0 {
+		s.maxSize = 512 << 20
+	}
+
+	if s.maxAge <= 0 {
+		s.maxAge = 24 * time.Hour
+	}
+
+	files, err := ioutil.ReadDir(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		s.segments = append(s.segments, &segment{id: id, size: f.Size(), modTime: f.ModTime()})
+		s.size += f.Size()
+
+		if id >= s.nextID {
+			s.nextID = id + 1
+		}
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].id < s.segments[j].id
+	})
+
+	// replay position
+	if b, err := ioutil.ReadFile(filepath.Join(s.path, cursorFile)); err == nil && len(s.segments) > 0 {
+		var id uint64
+		var off int64
+		if _, err := fmt.Sscanf(string(b), "%d %d", &id, &off); err == nil &&
+			id == s.segments[0].id && off <= s.segments[0].size {
+			s.rOff = off
+		}
+	}
+
+	return s, nil
+}
+
+// Write appends a record to the spool, the oldest segments are removed
+// once the spool exceeds its size or age limit.
+func (s *Spool) Write(b []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	n := int64(headerSize + len(b))
+
+	if s.w == nil || s.last().size+n > segmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	rec := make([]byte, n)
+	binary.BigEndian.PutUint32(rec, uint32(len(b)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(b))
+	binary.BigEndian.PutUint64(rec[8:], uint64(time.Now().UnixNano()))
+	copy(rec[headerSize:], b)
+
+	if _, err := s.w.Write(rec); err != nil {
+		return err
+	}
+
+	s.last().size += n
+	s.last().modTime = time.Now()
+	s.size += n
+
+	s.expire()
+
+	return nil
+}
+
+// Replay reads the records in order and calls fn for each of them until
+// the spool is empty, the record remains in the spool if fn fails.
+func (s *Spool) Replay(fn func(b []byte) error) (int, error) {
+	var count int
+
+	defer s.saveCursor()
+
+	for {
+		b, next, err := s.read()
+		if err != nil {
+			return count, err
+		}
+
+		if next < 0 {
+			return count, nil
+		}
+
+		if b != nil {
+			if err := fn(b); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		s.Lock()
+		s.rOff = next
+		s.Unlock()
+	}
+}
+
+// Empty returns true if there is no record to replay.
+func (s *Spool) Empty() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.segments) == 0 || (len(s.segments) == 1 && s.rOff >= s.segments[0].size)
+}
+
+// Size returns the spool size in bytes.
+func (s *Spool) Size() int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.size
+}
+
+// Dropped returns the number of segments which have been
+// removed because of the size or age limit.
+func (s *Spool) Dropped() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.dropped
+}
+
+// Close syncs and closes the spool files.
+func (s *Spool) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.r != nil {
+		s.r.Close()
+		s.r = nil
+	}
+
+	if s.w != nil {
+		s.w.Sync()
+		s.w.Close()
+		s.w = nil
+	}
+
+	return s.writeCursor()
+}
+
+// read returns the next record and its end offset, the end offset is -1
+// if there isn't any record, a nil record with a valid offset means the
+// record should be skipped (expired or corrupted).
+func (s *Spool) read() ([]byte, int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+
+		if s.rOff >= seg.size {
+			if len(s.segments) == 1 && s.w != nil {
+				// caught up with the writer, starts over
+				if seg.size > 0 {
+					s.w.Close()
+					s.w = nil
+					s.remove()
+				}
+				return nil, -1, nil
+			}
+
+			s.remove()
+			continue
+		}
+
+		if s.r == nil {
+			f, err := os.Open(s.segmentPath(seg.id))
+			if err != nil {
+				s.remove()
+				continue
+			}
+			s.r = f
+		}
+
+		hdr := make([]byte, headerSize)
+		if _, err := s.r.ReadAt(hdr, s.rOff); err != nil {
+			// truncated segment
+			s.rOff = seg.size
+			continue
+		}
+
+		var (
+			n   = int64(binary.BigEndian.Uint32(hdr))
+			sum = binary.BigEndian.Uint32(hdr[4:])
+			ts  = time.Unix(0, int64(binary.BigEndian.Uint64(hdr[8:])))
+			end = s.rOff + headerSize + n
+		)
+
+		if end > seg.size {
+			s.rOff = seg.size
+			continue
+		}
+
+		b := make([]byte, n)
+		if _, err := s.r.ReadAt(b, s.rOff+headerSize); err != nil || crc32.ChecksumIEEE(b) != sum {
+			// corrupted segment
+			s.rOff = seg.size
+			continue
+		}
+
+		if time.Since(ts) > s.maxAge {
+			return nil, end, nil
+		}
+
+		return b, end, nil
+	}
+
+	return nil, -1, nil
+}
+
+// remove removes the first segment.
+func (s *Spool) remove() {
+	if s.r != nil {
+		s.r.Close()
+		s.r = nil
+	}
+
+	seg := s.segments[0]
+	os.Remove(s.segmentPath(seg.id))
+
+	s.size -= seg.size
+	s.segments = s.segments[1:]
+	s.rOff = 0
+}
+
+// expire removes the oldest segments which exceed the limits,
+// the last segment is never removed.
+func (s *Spool) expire() {
+	for len(s.segments) > 1 {
+		seg := s.segments[0]
+		if s.size <= s.maxSize && time.Since(seg.modTime) <= s.maxAge {
+			return
+		}
+
+		s.remove()
+		s.dropped++
+	}
+}
+
+func (s *Spool) rotate() error {
+	if s.w != nil {
+		s.w.Sync()
+		s.w.Close()
+		s.w = nil
+	}
+
+	id := s.nextID
+	s.nextID++
+
+	f, err := os.OpenFile(s.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	s.w = f
+	s.segments = append(s.segments, &segment{id: id, modTime: time.Now()})
+
+	return nil
+}
+
+func (s *Spool) last() *segment {
+	return s.segments[len(s.segments)-1]
+}
+
+func (s *Spool) segmentPath(id uint64) string {
+	return filepath.Join(s.path, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+func (s *Spool) saveCursor() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.writeCursor()
+}
+
+func (s *Spool) writeCursor() error {
+	var b bytes.Buffer
+
+	if len(s.segments) > 0 {
+		fmt.Fprintf(&b, "%d %d", s.segments[0].id, s.rOff)
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.path, cursorFile), b.Bytes(), 0644)
+}
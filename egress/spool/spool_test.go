@@ -0,0 +1,151 @@
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWriteReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := New(&Config{Path: dir})
+	assert.NoError(t, err)
+	assert.True(t, s.Empty())
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, s.Write([]byte(fmt.Sprintf(`{"F1":%d}`, i))))
+	}
+	assert.False(t, s.Empty())
+
+	// fails at the 5th record
+	var records []string
+	n, err := s.Replay(func(b []byte) error {
+		if len(records) == 5 {
+			return errors.New("unavailable")
+		}
+		records = append(records, string(b))
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 5, n)
+	assert.NoError(t, s.Close())
+
+	// reopen, it continues from the 5th record
+	s, err = New(&Config{Path: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write([]byte(`{"F1":10}`)))
+
+	n, err = s.Replay(func(b []byte) error {
+		records = append(records, string(b))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.True(t, s.Empty())
+	assert.Equal(t, int64(0), s.Size())
+
+	for i := 0; i < 11; i++ {
+		assert.Equal(t, fmt.Sprintf(`{"F1":%d}`, i), records[i])
+	}
+
+	// it should start over after replay
+	assert.NoError(t, s.Write([]byte(`{"F1":11}`)))
+	n, err = s.Replay(func(b []byte) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, s.Close())
+}
+
+func TestExpire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := New(&Config{Path: dir, MaxAge: 1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Write([]byte("old")))
+	s.Lock()
+	s.segments[0].modTime = time.Now().Add(-time.Hour)
+	s.rotate()
+	s.Unlock()
+
+	assert.NoError(t, s.Write([]byte("new")))
+	assert.Equal(t, uint64(1), s.Dropped())
+
+	var records []string
+	s.Replay(func(b []byte) error {
+		records = append(records, string(b))
+		return nil
+	})
+	assert.Equal(t, []string{"new"}, records)
+}
+
+func TestCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := New(&Config{Path: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Write([]byte("abc")))
+	s.Close()
+
+	// truncated record
+	f, _ := os.OpenFile(s.segmentPath(1), os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{0, 0, 0, 9, 1})
+	f.Close()
+
+	s, err = New(&Config{Path: dir})
+	assert.NoError(t, err)
+
+	var records []string
+	_, err = s.Replay(func(b []byte) error {
+		records = append(records, string(b))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, records)
+	assert.True(t, s.Empty())
+}
+
+func TestDrain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := New(&Config{Path: dir})
+	assert.NoError(t, err)
+
+	bufpool := &sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	ch := make(chan *bytes.Buffer, 2)
+
+	d := s.Drain(ch, bufpool, zap.NewNop())
+	ch <- bytes.NewBufferString("a")
+	ch <- bytes.NewBufferString("b")
+	close(ch)
+
+	<-d.Done()
+	assert.True(t, d.Stop())
+
+	var records []string
+	s.Replay(func(b []byte) error {
+		records = append(records, string(b))
+		return nil
+	})
+	assert.Equal(t, []string{"a", "b"}, records)
+
+	d = s.Drain(make(chan *bytes.Buffer), bufpool, zap.NewNop())
+	assert.False(t, d.Stop())
+}
@@ -0,0 +1,56 @@
+package statsd
+
+import (
+	"log"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents statsd egress configuration
+type Config struct {
+	Address       string   // host:port
+	Prefix        string   // metrics name prefix
+	Dialect       string   // statsd or dogstatsd
+	Tags          []string // tag fields, e.g. DPort, Task and Hostname
+	Metrics       []Metric
+	Aggregate     bool // aggregates the metrics per the flush interval
+	FlushInterval int  // millisecond
+	MaxPacketSize int  // bytes
+}
+
+// Metric represents a metric of a field, the metric
+// value is one per event if there isn't any field.
+type Metric struct {
+	Name  string
+	Field string
+	Type  string  // counter, gauge, timing or histogram
+	Scale float64 // multiplies the field value, e.g. 0.001 for us to ms
+}
+
+// defaultMetrics are used if there isn't any configured metric.
+var defaultMetrics = []Metric{
+	{Name: "events", Type: "counter"},
+	{Name: "rtt", Field: "RTT", Type: "timing", Scale: 0.001},
+	{Name: "retrans", Field: "TotalRetrans", Type: "counter"},
+}
+
+func statsdConfig(cfg map[string]interface{}) *Config {
+	c := &Config{
+		Address:       "localhost:8125",
+		Prefix:        "tcpdog.",
+		Dialect:       "statsd",
+		Aggregate:     true,
+		FlushInterval: 1000,
+		MaxPacketSize: 1432,
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	if c.Metrics == nil {
+		c.Metrics = defaultMetrics
+	}
+
+	return c
+}
@@ -0,0 +1,357 @@
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pbstruct "github.com/golang/protobuf/ptypes/struct"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+var (
+	types = map[string]string{
+		"counter":   "c",
+		"gauge":     "g",
+		"timing":    "ms",
+		"histogram": "h",
+	}
+
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+	invalidTagChars  = regexp.MustCompile(`[|,#\s]`)
+)
+
+type statsd struct {
+	conn      net.Conn
+	address   string
+	prefix    string
+	dogstatsd bool
+	tags      []string
+	metrics   []Metric
+	aggregate bool
+	maxPacket int
+	packet    *bytes.Buffer
+
+	// the aggregated values per the metric and the tags
+	counters map[series]float64
+	gauges   map[series]float64
+	samples  map[series][]float64
+
+	spb     *helper.StructPB
+	bufpool *sync.Pool
+	logger  *zap.Logger
+}
+
+type series struct {
+	metric int
+	tags   string
+}
+
+// Start starts sending the requested fields as statsd metrics, the metrics
+// are aggregated per the flush interval and batched in the udp packets.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg    = config.FromContext(ctx)
+		sCfg   = statsdConfig(cfg.Egress[tp.Egress].Config)
+		fields = cfg.GetEgressOutFields(tp)
+	)
+
+	s := &statsd{
+		address:   sCfg.Address,
+		prefix:    sCfg.Prefix,
+		tags:      sCfg.Tags,
+		aggregate: sCfg.Aggregate,
+		maxPacket: sCfg.MaxPacketSize,
+		packet:    new(bytes.Buffer),
+		counters:  map[series]float64{},
+		gauges:    map[series]float64{},
+		samples:   map[series][]float64{},
+		spb:       helper.NewStructPB(fields),
+		bufpool:   bufpool,
+		logger:    cfg.Logger(),
+	}
+
+	switch sCfg.Dialect {
+	case "statsd":
+	case "dogstatsd":
+		s.dogstatsd = true
+	default:
+		return fmt.Errorf("unknown dialect: %s", sCfg.Dialect)
+	}
+
+	if sCfg.FlushInterval < 1 {
+		return fmt.Errorf("invalid flush interval: %d", sCfg.FlushInterval)
+	}
+
+	available := map[string]bool{"Hostname": true}
+	for _, f := range fields {
+		available[f.Name] = true
+	}
+
+	for _, tag := range s.tags {
+		if !available[tag] {
+			return fmt.Errorf("tag field %s is not available", tag)
+		}
+	}
+
+	// the metrics of the unavailable fields are skipped
+	for _, m := range sCfg.Metrics {
+		if _, ok := types[m.Type]; !ok {
+			return fmt.Errorf("unknown metric type: %s", m.Type)
+		}
+
+		if m.Field != "" && !available[m.Field] {
+			continue
+		}
+
+		if m.Scale == 0 {
+			m.Scale = 1
+		}
+
+		s.metrics = append(s.metrics, m)
+	}
+
+	if len(s.metrics) < 1 {
+		return fmt.Errorf("there isn't any metric field in the fields")
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.loop(ctx, ch, time.Duration(sCfg.FlushInterval)*time.Millisecond)
+	}()
+
+	return nil
+}
+
+func (s *statsd) loop(ctx context.Context, ch chan *bytes.Buffer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	defer func() {
+		ticker.Stop()
+
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+
+	for {
+		select {
+		case buf, ok := <-ch:
+			if !ok {
+				s.flush()
+				return
+			}
+
+			s.add(s.spb.Unmarshal(buf))
+			s.bufpool.Put(buf)
+
+		case <-ticker.C:
+			s.flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// add converts the event to the metrics, they are written
+// to the packet if the aggregation is disabled.
+func (s *statsd) add(r *pbstruct.Struct) {
+	tags := s.tagsOf(r)
+
+	for i, m := range s.metrics {
+		v := 1.0
+		if m.Field != "" {
+			f, ok := r.Fields[m.Field]
+			if !ok {
+				continue
+			}
+			v = f.GetNumberValue() * m.Scale
+		}
+
+		if !s.aggregate {
+			s.write(s.line(m, tags, v))
+			continue
+		}
+
+		key := series{metric: i, tags: tags}
+
+		switch m.Type {
+		case "counter":
+			s.counters[key] += v
+		case "gauge":
+			s.gauges[key] = v
+		default:
+			s.samples[key] = append(s.samples[key], v)
+		}
+	}
+}
+
+// tagsOf returns the tags of the event by the dialect, the dogstatsd tags
+// are key:value pairs and the statsd tags are the metric name segments.
+func (s *statsd) tagsOf(r *pbstruct.Struct) string {
+	var b strings.Builder
+
+	for i, tag := range s.tags {
+		key := strings.ToLower(tag)
+		value := helper.ValueString(r.Fields[tag])
+
+		if s.dogstatsd {
+			if i > 0 {
+				b.WriteRune(',')
+			}
+			b.WriteString(key + ":" + invalidTagChars.ReplaceAllString(value, "_"))
+			continue
+		}
+
+		b.WriteString("." + key + "_" + invalidNameChars.ReplaceAllString(value, "_"))
+	}
+
+	return b.String()
+}
+
+// line formats a metric line, the dogstatsd line
+// may carry multiple values (protocol v1.1).
+func (s *statsd) line(m Metric, tags string, values ...float64) string {
+	var b strings.Builder
+
+	b.WriteString(s.prefix + m.Name)
+	if !s.dogstatsd {
+		b.WriteString(tags)
+	}
+
+	for _, v := range values {
+		b.WriteString(":" + strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	b.WriteString("|" + types[m.Type])
+
+	if s.dogstatsd && tags != "" {
+		b.WriteString("|#" + tags)
+	}
+
+	return b.String()
+}
+
+// flush writes the aggregated metrics and sends the packet.
+func (s *statsd) flush() {
+	for _, key := range sortedKeys(s.counters) {
+		s.write(s.line(s.metrics[key.metric], key.tags, s.counters[key]))
+	}
+
+	for _, key := range sortedKeys(s.gauges) {
+		s.write(s.line(s.metrics[key.metric], key.tags, s.gauges[key]))
+	}
+
+	keys := make([]series, 0, len(s.samples))
+	for key := range s.samples {
+		keys = append(keys, key)
+	}
+	sortSeries(keys)
+
+	for _, key := range keys {
+		s.writeSamples(s.metrics[key.metric], key.tags, s.samples[key])
+	}
+
+	s.counters = map[series]float64{}
+	s.gauges = map[series]float64{}
+	s.samples = map[series][]float64{}
+
+	s.send()
+}
+
+// writeSamples writes the samples, the dogstatsd samples
+// are packed in the lines up to the max packet size.
+func (s *statsd) writeSamples(m Metric, tags string, values []float64) {
+	if !s.dogstatsd {
+		for _, v := range values {
+			s.write(s.line(m, tags, v))
+		}
+		return
+	}
+
+	// the line length without any value
+	size := len(s.line(m, tags))
+
+	for i := 0; i < len(values); {
+		n, l := 1, size+len(strconv.FormatFloat(values[i], 'f', -1, 64))+1
+		for ; i+n < len(values); n++ {
+			l += len(strconv.FormatFloat(values[i+n], 'f', -1, 64)) + 1
+			if l > s.maxPacket {
+				break
+			}
+		}
+
+		s.write(s.line(m, tags, values[i:i+n]...))
+		i += n
+	}
+}
+
+// write appends the line to the packet, the packet
+// is sent once the line doesn't fit in it.
+func (s *statsd) write(line string) {
+	if s.packet.Len() > 0 && s.packet.Len()+len(line)+1 > s.maxPacket {
+		s.send()
+	}
+
+	if s.packet.Len() > 0 {
+		s.packet.WriteRune('\n')
+	}
+
+	s.packet.WriteString(line)
+}
+
+// send sends the packet, the packet is dropped on failure.
+func (s *statsd) send() {
+	if s.packet.Len() < 1 {
+		return
+	}
+
+	defer s.packet.Reset()
+
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.address)
+		if err != nil {
+			s.logger.Error("statsd", zap.Error(err))
+			return
+		}
+
+		s.conn = conn
+	}
+
+	if _, err := s.conn.Write(s.packet.Bytes()); err != nil {
+		s.logger.Error("statsd", zap.Error(err))
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func sortedKeys(m map[series]float64) []series {
+	keys := make([]series, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sortSeries(keys)
+
+	return keys
+}
+
+func sortSeries(keys []series) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].metric != keys[j].metric {
+			return keys[i].metric < keys[j].metric
+		}
+		return keys[i].tags < keys[j].tags
+	})
+}
@@ -0,0 +1,106 @@
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/egresstest"
+)
+
+var events = []string{
+	`{"Task":"curl","DPort":443,"RTT":5000,"TotalRetrans":1,"Timestamp":1609564925}`,
+	`{"Task":"curl","DPort":443,"RTT":7500,"TotalRetrans":2,"Timestamp":1609564926}`,
+	`{"Task":"wget","DPort":80,"RTT":120,"TotalRetrans":0,"Timestamp":1609564926}`,
+}
+
+// start sends the events and returns the received packets.
+func start(t *testing.T, scheme string, conf map[string]interface{}) []string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	conf["address"] = conn.LocalAddr().String()
+
+	ch, wg := egresstest.Start(t, Start, "statsd", scheme, conf, config.Field{Name: "Task"},
+		config.Field{Name: "DPort"}, config.Field{Name: "RTT"}, config.Field{Name: "TotalRetrans"})
+	egresstest.Send(ch, wg, events...)
+
+	var packets []string
+	for {
+		b := make([]byte, 2048)
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, string(b[:n]))
+	}
+}
+
+func TestStartDogStatsD(t *testing.T) {
+	packets := start(t, "memory", map[string]interface{}{
+		"dialect": "dogstatsd",
+		"tags":    []string{"DPort", "Task"},
+	})
+
+	assert.Equal(t, []string{strings.Join([]string{
+		"tcpdog.events:2|c|#dport:443,task:curl",
+		"tcpdog.events:1|c|#dport:80,task:wget",
+		"tcpdog.retrans:3|c|#dport:443,task:curl",
+		"tcpdog.retrans:0|c|#dport:80,task:wget",
+		"tcpdog.rtt:5:7.5|ms|#dport:443,task:curl",
+		"tcpdog.rtt:0.12|ms|#dport:80,task:wget",
+	}, "\n")}, packets)
+}
+
+func TestStartStatsD(t *testing.T) {
+	packets := start(t, "memory1", map[string]interface{}{
+		"tags":          []string{"DPort"},
+		"aggregate":     false,
+		"maxPacketSize": 100,
+		"metrics": []map[string]interface{}{
+			{"name": "rtt", "field": "RTT", "type": "timing", "scale": 0.001},
+			{"name": "srtt", "field": "SRTT", "type": "timing"},
+		},
+	})
+
+	// the srtt metric is skipped, its field isn't available
+	assert.Equal(t, []string{
+		"tcpdog.rtt.dport_443:5|ms\ntcpdog.rtt.dport_443:7.5|ms\ntcpdog.rtt.dport_80:0.12|ms",
+	}, packets)
+}
+
+func TestWriteSamples(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	s := &statsd{
+		address:   conn.LocalAddr().String(),
+		prefix:    "tcpdog.",
+		dogstatsd: true,
+		maxPacket: 30,
+		packet:    new(bytes.Buffer),
+	}
+
+	s.writeSamples(Metric{Name: "rtt", Type: "timing"}, "dport:8", []float64{1, 2, 3, 4, 5})
+	s.send()
+
+	var packets []string
+	for i := 0; i < 2; i++ {
+		b := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(b)
+		assert.NoError(t, err)
+		packets = append(packets, string(b[:n]))
+	}
+
+	// a line and a packet are up to the max packet size
+	assert.Equal(t, []string{"tcpdog.rtt:1:2:3:4|ms|#dport:8", "tcpdog.rtt:5|ms|#dport:8"}, packets)
+}
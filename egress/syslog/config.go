@@ -0,0 +1,85 @@
+package syslog
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents syslog egress configuration
+type Config struct {
+	Network  string // udp, tcp, tls, unix or unixgram
+	Address  string // host:port or socket path
+	Facility string // kern, user, daemon, local0 ... local7
+	Severity string // emerg, alert, crit, err, warning, notice, info or debug
+	AppName  string
+	SDID     string // structured data id, e.g. tcpdog@32473
+	Framing  string // octet-counting or non-transparent (stream transports)
+
+	TLSConfig config.TLSConfig
+}
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var severities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+var defaultAddress = map[string]string{
+	"udp":      "localhost:514",
+	"tcp":      "localhost:514",
+	"tls":      "localhost:6514",
+	"unix":     "/dev/log",
+	"unixgram": "/dev/log",
+}
+
+func syslogConfig(cfg map[string]interface{}) *Config {
+	c := &Config{
+		Network:  "udp",
+		Facility: "local0",
+		Severity: "info",
+		AppName:  "tcpdog",
+		SDID:     "tcpdog@32473",
+	}
+
+	if err := config.Transform(cfg, c); err != nil {
+		log.Fatal(err)
+	}
+
+	return c
+}
+
+// priority returns the message priority value.
+func (c *Config) priority() (int, error) {
+	facility, ok := facilities[c.Facility]
+	if !ok {
+		return 0, fmt.Errorf("unknown facility: %s", c.Facility)
+	}
+
+	severity, ok := severities[c.Severity]
+	if !ok {
+		return 0, fmt.Errorf("unknown severity: %s", c.Severity)
+	}
+
+	return facility*8 + severity, nil
+}
+
+func (c *Config) address() (string, error) {
+	def, ok := defaultAddress[c.Network]
+	if !ok {
+		return "", fmt.Errorf("unknown network: %s", c.Network)
+	}
+
+	if c.Address != "" {
+		return c.Address, nil
+	}
+
+	return def, nil
+}
@@ -0,0 +1,221 @@
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+// dialTimeout is the connection timeout of the stream transports.
+var dialTimeout = 5 * time.Second
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+type syslog struct {
+	conn      net.Conn
+	network   string
+	address   string
+	stream    bool
+	octet     bool // octet-counting framing
+	tlsConfig *tls.Config
+	pri       string // <PRI>VERSION
+	meta      string // app-name, procid and msgid
+	sdID      string
+	spb       *helper.StructPB
+	buffer    *bytes.Buffer
+	bufpool   *sync.Pool
+	logger    *zap.Logger
+}
+
+// Start starts sending the requested fields to syslog as RFC5424 messages,
+// the fields are carried as the structured data parameters.
+func Start(ctx context.Context, tp config.Tracepoint, bufpool *sync.Pool, ch chan *bytes.Buffer, wg *sync.WaitGroup) error {
+	var (
+		cfg  = config.FromContext(ctx)
+		sCfg = syslogConfig(cfg.Egress[tp.Egress].Config)
+		err  error
+	)
+
+	s := &syslog{
+		network: sCfg.Network,
+		sdID:    sCfg.SDID,
+		spb:     helper.NewStructPB(cfg.GetEgressOutFields(tp)),
+		buffer:  new(bytes.Buffer),
+		bufpool: bufpool,
+		logger:  cfg.Logger(),
+	}
+
+	s.address, err = sCfg.address()
+	if err != nil {
+		return err
+	}
+
+	pri, err := sCfg.priority()
+	if err != nil {
+		return err
+	}
+
+	s.pri = fmt.Sprintf("<%d>1", pri)
+	s.meta = fmt.Sprintf("%s %d %s", headerField(sCfg.AppName, 48), os.Getpid(), headerField(tp.Name, 32))
+
+	switch sCfg.Framing {
+	case "", "octet-counting":
+		s.octet = true
+	case "non-transparent":
+	default:
+		return fmt.Errorf("unknown framing: %s", sCfg.Framing)
+	}
+
+	if sCfg.Network == "tls" {
+		s.tlsConfig, err = config.GetTLS(&sCfg.TLSConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.loop(ctx, ch)
+	}()
+
+	return nil
+}
+
+func (s *syslog) loop(ctx context.Context, ch chan *bytes.Buffer) {
+	defer s.close()
+
+	// the events are dropped while it's reconnecting
+	helper.Loop(ctx, "syslog", s.logger, s.bufpool, ch, func(buf *bytes.Buffer) error {
+		return s.write(s.spb.Fields(buf))
+	}, s.close)
+}
+
+// format formats the fields as a RFC5424 message without the msg part,
+// the hostname and the timestamp are carried by the header.
+func (s *syslog) format(fields []helper.Field) {
+	var (
+		hostname, timestamp string
+		sd                  strings.Builder
+	)
+
+	sd.WriteString("[" + s.sdID)
+	for _, f := range fields {
+		switch f.Name {
+		case "Hostname":
+			hostname = f.Value
+		case "Timestamp":
+			timestamp = f.Value
+		default:
+			sd.WriteString(" " + f.Name + `="` + escaper.Replace(f.Value) + `"`)
+		}
+	}
+	sd.WriteRune(']')
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		ts = time.Now().Unix()
+	}
+
+	msg := strings.Join([]string{
+		s.pri,
+		time.Unix(ts, 0).UTC().Format(time.RFC3339),
+		headerField(hostname, 255),
+		s.meta,
+		sd.String(),
+	}, " ")
+
+	s.buffer.Reset()
+
+	switch {
+	case s.stream && s.octet:
+		s.buffer.WriteString(strconv.Itoa(len(msg)) + " " + msg)
+	case s.stream:
+		s.buffer.WriteString(msg + "\n")
+	default:
+		s.buffer.WriteString(msg)
+	}
+}
+
+// write writes the message, it connects if there isn't any connection.
+func (s *syslog) write(fields []helper.Field) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+
+	s.format(fields)
+
+	_, err := s.conn.Write(s.buffer.Bytes())
+
+	return err
+}
+
+func (s *syslog) dial() error {
+	var err error
+
+	switch s.network {
+	case "tls":
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+		s.stream = true
+	case "unix":
+		// the local syslog socket is a datagram socket mostly
+		s.conn, err = net.DialTimeout("unixgram", s.address, dialTimeout)
+		s.stream = false
+		if err != nil {
+			s.conn, err = net.DialTimeout("unix", s.address, dialTimeout)
+			s.stream = true
+		}
+	default:
+		s.conn, err = net.DialTimeout(s.network, s.address, dialTimeout)
+		s.stream = s.network == "tcp"
+	}
+
+	if err != nil {
+		s.conn = nil
+	}
+
+	return err
+}
+
+func (s *syslog) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// headerField returns the nil value if it's empty otherwise it
+// replaces the invalid characters and truncates to the max length.
+func headerField(v string, max int) string {
+	if v == "" {
+		return "-"
+	}
+
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, v)
+
+	if len(v) > max {
+		v = v[:max]
+	}
+
+	return v
+}
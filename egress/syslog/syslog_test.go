@@ -0,0 +1,122 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/egresstest"
+)
+
+func start(t *testing.T, scheme string, conf map[string]interface{}) chan *bytes.Buffer {
+	ch, _ := egresstest.Start(t, Start, "syslog", scheme, conf, config.Field{Name: "Task"}, config.Field{Name: "F1"})
+	ch <- bytes.NewBufferString(`{"Task":"curl]","F1":5,"Timestamp":1609564925}`)
+
+	return ch
+}
+
+func expected() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf(`<134>1 2021-01-02T05:22:05Z %s tcpdog %d sock:inet_sock_set_state [tcpdog@32473 Task="curl\]" F1="5"]`,
+		hostname, os.Getpid())
+}
+
+func TestStartUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ch := start(t, "memory", map[string]interface{}{
+		"address": conn.LocalAddr().String(),
+	})
+	defer close(ch)
+
+	b := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(b)
+	assert.NoError(t, err)
+	assert.Equal(t, expected(), string(b[:n]))
+}
+
+func TestStartTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	ch := start(t, "memory1", map[string]interface{}{
+		"network": "tcp",
+		"address": l.Addr().String(),
+	})
+	defer close(ch)
+
+	conn, err := l.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	var n int
+	r := bufio.NewReader(conn)
+	_, err = fmt.Fscanf(r, "%d ", &n)
+	assert.NoError(t, err)
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	assert.NoError(t, err)
+	assert.Equal(t, expected(), string(b))
+}
+
+func TestStartUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpdog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "log"))
+	assert.NoError(t, err)
+	defer l.Close()
+
+	ch := start(t, "memory2", map[string]interface{}{
+		"network": "unix",
+		"address": filepath.Join(dir, "log"),
+		"framing": "non-transparent",
+	})
+	defer close(ch)
+
+	conn, err := l.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, expected()+"\n", line)
+}
+
+func TestConfig(t *testing.T) {
+	c := syslogConfig(map[string]interface{}{"facility": "foo"})
+	_, err := c.priority()
+	assert.Error(t, err)
+
+	c = syslogConfig(map[string]interface{}{"severity": "err", "facility": "daemon"})
+	pri, err := c.priority()
+	assert.NoError(t, err)
+	assert.Equal(t, 27, pri)
+
+	addr, err := c.address()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:514", addr)
+
+	c = syslogConfig(map[string]interface{}{"network": "foo"})
+	_, err = c.address()
+	assert.Error(t, err)
+
+	assert.Equal(t, "-", headerField("", 10))
+	assert.Equal(t, "a_b", headerField("a b c", 3))
+}
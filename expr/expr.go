@@ -0,0 +1,457 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr represents a parsed expression over the event fields.
+//
+// It supports numbers, "quoted" strings, field names, the arithmetic
+// operators + - * / %, the comparison operators == != < <= > >=,
+// the logical operators && || ! and parentheses. A division by zero
+// evaluates to zero so derived ratios never produce NaN or Inf.
+type Expr struct {
+	src  string
+	root node
+	vars []string
+}
+
+type node interface {
+	eval(fields map[string]interface{}) (interface{}, error)
+}
+
+type (
+	number   float64
+	str      string
+	variable string
+
+	unary struct {
+		op string
+		x  node
+	}
+
+	binary struct {
+		op   string
+		x, y node
+	}
+)
+
+// Parse parses an expression.
+func Parse(s string) (*Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, vars: map[string]bool{}}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos].text, s)
+	}
+
+	e := &Expr{src: s, root: root}
+	for _, t := range tokens {
+		if t.kind == tIdent && p.vars[t.text] {
+			e.vars = append(e.vars, t.text)
+			delete(p.vars, t.text)
+		}
+	}
+
+	return e, nil
+}
+
+// String returns the expression source.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Vars returns the field names the expression refers to.
+func (e *Expr) Vars() []string {
+	return e.vars
+}
+
+// Eval evaluates the expression and returns a float64, string or bool.
+func (e *Expr) Eval(fields map[string]interface{}) (interface{}, error) {
+	return e.root.eval(fields)
+}
+
+// Float evaluates the expression and returns a number.
+func (e *Expr) Float(fields map[string]interface{}) (float64, error) {
+	v, err := e.root.eval(fields)
+	if err != nil {
+		return 0, err
+	}
+
+	return toFloat(v)
+}
+
+// Bool evaluates the expression and returns a boolean, a number
+// is true if it is not zero.
+func (e *Expr) Bool(fields map[string]interface{}) (bool, error) {
+	v, err := e.root.eval(fields)
+	if err != nil {
+		return false, err
+	}
+
+	return toBool(v)
+}
+
+func (n number) eval(map[string]interface{}) (interface{}, error) {
+	return float64(n), nil
+}
+
+func (n str) eval(map[string]interface{}) (interface{}, error) {
+	return string(n), nil
+}
+
+func (n variable) eval(fields map[string]interface{}) (interface{}, error) {
+	v, ok := fields[string(n)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field: %s", n)
+	}
+
+	switch v := v.(type) {
+	case string, bool, float64:
+		return v, nil
+	default:
+		return toFloat(v)
+	}
+}
+
+func (n *unary) eval(fields map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "!" {
+		b, err := toBool(x)
+		return !b, err
+	}
+
+	f, err := toFloat(x)
+	return -f, err
+}
+
+func (n *binary) eval(fields map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit logical operators
+	switch n.op {
+	case "&&", "||":
+		bx, err := toBool(x)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&") != bx {
+			return bx, nil
+		}
+		y, err := n.y.eval(fields)
+		if err != nil {
+			return nil, err
+		}
+		return toBool(y)
+	}
+
+	y, err := n.y.eval(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// string comparison
+	if sx, ok := x.(string); ok {
+		sy, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("mismatched types: %q %s %v", sx, n.op, y)
+		}
+		switch n.op {
+		case "==":
+			return sx == sy, nil
+		case "!=":
+			return sx != sy, nil
+		}
+		return nil, fmt.Errorf("invalid operator %s for strings", n.op)
+	}
+
+	fx, err := toFloat(x)
+	if err != nil {
+		return nil, err
+	}
+	fy, err := toFloat(y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "+":
+		return fx + fy, nil
+	case "-":
+		return fx - fy, nil
+	case "*":
+		return fx * fy, nil
+	case "/":
+		if fy == 0 {
+			return 0.0, nil
+		}
+		return fx / fy, nil
+	case "%":
+		if int64(fy) == 0 {
+			return 0.0, nil
+		}
+		return float64(int64(fx) % int64(fy)), nil
+	case "==":
+		return fx == fy, nil
+	case "!=":
+		return fx != fy, nil
+	case "<":
+		return fx < fy, nil
+	case "<=":
+		return fx <= fy, nil
+	case ">":
+		return fx > fy, nil
+	case ">=":
+		return fx >= fy, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s", n.op)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, fmt.Errorf("not a number: %v", v)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return v != "", nil
+	}
+
+	f, err := toFloat(v)
+	return f != 0, err
+}
+
+type tokenKind uint8
+
+const (
+	tNumber tokenKind = iota + 1
+	tString
+	tIdent
+	tOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")"}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			j := i
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.' || s[j] == 'e' || s[j] == 'E' ||
+				((s[j] == '+' || s[j] == '-') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{tNumber, s[i:j]})
+			i = j
+
+		case isLetter(c):
+			j := i
+			for j < len(s) && (isLetter(s[j]) || isDigit(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tIdent, s[i:j]})
+			i = j
+
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", s)
+			}
+			tokens = append(tokens, token{tString, s[i+1 : i+1+j]})
+			i += j + 2
+
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q in %q", c, s)
+			}
+			tokens = append(tokens, token{tOp, op})
+			i += len(op)
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	vars   map[string]bool
+}
+
+func (p *parser) peek(ops ...string) string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tOp {
+		return ""
+	}
+
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op
+		}
+	}
+
+	return ""
+}
+
+func (p *parser) binary(next func() (node, error), ops ...string) (node, error) {
+	x, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(ops...); op != ""; op = p.peek(ops...) {
+		p.pos++
+		y, err := next()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: op, x: x, y: y}
+	}
+
+	return x, nil
+}
+
+func (p *parser) or() (node, error) {
+	return p.binary(p.and, "||")
+}
+
+func (p *parser) and() (node, error) {
+	return p.binary(p.comparison, "&&")
+}
+
+func (p *parser) comparison() (node, error) {
+	return p.binary(p.additive, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *parser) additive() (node, error) {
+	return p.binary(p.multiplicative, "+", "-")
+}
+
+func (p *parser) multiplicative() (node, error) {
+	return p.binary(p.unary, "*", "/", "%")
+}
+
+func (p *parser) unary() (node, error) {
+	if op := p.peek("-", "!"); op != "" {
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, x: x}, nil
+	}
+
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case tNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", t.text)
+		}
+		return number(f), nil
+	case tString:
+		return str(t.text), nil
+	case tIdent:
+		p.vars[t.text] = true
+		return variable(t.text), nil
+	}
+
+	if t.text == "(" {
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek(")") == "" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return x, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,92 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEval(t *testing.T) {
+	fields := map[string]interface{}{
+		"TotalRetrans": 2.0,
+		"SegsOut":      8.0,
+		"DPort":        443.0,
+		"Task":         "curl",
+		"RTT":          uint32(120),
+	}
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"TotalRetrans / SegsOut", 0.25},
+		{"TotalRetrans / 0", 0.0},
+		{"(1 + 2) * 3", 9.0},
+		{"1 + 2 * 3", 7.0},
+		{"-RTT + 20", -100.0},
+		{"7 % 4", 3.0},
+		{"1.5e2", 150.0},
+		{"TotalRetrans > 0 || DPort == 443", true},
+		{"TotalRetrans > 5 && DPort == 443", false},
+		{"!(DPort != 443)", true},
+		{`Task == "curl"`, true},
+		{`Task != 'curl'`, false},
+		{"RTT >= 120 && RTT <= 120", true},
+	}
+
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		assert.NoError(t, err, test.expr)
+		v, err := e.Eval(fields)
+		assert.NoError(t, err, test.expr)
+		assert.Equal(t, test.expected, v, test.expr)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, s := range []string{"", "1 +", "(1 + 2", "1 2", "RTT # 2", `"abc`} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	e, err := Parse("Foo * 2")
+	assert.NoError(t, err)
+	_, err = e.Float(map[string]interface{}{})
+	assert.Error(t, err)
+
+	e, err = Parse(`Task > "a"`)
+	assert.NoError(t, err)
+	_, err = e.Bool(map[string]interface{}{"Task": "curl"})
+	assert.Error(t, err)
+}
+
+func TestVars(t *testing.T) {
+	e, err := Parse("BytesAcked * 8 / (RateInterval + BytesAcked)")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BytesAcked", "RateInterval"}, e.Vars())
+	assert.Equal(t, "BytesAcked * 8 / (RateInterval + BytesAcked)", e.String())
+}
+
+func TestFloatBool(t *testing.T) {
+	e, err := Parse("SegsOut")
+	assert.NoError(t, err)
+
+	f, err := e.Float(map[string]interface{}{"SegsOut": 3.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, f)
+
+	b, err := e.Bool(map[string]interface{}{"SegsOut": 0.0})
+	assert.NoError(t, err)
+	assert.False(t, b)
+}
+
+func BenchmarkEval(b *testing.B) {
+	e, _ := Parse("TotalRetrans / SegsOut")
+	fields := map[string]interface{}{"TotalRetrans": 2.0, "SegsOut": 8.0}
+
+	for i := 0; i < b.N; i++ {
+		e.Float(fields)
+	}
+}
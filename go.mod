@@ -3,22 +3,25 @@ module github.com/mehrdadrad/tcpdog
 go 1.15
 
 require (
-	github.com/Shopify/sarama v1.26.3
+	github.com/ClickHouse/clickhouse-go v1.5.4
+	github.com/Shopify/sarama v1.27.2
+	github.com/eclipse/paho.mqtt.golang v1.4.2
 	github.com/elastic/go-elasticsearch/v8 v8.0.0-20201229214741-2366c2514674
 	github.com/golang/protobuf v1.4.2
+	github.com/golang/snappy v0.0.1
 	github.com/influxdata/influxdb-client-go/v2 v2.2.1
 	github.com/iovisor/gobpf v0.0.0-20210109143822-fb892541d416
-	github.com/ip2location/ip2location-go v8.3.0+incompatible
+	github.com/nats-io/nats-server/v2 v2.10.7
+	github.com/nats-io/nats.go v1.31.0
 	github.com/oschwald/geoip2-golang v1.4.0
 	github.com/oschwald/maxminddb-golang v1.8.0
 	github.com/sethvargo/go-signalcontext v0.1.0
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.7.1
 	github.com/urfave/cli/v2 v2.3.0
+	github.com/xdg-go/scram v1.1.2
 	go.uber.org/zap v1.16.0
-	golang.org/x/net v0.0.0-20200625001655-4c5254603344 // indirect
-	golang.org/x/sys v0.0.0-20200625212154-ddb9806d33ae // indirect
-	golang.org/x/tools v0.0.0-20200103221440-774c71fcf114 // indirect
+	golang.org/x/net v0.10.0
 	google.golang.org/grpc v1.27.0
 	google.golang.org/protobuf v1.25.0
-	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
+	gopkg.in/yaml.v3 v3.0.1
 )
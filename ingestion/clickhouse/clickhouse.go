@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	chgo "github.com/ClickHouse/clickhouse-go"
 	"go.uber.org/zap"
 
+	"github.com/mehrdadrad/tcpdog/ack"
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/egress/helper"
 	"github.com/mehrdadrad/tcpdog/geo"
@@ -25,8 +27,14 @@ type clickhouse struct {
 	vFields reflect.Value
 }
 
+// row represents the values of a row and its acknowledgement.
+type row struct {
+	values []interface{}
+	ack    func()
+}
+
 // Start starts ingestion data to clickhouse
-func Start(ctx context.Context, name string, ser string, ch chan interface{}) error {
+func Start(ctx context.Context, name string, ser string, ch chan interface{}, wg *sync.WaitGroup) error {
 	var g geo.Geoer
 
 	cfg := config.FromContextServer(ctx)
@@ -55,109 +63,159 @@ func Start(ctx context.Context, name string, ser string, ch chan interface{}) er
 	}
 
 	c := clickhouse{geo: g, cfg: cCfg, serialization: ser, vFields: reflect.ValueOf(&pb.Fields{}).Elem()}
-	iCh := make(chan []interface{}, 1000)
+	iCh := make(chan row, 1000)
 
+	var workers, conns sync.WaitGroup
 	for i := 0; i < c.cfg.Workers; i++ {
-		go c.iWorker(ctx, ch, iCh)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			c.iWorker(ctx, ch, iCh)
+		}()
 	}
 
+	go func() {
+		workers.Wait()
+		close(iCh)
+	}()
+
 	for i := 0; i < c.cfg.Connections; i++ {
-		go c.ingest(ctx, connect, iCh)
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			c.ingest(ctx, connect, iCh)
+		}()
 	}
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conns.Wait()
+		connect.Close()
+	}()
+
 	return nil
 }
 
-func (c *clickhouse) iWorker(ctx context.Context, ch chan interface{}, iCh chan []interface{}) {
+func (c *clickhouse) iWorker(ctx context.Context, ch chan interface{}, iCh chan row) {
 	fn := c.getSliceIfMaker()
 	logger := config.FromContextServer(ctx).Logger()
 
 	for {
 		select {
-		case data := <-ch:
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			data, done := ack.Split(data)
+
 			s, err := fn(data)
 			if err != nil {
 				logger.Error("clickhouse", zap.Error(err))
+				done()
 				continue
 			}
 
-			iCh <- s
+			iCh <- row{values: s, ack: done}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (c *clickhouse) ingest(ctx context.Context, connect *sql.DB, iCh chan []interface{}) {
+// ingest inserts the rows in batches, the rows are acknowledged once
+// their transaction has been committed. a failed batch is retried until
+// it has been committed or the ingestion has been canceled, so the
+// ingress never commits past an event which hasn't been stored.
+func (c *clickhouse) ingest(ctx context.Context, connect *sql.DB, iCh chan row) {
 	query := c.getQuery()
 	logger := config.FromContextServer(ctx).Logger()
-	timer := time.NewTimer(time.Second * 10)
-	backoff := helper.NewBackoff(logger)
 	interval := time.Second * time.Duration(c.cfg.FlushInterval)
-	counter := 0
-	timeoutCounter := 0
+	timer := time.NewTimer(interval)
+	backoff := helper.NewBackoff(logger)
 
-OUTERLOOP:
-	for {
+	var (
+		rows   [][]interface{}
+		batch  ack.Batch
+		closed bool
+	)
 
-		tx, err := connect.Begin()
-		if err != nil {
-			logger.Error("clickhouse-1", zap.Error(err))
-			backoff.Next()
-			continue
-		}
+	for !closed {
+		rows = rows[:0]
 
-		stmt, err := tx.Prepare(query)
-		if err != nil {
-			logger.Error("clickhouse-2", zap.Error(err))
-			backoff.Next()
-			continue
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
 		}
-
-		counter = 0
-		timeoutCounter = 0
 		timer.Reset(interval)
 
-	INNERLOOP:
-		for {
+	BATCH:
+		for len(rows) < c.cfg.BatchSize {
 			select {
-			case fields := <-iCh:
-				_, err := stmt.ExecContext(ctx, fields...)
-				if err != nil {
-					logger.Error("clickhouse-3", zap.Error(err))
+			case r, ok := <-iCh:
+				if !ok {
+					closed = true
+					break BATCH
 				}
 
-				counter++
-				if counter >= c.cfg.BatchSize {
-					break INNERLOOP
-				}
+				rows = append(rows, r.values)
+				batch.Add(r.ack)
 			case <-timer.C:
-				if counter > 0 {
-					break INNERLOOP
+				if len(rows) > 0 {
+					break BATCH
 				}
 				timer.Reset(interval)
-				if timeoutCounter++; timeoutCounter >= (300/c.cfg.FlushInterval)-1 {
-					continue OUTERLOOP
-				}
 			case <-ctx.Done():
-				tx.Commit()
 				return
 			}
 		}
 
-		if err := tx.Commit(); err != nil {
-			logger.Error("clickhouse", zap.Error(err))
-		}
+		for len(rows) > 0 {
+			err := c.insert(ctx, connect, query, rows)
+			if err == nil {
+				batch.Done()
+				break
+			}
 
-		if !timer.Stop() {
-			select {
-			case <-timer.C:
-			default:
+			logger.Error("clickhouse", zap.Error(err), zap.Int("rows", len(rows)))
+
+			if ctx.Err() != nil {
+				return
 			}
+
+			backoff.Next()
 		}
 	}
 }
 
+// insert inserts the rows in a transaction, the invalid
+// rows are skipped as they would fail at the retry too.
+func (c *clickhouse) insert(ctx context.Context, connect *sql.DB, query string, rows [][]interface{}) error {
+	logger := config.FromContextServer(ctx).Logger()
+
+	tx, err := connect.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, values := range rows {
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			logger.Error("clickhouse", zap.Error(err))
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (c *clickhouse) JSON(fi interface{}) ([]interface{}, error) {
 	f := fi.(map[string]interface{})
 
@@ -172,6 +230,11 @@ func (c *clickhouse) JSON(fi interface{}) ([]interface{}, error) {
 	a := make([]interface{}, len(c.cfg.Fields))
 
 	for i, name := range c.cfg.Fields {
+		if !c.vFields.FieldByName(name).IsValid() {
+			a[i] = f[name] // derived field
+			continue
+		}
+
 		switch c.vFields.FieldByName(name).Type().Elem().Kind() {
 		case reflect.Uint32:
 			a[i] = uint32(f[name].(float64))
@@ -187,7 +250,8 @@ func (c *clickhouse) JSON(fi interface{}) ([]interface{}, error) {
 
 func (c *clickhouse) PB(fi interface{}) ([]interface{}, error) {
 	a := make([]interface{}, len(c.cfg.Fields))
-	v := reflect.ValueOf(fi.(*pb.Fields)).Elem()
+	f := fi.(*pb.Fields)
+	v := reflect.ValueOf(f).Elem()
 
 	geoKV := map[string]string{}
 	if c.geo != nil {
@@ -198,7 +262,11 @@ func (c *clickhouse) PB(fi interface{}) ([]interface{}, error) {
 	}
 
 	for i, name := range c.cfg.Fields {
-		if v.FieldByName(name).Pointer() != 0 {
+		if ext, ok := f.Ext[name]; ok {
+			a[i] = ext // derived field
+		} else if !v.FieldByName(name).IsValid() {
+			continue
+		} else if v.FieldByName(name).Pointer() != 0 {
 			switch v.FieldByName(name).Type().Elem().Kind() {
 			case reflect.Uint32:
 				a[i] = uint32(v.FieldByName(name).Elem().Uint())
@@ -228,6 +296,11 @@ func (c *clickhouse) SPB(fi interface{}) ([]interface{}, error) {
 	}
 
 	for i, name := range c.cfg.Fields {
+		if !c.vFields.FieldByName(name).IsValid() {
+			a[i] = f.Fields.Fields[name].GetNumberValue() // derived field
+			continue
+		}
+
 		switch c.vFields.FieldByName(name).Type().Elem().Kind() {
 		case reflect.Uint32:
 			a[i] = uint32(f.Fields.Fields[name].GetNumberValue())
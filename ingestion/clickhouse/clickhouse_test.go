@@ -5,6 +5,7 @@ import (
 	"net"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -88,7 +89,7 @@ func TestStart(t *testing.T) {
 
 	ch := make(chan interface{}, 10)
 
-	Start(ctx, "foo", "json", ch)
+	Start(ctx, "foo", "json", ch, new(sync.WaitGroup))
 	time.Sleep(time.Second * 2)
 
 	m := map[string]interface{}{}
@@ -105,7 +106,7 @@ func TestStart(t *testing.T) {
 
 	// server down
 	ctx = cfg.WithContext(context.Background())
-	err := Start(ctx, "foo", "json", ch)
+	err := Start(ctx, "foo", "json", ch, new(sync.WaitGroup))
 	assert.Error(t, err)
 }
 
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
@@ -13,6 +14,7 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	"github.com/mehrdadrad/tcpdog/ack"
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/geo"
 	pb "github.com/mehrdadrad/tcpdog/proto"
@@ -25,7 +27,7 @@ type elastic struct {
 }
 
 // Start starts ingestion data points to influxdb
-func Start(ctx context.Context, name string, ser string, ch chan interface{}) error {
+func Start(ctx context.Context, name string, ser string, ch chan interface{}, wg *sync.WaitGroup) error {
 	var g geo.Geoer
 
 	cfg := config.FromContextServer(ctx)
@@ -68,15 +70,36 @@ func Start(ctx context.Context, name string, ser string, ch chan interface{}) er
 	iCh := make(chan *esutil.BulkIndexerItem, 1000)
 
 	// marshaler workers (encode data)
+	var workers sync.WaitGroup
 	for c := 0; c < eCfg.Workers; c++ {
-		go e.iWorker(ctx, ch, iCh)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			e.iWorker(ctx, ch, iCh)
+		}()
 	}
 
 	go func() {
+		workers.Wait()
+		close(iCh)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
 		for {
 			select {
-			case item := <-iCh:
-				err = indexer.Add(ctx, *item)
+			case item, ok := <-iCh:
+				if !ok {
+					// flushes the remaining items
+					if err := indexer.Close(ctx); err != nil {
+						logger.Error("es.close", zap.Error(err))
+					}
+					return
+				}
+
+				err := indexer.Add(ctx, *item)
 				if err != nil {
 					logger.Error("es.add", zap.Error(err))
 				}
@@ -92,20 +115,32 @@ func Start(ctx context.Context, name string, ser string, ch chan interface{}) er
 
 // iWorker creates elasticsearch item
 func (e *elastic) iWorker(ctx context.Context, ch chan interface{}, iCh chan *esutil.BulkIndexerItem) {
-	var fields interface{}
+	var (
+		fields interface{}
+		ok     bool
+	)
 
 	logger := config.FromContextServer(ctx).Logger()
 	getItem := e.getItemMaker(e.serialization)
 
 	for {
 		select {
-		case fields = <-ch:
+		case fields, ok = <-ch:
+			if !ok {
+				return
+			}
+
+			fields, done := ack.Split(fields)
+
 			item, err := getItem(fields)
 			if err != nil {
 				logger.Error("es.worker", zap.Error(err))
+				done()
 				continue
 			}
 
+			setAck(item, done, logger)
+
 			iCh <- item
 		case <-ctx.Done():
 			return
@@ -113,6 +148,28 @@ func (e *elastic) iWorker(ctx context.Context, ch chan interface{}, iCh chan *es
 	}
 }
 
+// setAck acknowledges the item once it has been indexed, the rejected
+// item is acknowledged too unless it's retryable.
+func setAck(item *esutil.BulkIndexerItem, done func(), logger *zap.Logger) {
+	item.OnSuccess = func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem) {
+		done()
+	}
+
+	item.OnFailure = func(_ context.Context, _ esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+		if err != nil {
+			logger.Error("es.item", zap.Error(err))
+			return
+		}
+
+		logger.Error("es.item", zap.Int("status", res.Status), zap.String("type", res.Error.Type),
+			zap.String("reason", res.Error.Reason))
+
+		if res.Status < 500 && res.Status != 429 {
+			done()
+		}
+	}
+}
+
 func (e *elastic) getItemMaker(ser string) func(fi interface{}) (*esutil.BulkIndexerItem, error) {
 	switch ser {
 	case "json":
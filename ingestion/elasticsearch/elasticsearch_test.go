@@ -3,14 +3,17 @@ package elasticsearch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/elastic/go-elasticsearch/v8/esutil"
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/geo"
 	pb "github.com/mehrdadrad/tcpdog/proto"
@@ -63,7 +66,7 @@ func TestStart(t *testing.T) {
 	ctx = cfg.WithContext(ctx)
 	ch := make(chan interface{}, 1)
 
-	Start(ctx, "foo", "json", ch)
+	Start(ctx, "foo", "json", ch, new(sync.WaitGroup))
 
 	m := map[string]interface{}{}
 	b := []byte(`{"PID":123456,"Task":"curl","RTT":12345,"Timestamp":1611118090,"Hostname":"foo"}`)
@@ -77,6 +80,27 @@ func TestStart(t *testing.T) {
 	server.Close()
 }
 
+func TestSetAck(t *testing.T) {
+	var (
+		item  = &esutil.BulkIndexerItem{}
+		acked int
+	)
+
+	setAck(item, func() { acked++ }, zap.NewNop())
+
+	item.OnSuccess(context.Background(), *item, esutil.BulkIndexerResponseItem{Status: 201})
+	assert.Equal(t, 1, acked)
+
+	// it's rejected and it's not retryable
+	item.OnFailure(context.Background(), *item, esutil.BulkIndexerResponseItem{Status: 400}, nil)
+	assert.Equal(t, 2, acked)
+
+	// it's redelivered
+	item.OnFailure(context.Background(), *item, esutil.BulkIndexerResponseItem{Status: 429}, nil)
+	item.OnFailure(context.Background(), *item, esutil.BulkIndexerResponseItem{}, errors.New("foo"))
+	assert.Equal(t, 2, acked)
+}
+
 func TestItemJSON(t *testing.T) {
 	e := &elastic{geo: &geoMock{}, cfg: &esConfig{GeoField: "SAddr"}}
 
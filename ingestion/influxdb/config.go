@@ -7,14 +7,14 @@ import (
 )
 
 type dbConfig struct {
-	URL        string
-	Org        string
-	Bucket     string
-	Token      string
-	Timeout    uint
-	MaxRetries uint
-	BatchSize  uint
-	Workers    uint
+	URL           string
+	Org           string
+	Bucket        string
+	Token         string
+	Timeout       uint
+	BatchSize     uint // a failed batch is retried until it has been written
+	FlushInterval uint // second
+	Workers       uint
 
 	GeoField string // field supposed to resolve to Geo
 
@@ -24,18 +24,22 @@ type dbConfig struct {
 func influxDBConfig(cfg map[string]interface{}) *dbConfig {
 	// default configuration
 	conf := &dbConfig{
-		URL:        "http://localhost:8086",
-		Bucket:     "tcpdog",
-		Timeout:    5,
-		MaxRetries: 10,
-		BatchSize:  200,
-		Workers:    2,
-		GeoField:   "DAddr",
+		URL:           "http://localhost:8086",
+		Bucket:        "tcpdog",
+		Timeout:       5,
+		BatchSize:     200,
+		FlushInterval: 1,
+		Workers:       2,
+		GeoField:      "DAddr",
 	}
 
 	if err := config.Transform(cfg, conf); err != nil {
 		log.Fatal(err)
 	}
 
+	if conf.FlushInterval < 1 {
+		conf.FlushInterval = 1
+	}
+
 	return conf
 }
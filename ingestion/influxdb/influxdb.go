@@ -3,13 +3,18 @@ package influxdb
 import (
 	"context"
 	"reflect"
+	"sync"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	"github.com/mehrdadrad/tcpdog/ack"
 	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
 	"github.com/mehrdadrad/tcpdog/geo"
 	pb "github.com/mehrdadrad/tcpdog/proto"
 )
@@ -22,8 +27,14 @@ type influxdb struct {
 	serialization string
 }
 
+// point represents an influxdb point and its acknowledgement.
+type point struct {
+	point *write.Point
+	ack   func()
+}
+
 // Start starts ingestion data points to influxdb
-func Start(ctx context.Context, name string, ser string, ch chan interface{}) error {
+func Start(ctx context.Context, name string, ser string, ch chan interface{}, wg *sync.WaitGroup) error {
 	var g geo.Geoer
 
 	cfg := config.FromContextServer(ctx)
@@ -35,7 +46,9 @@ func Start(ctx context.Context, name string, ser string, ch chan interface{}) er
 	}
 
 	client := influxdb2.NewClientWithOptions(iCfg.URL, iCfg.Token, opts)
-	writeAPI := client.WriteAPI(iCfg.Org, iCfg.Bucket)
+	newWriteAPI := func() api.WriteAPIBlocking {
+		return api.NewWriteAPIBlocking(iCfg.Org, iCfg.Bucket, client.HTTPService(), opts.WriteOptions())
+	}
 
 	// if geo is available
 	if v, ok := geo.Reg[cfg.Geo.Type]; ok {
@@ -45,40 +58,120 @@ func Start(ctx context.Context, name string, ser string, ch chan interface{}) er
 
 	i := influxdb{geo: g, cfg: iCfg, serialization: ser}
 
-	pCh := make(chan *write.Point, maxChanSize)
+	pCh := make(chan point, maxChanSize)
 
+	var workers sync.WaitGroup
 	for c := uint(0); c < iCfg.Workers; c++ {
-		go i.pWorker(ctx, ch, pCh)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			i.pWorker(ctx, ch, pCh)
+		}()
 	}
 
+	go func() {
+		workers.Wait()
+		close(pCh)
+	}()
+
 	// main influxdb loop
+	wg.Add(1)
 	go func() {
-		for {
-			select {
-			case p := <-pCh:
-				writeAPI.WritePoint(p)
-			case <-ctx.Done():
+		defer wg.Done()
+		defer client.Close()
+
+		i.write(ctx, newWriteAPI, pCh)
+	}()
+
+	return nil
+}
+
+// write writes the points in batches, the points are acknowledged once
+// their batch has been written. a failed batch is retried until it has
+// been written or the ingestion has been canceled, so the ingress never
+// commits past an event which hasn't been stored.
+func (i *influxdb) write(ctx context.Context, newWriteAPI func() api.WriteAPIBlocking, pCh chan point) {
+	var (
+		points []*write.Point
+		batch  ack.Batch
+	)
+
+	logger := config.FromContextServer(ctx).Logger()
+	backoff := helper.NewBackoff(logger)
+	writeAPI := newWriteAPI()
+	ticker := time.NewTicker(time.Duration(i.cfg.FlushInterval) * time.Second)
+	defer ticker.Stop()
+
+	flush := func() {
+		for len(points) > 0 {
+			err := writeAPI.WritePoint(ctx, points...)
+			if err == nil {
+				batch.Done()
+				points = points[:0]
+				return
+			}
+
+			logger.Error("influxdb", zap.Error(err), zap.Int("points", len(points)))
+
+			if ctx.Err() != nil {
 				return
 			}
+
+			// the blocking write api queues the failed batch and may
+			// return without writing the next one, a new one doesn't.
+			writeAPI = newWriteAPI()
+
+			backoff.Next()
 		}
-	}()
+	}
 
-	return nil
+	for {
+		select {
+		case p, ok := <-pCh:
+			if !ok {
+				flush()
+				return
+			}
+
+			points = append(points, p.point)
+			batch.Add(p.ack)
+
+			if uint(len(points)) >= i.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 // pWorker creates influxdb point
-func (i *influxdb) pWorker(ctx context.Context, ch chan interface{}, pCh chan *write.Point) {
-	var fields interface{}
+func (i *influxdb) pWorker(ctx context.Context, ch chan interface{}, pCh chan point) {
+	var (
+		fields interface{}
+		ok     bool
+	)
 
-	point := i.getPointMaker(i.serialization)
+	getPoint := i.getPointMaker(i.serialization)
 
 	for {
 		select {
-		case fields = <-ch:
-			p := point(fields)
-			if p != nil {
-				pCh <- p
+		case fields, ok = <-ch:
+			if !ok {
+				return
+			}
+
+			fields, done := ack.Split(fields)
+
+			p := getPoint(fields)
+			if p == nil {
+				done()
+				continue
 			}
+
+			pCh <- point{point: p, ack: done}
 		case <-ctx.Done():
 			return
 		}
@@ -135,9 +228,14 @@ func (i *influxdb) pointPB(fi interface{}) *write.Point {
 		timestamp time.Time
 	)
 
-	v := reflect.ValueOf(fi.(*pb.Fields)).Elem()
+	f := fi.(*pb.Fields)
+	v := reflect.ValueOf(f).Elem()
 
 	for n := 3; n < v.NumField(); n++ {
+		if v.Field(n).Kind() == reflect.Map {
+			continue
+		}
+
 		if v.Field(n).Pointer() != 0 {
 			switch v.Field(n).Addr().Elem().Elem().Kind() {
 			case reflect.String:
@@ -160,6 +258,11 @@ func (i *influxdb) pointPB(fi interface{}) *write.Point {
 		}
 	}
 
+	// derived fields
+	for key, value := range f.Ext {
+		fields[key] = value
+	}
+
 	return influxdb2.NewPoint("tcpdog", tags, fields, timestamp)
 }
 
@@ -194,7 +297,6 @@ func (i *influxdb) pointJSON(fi interface{}) *write.Point {
 // influxdbOpts returns influxdb options
 func influxdbOpts(cfg *dbConfig) (*influxdb2.Options, error) {
 	opts := influxdb2.DefaultOptions()
-	opts.SetMaxRetries(cfg.MaxRetries)
 	opts.SetHTTPRequestTimeout(cfg.Timeout)
 	opts.SetBatchSize(cfg.BatchSize)
 
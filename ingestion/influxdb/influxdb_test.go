@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	"github.com/mehrdadrad/tcpdog/ack"
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/geo"
 )
@@ -51,11 +53,14 @@ func TestStart(t *testing.T) {
 			},
 		},
 	}
+
+	cfg.SetMockLogger("memory")
+
 	ctx, cancel := context.WithCancel(context.Background())
 	ctx = cfg.WithContext(ctx)
 	ch := make(chan interface{}, 1)
 
-	Start(ctx, "foo", "json", ch)
+	Start(ctx, "foo", "json", ch, new(sync.WaitGroup))
 
 	m := map[string]interface{}{}
 	b := []byte(`{"PID":123456,"Task":"curl","RTT":12345,"Timestamp":1611118090,"Hostname":"foo"}`)
@@ -69,6 +74,86 @@ func TestStart(t *testing.T) {
 	server.Close()
 }
 
+func TestStartDrain(t *testing.T) {
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	cfg := &config.ServerConfig{
+		Ingestion: map[string]config.Ingestion{
+			"foo": {
+				Config: map[string]interface{}{
+					"url": server.URL,
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory1")
+	ctx := cfg.WithContext(context.Background())
+	ch := make(chan interface{}, 1)
+	wg := new(sync.WaitGroup)
+
+	err := Start(ctx, "foo", "json", ch, wg)
+	assert.NoError(t, err)
+
+	acked := false
+
+	m := map[string]interface{}{}
+	json.Unmarshal([]byte(`{"RTT":12345,"Timestamp":1611118090,"Hostname":"foo"}`), &m)
+	ch <- ack.Event{Value: m, Ack: func() { acked = true }}
+	close(ch)
+
+	// the point should be written and acknowledged before it returns
+	wg.Wait()
+	assert.Equal(t, "tcpdog,Hostname=foo RTT=12345 1611118090000000000\n", string(body))
+	assert.True(t, acked)
+}
+
+func TestStartRetry(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.ServerConfig{
+		Ingestion: map[string]config.Ingestion{
+			"foo": {
+				Config: map[string]interface{}{
+					"url": server.URL,
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory2")
+	ctx := cfg.WithContext(context.Background())
+	ch := make(chan interface{}, 1)
+	wg := new(sync.WaitGroup)
+
+	err := Start(ctx, "foo", "json", ch, wg)
+	assert.NoError(t, err)
+
+	acked := false
+
+	m := map[string]interface{}{}
+	json.Unmarshal([]byte(`{"RTT":12345,"Timestamp":1611118090,"Hostname":"foo"}`), &m)
+	ch <- ack.Event{Value: m, Ack: func() { acked = true }}
+	close(ch)
+
+	// the failed batch should be retried and then acknowledged
+	wg.Wait()
+	assert.Equal(t, 2, requests)
+	assert.True(t, acked)
+}
+
 func TestPointJSON(t *testing.T) {
 	i := &influxdb{geo: &geoMock{}, cfg: &dbConfig{GeoField: "SAddr"}}
 
@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+// rateWindow is the window to calculate the agents' event rate.
+var rateWindow = 10 * time.Second
+
+// AgentInfo represents a connected agent.
+type AgentInfo struct {
+	Addr      string    `json:"addr"`
+	Hostname  string    `json:"hostname"`
+	Version   string    `json:"version"`
+	Identity  string    `json:"identity,omitempty"`
+	Connected time.Time `json:"connected"`
+	LastSeen  time.Time `json:"last_seen"`
+	Events    uint64    `json:"events"`
+	Bytes     uint64    `json:"bytes"`
+	Rate      float64   `json:"rate"` // events per second
+}
+
+// conn represents an agent's connection.
+type conn struct {
+	sync.Mutex
+	info         AgentInfo
+	window       time.Time
+	windowEvents uint64
+}
+
+type connKey struct{}
+
+var connected = struct {
+	sync.Mutex
+	conns map[*conn]struct{}
+}{conns: map[*conn]struct{}{}}
+
+// Agents returns the connected agents.
+func Agents() []AgentInfo {
+	connected.Lock()
+	defer connected.Unlock()
+
+	agents := make([]AgentInfo, 0, len(connected.conns))
+	for c := range connected.conns {
+		c.Lock()
+		agents = append(agents, c.info)
+		c.Unlock()
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Addr < agents[j].Addr
+	})
+
+	return agents
+}
+
+func connFromContext(ctx context.Context) *conn {
+	c, _ := ctx.Value(connKey{}).(*conn)
+	return c
+}
+
+func (c *conn) setMeta(md metadata.MD) {
+	c.Lock()
+	defer c.Unlock()
+
+	if v := md.Get("hostname"); len(v) > 0 {
+		c.info.Hostname = v[0]
+	}
+
+	if v := md.Get("version"); len(v) > 0 {
+		c.info.Version = v[0]
+	}
+}
+
+func (c *conn) setIdentity(id string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.info.Identity = id
+}
+
+// add counts the received events and bytes, the event rate is
+// calculated once the rate window is over.
+func (c *conn) add(events, bytes int, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.info.Events += uint64(events)
+	c.info.Bytes += uint64(bytes)
+	c.info.LastSeen = now
+	c.windowEvents += uint64(events)
+
+	if elapsed := now.Sub(c.window); elapsed >= rateWindow {
+		c.info.Rate = float64(c.windowEvents) / elapsed.Seconds()
+		c.window = now
+		c.windowEvents = 0
+	}
+}
+
+// statsHandler tracks the agents' connections, the connection
+// is carried by the context which is tagged per connection.
+type statsHandler struct {
+	logger *zap.Logger
+}
+
+func (h *statsHandler) TagRPC(ctx context.Context, s *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	c := connFromContext(ctx)
+	if c == nil {
+		return
+	}
+
+	switch s := s.(type) {
+	case *stats.InHeader:
+		c.setMeta(s.Header)
+	case *stats.InPayload:
+		events := 1
+		switch m := s.Payload.(type) {
+		case *pb.FieldsBatch:
+			events = len(m.Fields)
+		case *pb.FieldsSeq:
+			events = len(m.Fields)
+		}
+
+		c.add(events, s.WireLength, s.RecvTime)
+	}
+}
+
+func (h *statsHandler) TagConn(ctx context.Context, s *stats.ConnTagInfo) context.Context {
+	now := time.Now()
+
+	return context.WithValue(ctx, connKey{}, &conn{
+		info: AgentInfo{
+			Addr:      s.RemoteAddr.String(),
+			Connected: now,
+			LastSeen:  now,
+		},
+		window: now,
+	})
+}
+
+func (h *statsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	c := connFromContext(ctx)
+	if c == nil {
+		return
+	}
+
+	connected.Lock()
+	defer connected.Unlock()
+
+	switch s.(type) {
+	case *stats.ConnEnd:
+		delete(connected.conns, c)
+		h.logger.Info("grpc", zap.String("msg", fmt.Sprintf("%s has been disconnected", c.info.Addr)))
+	case *stats.ConnBegin:
+		connected.conns[c] = struct{}{}
+		h.logger.Info("grpc", zap.String("msg", fmt.Sprintf("%s has been connected", c.info.Addr)))
+	}
+}
@@ -0,0 +1,58 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+func TestStatsHandler(t *testing.T) {
+	cfg := config.ServerConfig{}
+	cfg.SetMockLogger("memory1")
+	h := &statsHandler{logger: cfg.Logger()}
+
+	addr1 := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}
+	addr2 := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5000}
+
+	ctx1 := h.TagConn(context.Background(), &stats.ConnTagInfo{RemoteAddr: addr1})
+	ctx2 := h.TagConn(context.Background(), &stats.ConnTagInfo{RemoteAddr: addr2})
+
+	h.HandleConn(ctx1, &stats.ConnBegin{})
+	h.HandleConn(ctx2, &stats.ConnBegin{})
+
+	h.HandleRPC(ctx1, &stats.InHeader{Header: metadata.Pairs("hostname", "host1", "version", "1.0.0")})
+
+	now := time.Now()
+	h.HandleRPC(ctx1, &stats.InPayload{Payload: &pb.Fields{}, WireLength: 10, RecvTime: now})
+	h.HandleRPC(ctx1, &stats.InPayload{
+		Payload:    &pb.FieldsBatch{Fields: []*pb.Fields{{}, {}, {}}},
+		WireLength: 30,
+		RecvTime:   now.Add(rateWindow),
+	})
+
+	agents := Agents()
+	assert.Len(t, agents, 2)
+	assert.Equal(t, addr1.String(), agents[0].Addr)
+	assert.Equal(t, "host1", agents[0].Hostname)
+	assert.Equal(t, "1.0.0", agents[0].Version)
+	assert.Equal(t, uint64(4), agents[0].Events)
+	assert.Equal(t, uint64(40), agents[0].Bytes)
+	assert.Greater(t, agents[0].Rate, 0.0)
+
+	// the connections are tracked independently
+	h.HandleConn(ctx1, &stats.ConnEnd{})
+	agents = Agents()
+	assert.Len(t, agents, 1)
+	assert.Equal(t, addr2.String(), agents[0].Addr)
+
+	h.HandleConn(ctx2, &stats.ConnEnd{})
+	assert.Len(t, Agents(), 0)
+}
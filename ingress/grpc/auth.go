@@ -0,0 +1,151 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+// Agent represents an allowed agent, it's identified by
+// its client certificate common name or its bearer token.
+type Agent struct {
+	Identity string
+	CN       string
+	Token    string
+}
+
+type identityKey struct{}
+
+// authenticator authenticates the agents' streams based on the allow-list.
+type authenticator struct {
+	cns    map[string]string
+	tokens map[string]string
+}
+
+func newAuthenticator(agents []Agent) (*authenticator, error) {
+	a := &authenticator{
+		cns:    map[string]string{},
+		tokens: map[string]string{},
+	}
+
+	for _, agent := range agents {
+		if agent.Identity == "" {
+			return nil, errors.New("agent identity is empty")
+		}
+
+		if agent.CN == "" && agent.Token == "" {
+			return nil, errors.New("agent " + agent.Identity + " requires cn or token")
+		}
+
+		if agent.CN != "" {
+			a.cns[agent.CN] = agent.Identity
+		}
+
+		if agent.Token != "" {
+			a.tokens[agent.Token] = agent.Identity
+		}
+	}
+
+	return a, nil
+}
+
+// authenticate returns the agent identity based on the verified
+// client certificate or the bearer token at the metadata.
+func (a *authenticator) authenticate(ctx context.Context) (string, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			for _, chain := range info.State.VerifiedChains {
+				if len(chain) < 1 {
+					continue
+				}
+
+				if id, ok := a.cns[chain[0].Subject.CommonName]; ok {
+					return id, nil
+				}
+			}
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if !strings.HasPrefix(v, "Bearer ") {
+			continue
+		}
+
+		token := []byte(strings.TrimPrefix(v, "Bearer "))
+		for t, id := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(t), token) == 1 {
+				return id, nil
+			}
+		}
+	}
+
+	return "", status.Error(codes.Unauthenticated, "unknown agent")
+}
+
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	if c := connFromContext(ss.Context()); c != nil {
+		c.setIdentity(id)
+	}
+
+	return handler(srv, &identityStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ss.Context(), identityKey{}, id),
+	})
+}
+
+// identityStream carries the agent identity through the stream's context.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// identity returns the agent identity of the stream,
+// it's empty if the authentication isn't configured.
+func identity(ctx context.Context) string {
+	id, _ := ctx.Value(identityKey{}).(string)
+	return id
+}
+
+// stamp overwrites the event's hostname by the agent identity
+// so an agent can't impersonate the other agents.
+func stamp(id string, msg interface{}) {
+	if id == "" {
+		return
+	}
+
+	switch m := msg.(type) {
+	case *pb.Fields:
+		m.Hostname = &id
+	case *pb.FieldsSPB:
+		if m.Fields == nil {
+			m.Fields = &structpb.Struct{}
+		}
+
+		if m.Fields.Fields == nil {
+			m.Fields.Fields = map[string]*structpb.Value{}
+		}
+
+		m.Fields.Fields["Hostname"] = structpb.NewStringValue(id)
+	}
+}
@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/mehrdadrad/tcpdog/proto"
+)
+
+func TestAuthenticate(t *testing.T) {
+	auth, err := newAuthenticator([]Agent{
+		{Identity: "agent1", CN: "agent1.example.com"},
+		{Identity: "agent2", Token: "secret"},
+	})
+	assert.NoError(t, err)
+
+	// bearer token
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	id, err := auth.authenticate(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "agent2", id)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer foo"))
+	_, err = auth.authenticate(ctx)
+	assert.Error(t, err)
+
+	// client certificate
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent1.example.com"}}
+	ctx = peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+	id, err = auth.authenticate(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "agent1", id)
+
+	_, err = auth.authenticate(context.Background())
+	assert.Error(t, err)
+
+	// invalid allow-list
+	_, err = newAuthenticator([]Agent{{Identity: "agent3"}})
+	assert.Error(t, err)
+}
+
+func TestStamp(t *testing.T) {
+	hostname := "agent2"
+	fields := &pb.Fields{Hostname: &hostname}
+	stamp("agent1", fields)
+	assert.Equal(t, "agent1", *fields.Hostname)
+
+	spb, _ := structpb.NewStruct(map[string]interface{}{"Hostname": "agent2"})
+	fieldsSPB := &pb.FieldsSPB{Fields: spb}
+	stamp("agent1", fieldsSPB)
+	assert.Equal(t, "agent1", fieldsSPB.Fields.Fields["Hostname"].GetStringValue())
+
+	// no authentication
+	stamp("", fields)
+	assert.Equal(t, "agent1", *fields.Hostname)
+}
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/peer"
+)
+
+// dropLogInterval is the minimum interval between the drop logs of an agent.
+var dropLogInterval = 10 * time.Second
+
+// drops counts the dropped events per agent and rate-limits the drop logs.
+type drops struct {
+	sync.Mutex
+	agents map[string]*agentDrops
+}
+
+type agentDrops struct {
+	total    uint64
+	unlogged uint64
+	logged   time.Time
+}
+
+var dropped = &drops{agents: map[string]*agentDrops{}}
+
+func (d *drops) add(agent string, logger *zap.Logger) {
+	d.Lock()
+	defer d.Unlock()
+
+	a, ok := d.agents[agent]
+	if !ok {
+		a = &agentDrops{}
+		d.agents[agent] = a
+	}
+
+	a.total++
+	a.unlogged++
+
+	if time.Since(a.logged) < dropLogInterval {
+		return
+	}
+
+	logger.Error("grpc", zap.String("msg", "data has been dropped"),
+		zap.String("agent", agent), zap.Uint64("count", a.unlogged))
+
+	a.unlogged = 0
+	a.logged = time.Now()
+}
+
+// Dropped returns the number of the dropped events per agent.
+func Dropped() map[string]uint64 {
+	dropped.Lock()
+	defer dropped.Unlock()
+
+	m := make(map[string]uint64, len(dropped.agents))
+	for agent, a := range dropped.agents {
+		m[agent] = a.total
+	}
+
+	return m
+}
+
+// enqueue hands over the event to the flow, it blocks the stream up to the
+// enqueue timeout so the backpressure reaches the agent through the grpc
+// flow control. the event is dropped once the timeout is exceeded.
+func (s *Server) enqueue(ctx context.Context, agent string, msg interface{}) {
+	select {
+	case s.ch <- msg:
+		return
+	default:
+	}
+
+	if s.timeout > 0 {
+		t := time.NewTimer(s.timeout)
+		defer t.Stop()
+
+		select {
+		case s.ch <- msg:
+			return
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+
+	dropped.add(agent, s.logger)
+}
+
+// agentName returns the agent identity if it's authenticated
+// otherwise the agent's ip address.
+func agentName(ctx context.Context) string {
+	if id := identity(ctx); id != "" {
+		return id
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
@@ -10,13 +10,20 @@ import (
 type Config struct {
 	Addr             string
 	NumStreamWorkers uint32
+	MaxRecvMsgSize   int // bytes
+	KeepaliveMinTime int // seconds
+	EnqueueTimeout   int // millisecond, the events are dropped after the timeout
 	TLSConfig        *config.TLSConfig
+	Agents           []Agent // allow-list, all the agents are allowed if it's empty
 }
 
 func grpcConfig(cfg map[string]interface{}) *Config {
 	// default configuration
 	conf := &Config{
-		Addr: ":8085",
+		Addr:             ":8085",
+		MaxRecvMsgSize:   4 << 20,
+		KeepaliveMinTime: 10,
+		EnqueueTimeout:   1000,
 	}
 
 	if err := config.Transform(cfg, conf); err != nil {
@@ -2,82 +2,129 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"io"
 	"net"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/stats"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+	"google.golang.org/grpc/keepalive"
 
 	"github.com/mehrdadrad/tcpdog/config"
+	_ "github.com/mehrdadrad/tcpdog/egress/grpc/snappy" // registers the snappy compressor
 	pb "github.com/mehrdadrad/tcpdog/proto"
 )
 
 // Server represents gRPC server
 type Server struct {
-	ch     chan interface{}
-	logger *zap.Logger
+	ch       chan interface{}
+	logger   *zap.Logger
+	timeout  time.Duration // enqueue timeout
+	handlers sync.WaitGroup
 }
 
 // Tracepoint receives protobuf messages
 func (s *Server) Tracepoint(srv pb.TCPDog_TracepointServer) error {
+	s.handlers.Add(1)
+	defer s.handlers.Done()
+
+	id := identity(srv.Context())
+	agent := agentName(srv.Context())
+
 	for {
 		fields, err := srv.Recv()
 		if err != nil {
 			return err
 		}
 
-		select {
-		case s.ch <- fields:
-		default:
-			s.logger.Error("grpc", zap.String("msg", "data has been dropped"))
-		}
+		stamp(id, fields)
+
+		s.enqueue(srv.Context(), agent, fields)
 	}
 }
 
 // TracepointSPB receives struct protobuf messages
 func (s *Server) TracepointSPB(srv pb.TCPDog_TracepointSPBServer) error {
+	s.handlers.Add(1)
+	defer s.handlers.Done()
+
+	id := identity(srv.Context())
+	agent := agentName(srv.Context())
+
 	for {
 		fields, err := srv.Recv()
 		if err != nil {
 			return err
 		}
 
-		select {
-		case s.ch <- fields:
-		default:
-			s.logger.Error("grpc", zap.String("msg", "data has been dropped"))
-		}
+		stamp(id, fields)
+
+		s.enqueue(srv.Context(), agent, fields)
 	}
 }
 
-type statsHandler struct {
-	remoteAddr net.Addr
-	logger     *zap.Logger
-}
+// TracepointBatch receives batched protobuf messages, the fields
+// are handed over to the ingestion one by one.
+func (s *Server) TracepointBatch(srv pb.TCPDog_TracepointBatchServer) error {
+	s.handlers.Add(1)
+	defer s.handlers.Done()
 
-func (h *statsHandler) TagRPC(ctx context.Context, s *stats.RPCTagInfo) context.Context {
-	return ctx
-}
+	id := identity(srv.Context())
+	agent := agentName(srv.Context())
+
+	for {
+		batch, err := srv.Recv()
+		if err != nil {
+			return err
+		}
 
-func (h *statsHandler) HandleRPC(context.Context, stats.RPCStats) {}
+		for _, fields := range batch.Fields {
+			stamp(id, fields)
 
-func (h *statsHandler) TagConn(ctx context.Context, s *stats.ConnTagInfo) context.Context {
-	h.remoteAddr = s.RemoteAddr
-	return ctx
+			s.enqueue(srv.Context(), agent, fields)
+		}
+	}
 }
 
-func (h *statsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
-	switch s.(type) {
-	case *stats.ConnEnd:
-		h.logger.Info("grpc", zap.String("msg", fmt.Sprintf("%s has been disconnected", h.remoteAddr)))
-	case *stats.ConnBegin:
-		h.logger.Info("grpc", zap.String("msg", fmt.Sprintf("%s has been connected", h.remoteAddr)))
+// TracepointAck receives protobuf messages with sequence numbers, it
+// acknowledges the sequence number once the fields have been enqueued.
+// it waits for the ingestion instead of dropping the fields.
+func (s *Server) TracepointAck(srv pb.TCPDog_TracepointAckServer) error {
+	s.handlers.Add(1)
+	defer s.handlers.Done()
+
+	id := identity(srv.Context())
+
+	for {
+		msg, err := srv.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, fields := range msg.Fields {
+			stamp(id, fields)
+
+			select {
+			case s.ch <- fields:
+			case <-srv.Context().Done():
+				return srv.Context().Err()
+			}
+		}
+
+		if err := srv.Send(&pb.Ack{Seq: msg.Seq}); err != nil {
+			return err
+		}
 	}
 }
 
-// Start starts gRPC server
-func Start(ctx context.Context, name string, ch chan interface{}) error {
+// Start starts gRPC server, once the context is done it stops the server
+// and closes the channel after all the streams have been returned.
+func Start(ctx context.Context, name string, ch chan interface{}, wg *sync.WaitGroup) error {
 	gCfg := grpcConfig(config.FromContextServer(ctx).Ingress[name].Config)
 	logger := config.FromContextServer(ctx).Logger()
 
@@ -85,9 +132,10 @@ func Start(ctx context.Context, name string, ch chan interface{}) error {
 	if err != nil {
 		return err
 	}
-	srv := Server{
-		ch:     ch,
-		logger: logger,
+	srv := &Server{
+		ch:      ch,
+		logger:  logger,
+		timeout: time.Duration(gCfg.EnqueueTimeout) * time.Millisecond,
 	}
 
 	opts, err := getServerOpts(gCfg, logger)
@@ -96,11 +144,25 @@ func Start(ctx context.Context, name string, ch chan interface{}) error {
 	}
 
 	gServer := grpc.NewServer(opts...)
-	pb.RegisterTCPDogServer(gServer, &srv)
+	pb.RegisterTCPDogServer(gServer, srv)
 
 	go func() {
 		err := gServer.Serve(l)
-		logger.Fatal("grpc", zap.Error(err))
+		if err != nil {
+			logger.Fatal("grpc", zap.Error(err))
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+
+		// the agents' streams never end so stop doesn't wait for them
+		gServer.Stop()
+		srv.handlers.Wait()
+		close(ch)
 	}()
 
 	return nil
@@ -117,7 +179,24 @@ func getServerOpts(gCfg *Config, logger *zap.Logger) ([]grpc.ServerOption, error
 		opts = append(opts, grpc.Creds(creds))
 	}
 
+	// the agents are authenticated if the allow-list is configured
+	if len(gCfg.Agents) > 0 {
+		auth, err := newAuthenticator(gCfg.Agents)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.StreamInterceptor(auth.streamInterceptor))
+	}
+
 	opts = append(opts, grpc.StatsHandler(&statsHandler{logger: logger}))
+	opts = append(opts, grpc.MaxRecvMsgSize(gCfg.MaxRecvMsgSize))
+
+	// the agents' keepalive pings shouldn't be more frequent than the min time
+	opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             time.Duration(gCfg.KeepaliveMinTime) * time.Second,
+		PermitWithoutStream: true,
+	}))
 
 	return opts, nil
 }
@@ -3,6 +3,7 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,7 +22,8 @@ func TestStart(t *testing.T) {
 			"foo": {
 				Type: "grpc",
 				Config: map[string]interface{}{
-					"addr": ":8085",
+					"addr":           ":8085",
+					"enqueueTimeout": 100,
 				},
 			},
 		},
@@ -33,7 +35,8 @@ func TestStart(t *testing.T) {
 	ctx = cfg.WithContext(ctx)
 	ch := make(chan interface{}, 1)
 
-	Start(ctx, "foo", ch)
+	wg := new(sync.WaitGroup)
+	Start(ctx, "foo", ch, wg)
 
 	// client
 	conn, err := grpc.Dial("localhost:8085", grpc.WithInsecure())
@@ -108,16 +111,54 @@ func TestStart(t *testing.T) {
 		t.Fatal("time exceeded")
 	}
 
-	// drop PB
+	// drop PB, the drop logs are rate-limited
 	ms.Reset()
 	for i := 0; i < 2; i++ {
 		err = streamPB.Send(&pb.Fields{})
 		assert.NoError(t, err)
 	}
 	time.Sleep(time.Second)
-	assert.Equal(t, "data has been dropped", ms.Unmarshal()["msg"])
+	assert.Empty(t, ms.Unmarshal()["msg"])
+	assert.Equal(t, uint64(2), Dropped()["127.0.0.1"])
+
+	<-ch // empty the channel
+
+	// batch with compression
+	streamBatch, err := client.TracepointBatch(ctx, grpc.UseCompressor("snappy"))
+	assert.NoError(t, err)
+
+	err = streamBatch.Send(&pb.FieldsBatch{
+		Fields: []*pb.Fields{{RTT: &rtt, Timestamp: &timestamp}},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case a := <-ch:
+		assert.Equal(t, uint32(10), *a.(*pb.Fields).RTT)
+	case <-time.After(time.Second):
+		t.Fatal("time exceeded")
+	}
+
+	// ack
+	streamAck, err := client.TracepointAck(ctx)
+	assert.NoError(t, err)
+
+	err = streamAck.Send(&pb.FieldsSeq{
+		Seq:    5,
+		Fields: []*pb.Fields{{RTT: &rtt, Timestamp: &timestamp}},
+	})
+	assert.NoError(t, err)
 
-	// recv err
+	<-ch
+
+	ack, err := streamAck.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), ack.Seq)
+
+	// stop, the channel should be closed after the streams returned
 	cancel()
-	time.Sleep(time.Second)
+	wg.Wait()
+
+	for range ch {
+	}
 }
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"log"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents http ingress configuration
+type Config struct {
+	Addr           string
+	Path           string
+	MaxBodySize    int64 // bytes, after decompression
+	EnqueueTimeout int   // millisecond, the events are dropped after the timeout
+	TLSConfig      *config.TLSConfig
+	Agents         []Agent // allow-list, all the requests are allowed if it's empty
+}
+
+// Agent represents an allowed producer, it's identified by its bearer token.
+type Agent struct {
+	Identity string
+	Token    string
+}
+
+func httpConfig(cfg map[string]interface{}) *Config {
+	// default configuration
+	conf := &Config{
+		Addr:           ":8088",
+		Path:           "/events",
+		MaxBodySize:    16 << 20,
+		EnqueueTimeout: 1000,
+	}
+
+	if err := config.Transform(cfg, conf); err != nil {
+		log.Fatal(err)
+	}
+
+	return conf
+}
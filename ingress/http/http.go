@@ -0,0 +1,351 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// shutdownTimeout is the time to wait for the in-flight requests at shutdown.
+var shutdownTimeout = 5 * time.Second
+
+var (
+	errTooLarge     = errors.New("request body too large")
+	errInvalidEvent = errors.New("invalid event")
+	errBusy         = errors.New("ingestion is busy")
+)
+
+// Server represents http server, it accepts the events as a json
+// array or newline delimited json (the agent's json serialization).
+type Server struct {
+	ch       chan interface{}
+	logger   *zap.Logger
+	timeout  time.Duration // enqueue timeout
+	maxBody  int64
+	tokens   map[string]string
+	handlers sync.WaitGroup
+}
+
+type response struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newServer(hCfg *Config, ch chan interface{}, logger *zap.Logger) (*Server, error) {
+	s := &Server{
+		ch:      ch,
+		logger:  logger,
+		timeout: time.Duration(hCfg.EnqueueTimeout) * time.Millisecond,
+		maxBody: hCfg.MaxBodySize,
+		tokens:  map[string]string{},
+	}
+
+	for _, agent := range hCfg.Agents {
+		if agent.Identity == "" || agent.Token == "" {
+			return nil, errors.New("agent requires identity and token")
+		}
+
+		s.tokens[agent.Token] = agent.Identity
+	}
+
+	return s, nil
+}
+
+// ServeHTTP enqueues the request's events, it responds the number of
+// the accepted events so the client can resend the rest on failure.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handlers.Add(1)
+	defer s.handlers.Done()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(w, http.StatusMethodNotAllowed, 0, errors.New("method not allowed"))
+		return
+	}
+
+	id, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		reply(w, http.StatusUnauthorized, 0, errors.New("unknown agent"))
+		return
+	}
+
+	var body io.Reader = r.Body
+
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			reply(w, http.StatusBadRequest, 0, err)
+			return
+		}
+		defer gz.Close()
+
+		body = gz
+	default:
+		reply(w, http.StatusUnsupportedMediaType, 0, errors.New("unsupported content encoding"))
+		return
+	}
+
+	// the limit applies to the decompressed body
+	if s.maxBody > 0 {
+		body = &limitReader{r: body, n: s.maxBody}
+	}
+
+	accepted := 0
+	err := decode(body, func(m map[string]interface{}) error {
+		if id != "" {
+			m["Hostname"] = id
+		}
+
+		if !s.enqueue(r.Context(), m) {
+			return errBusy
+		}
+
+		accepted++
+
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		reply(w, http.StatusOK, accepted, nil)
+	case errors.Is(err, errBusy):
+		w.Header().Set("Retry-After", "1")
+		reply(w, http.StatusServiceUnavailable, accepted, err)
+	case errors.Is(err, errTooLarge):
+		reply(w, http.StatusRequestEntityTooLarge, accepted, err)
+	default:
+		reply(w, http.StatusBadRequest, accepted, err)
+	}
+}
+
+// authenticate returns the agent identity based on the bearer token,
+// it's empty if the allow-list isn't configured.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	if len(s.tokens) < 1 {
+		return "", true
+	}
+
+	v := r.Header.Get("Authorization")
+	if !strings.HasPrefix(v, "Bearer ") {
+		return "", false
+	}
+
+	token := []byte(strings.TrimPrefix(v, "Bearer "))
+	for t, id := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), token) == 1 {
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
+// enqueue hands over the event to the flow, it waits up to the enqueue
+// timeout and returns false if the event couldn't be enqueued.
+func (s *Server) enqueue(ctx context.Context, m map[string]interface{}) bool {
+	select {
+	case s.ch <- m:
+		return true
+	default:
+	}
+
+	if s.timeout <= 0 {
+		return false
+	}
+
+	t := time.NewTimer(s.timeout)
+	defer t.Stop()
+
+	select {
+	case s.ch <- m:
+		return true
+	case <-ctx.Done():
+	case <-t.C:
+	}
+
+	return false
+}
+
+// decode decodes a json array or newline delimited json events,
+// it stops once the handler returns an error.
+func decode(r io.Reader, handler func(map[string]interface{}) error) error {
+	br := bufio.NewReader(r)
+
+	// skips the leading white spaces to find out the format
+	var c byte
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		c = b[0]
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			break
+		}
+
+		br.ReadByte()
+	}
+
+	dec := json.NewDecoder(br)
+
+	next := func() error {
+		m := map[string]interface{}{}
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+
+		if m == nil {
+			return errInvalidEvent
+		}
+
+		return handler(m)
+	}
+
+	if c != '[' {
+		for {
+			err := next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// json array
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		if err := next(); err != nil {
+			return err
+		}
+	}
+
+	_, err := dec.Token()
+
+	return err
+}
+
+// limitReader returns errTooLarge if the reader exceeds the limit.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errTooLarge
+	}
+
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+
+	if l.n < 0 {
+		return n, errTooLarge
+	}
+
+	return n, err
+}
+
+func reply(w http.ResponseWriter, code, accepted int, err error) {
+	resp := response{Accepted: accepted}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Start starts http server, once the context is done it shuts down the
+// server and closes the channel after all the requests have been returned.
+// the server is shut down the same way if it fails, so the flow ends.
+func Start(ctx context.Context, name string, ch chan interface{}, wg *sync.WaitGroup) error {
+	hCfg := httpConfig(config.FromContextServer(ctx).Ingress[name].Config)
+	logger := config.FromContextServer(ctx).Logger()
+
+	srv, err := newServer(hCfg, ch, logger)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", hCfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	if hCfg.TLSConfig != nil && hCfg.TLSConfig.Enable {
+		tlsConfig, err := config.GetTLS(hCfg.TLSConfig)
+		if err != nil {
+			l.Close()
+			return err
+		}
+
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(hCfg.Path, srv)
+
+	hServer := &http.Server{Handler: mux}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go serve(hServer, l, cancel, logger)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+
+		<-ctx.Done()
+
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := hServer.Shutdown(sctx); err != nil {
+			hServer.Close()
+		}
+
+		srv.handlers.Wait()
+		close(ch)
+	}()
+
+	return nil
+}
+
+// serve serves the http requests, it cancels the ingress if the server fails.
+func serve(hServer *http.Server, l net.Listener, cancel context.CancelFunc, logger *zap.Logger) {
+	if err := hServer.Serve(l); err != http.ErrServerClosed {
+		logger.Error("http", zap.Error(err))
+		cancel()
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+func TestStart(t *testing.T) {
+	cfg := config.ServerConfig{
+		Ingress: map[string]config.Ingress{
+			"foo": {
+				Type: "http",
+				Config: map[string]interface{}{
+					"addr": ":8088",
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = cfg.WithContext(ctx)
+	ch := make(chan interface{}, 2)
+
+	wg := new(sync.WaitGroup)
+	err := Start(ctx, "foo", ch, wg)
+	assert.NoError(t, err)
+
+	resp, err := http.Post("http://localhost:8088/events", "application/json",
+		strings.NewReader(`[{"RTT":12345,"Task":"curl"},{"RTT":5}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	m := (<-ch).(map[string]interface{})
+	assert.Equal(t, float64(12345), m["RTT"])
+	assert.Equal(t, "curl", m["Task"])
+	<-ch
+
+	cancel()
+	wg.Wait()
+
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestServeFailed(t *testing.T) {
+	cfg := config.ServerConfig{}
+	cfg.SetMockLogger("memory")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the ingress is canceled once the server fails
+	serve(&http.Server{}, l, cancel, cfg.Logger())
+	assert.Error(t, ctx.Err())
+}
+
+func TestServeHTTP(t *testing.T) {
+	ch := make(chan interface{}, 10)
+	srv, err := newServer(&Config{
+		MaxBodySize: 64,
+		Agents:      []Agent{{Identity: "agent1", Token: "secret"}},
+	}, ch, nil)
+	assert.NoError(t, err)
+
+	post := func(body []byte, header map[string]string) (int, response) {
+		r := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+
+		resp := response{}
+		json.NewDecoder(w.Body).Decode(&resp)
+
+		return w.Code, resp
+	}
+
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	// unauthorized
+	code, _ := post([]byte(`{"RTT":5}`), nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = post([]byte(`{"RTT":5}`), map[string]string{"Authorization": "Bearer foo"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// ndjson, the hostname is stamped by the identity
+	code, resp := post([]byte("{\"RTT\":5,\"Hostname\":\"foo\"}\n{\"RTT\":6}\n"), auth)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, resp.Accepted)
+	assert.Equal(t, "agent1", (<-ch).(map[string]interface{})["Hostname"])
+	assert.Equal(t, float64(6), (<-ch).(map[string]interface{})["RTT"])
+
+	// gzip
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(` [{"RTT":7}]`))
+	gz.Close()
+
+	code, resp = post(buf.Bytes(), map[string]string{"Authorization": "Bearer secret", "Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, float64(7), (<-ch).(map[string]interface{})["RTT"])
+
+	// invalid json
+	code, resp = post([]byte(`[{"RTT":8},5]`), auth)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, 1, resp.Accepted)
+	<-ch
+
+	// too large
+	code, _ = post([]byte(`[{"Task":"`+strings.Repeat("a", 64)+`"}]`), auth)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	// busy
+	for i := 0; i < 10; i++ {
+		ch <- nil
+	}
+
+	code, resp = post([]byte(`{"RTT":9}`), auth)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, 0, resp.Accepted)
+
+	// method
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestDecode(t *testing.T) {
+	var events []map[string]interface{}
+	handler := func(m map[string]interface{}) error {
+		events = append(events, m)
+		return nil
+	}
+
+	assert.NoError(t, decode(strings.NewReader(""), handler))
+	assert.NoError(t, decode(strings.NewReader("\n[]\n"), handler))
+	assert.Len(t, events, 0)
+
+	assert.NoError(t, decode(strings.NewReader("{\"F1\":1}\r\n{\"F1\":2}"), handler))
+	assert.Len(t, events, 2)
+
+	assert.Error(t, decode(strings.NewReader("null"), handler))
+	assert.Error(t, decode(strings.NewReader(`[{"F1":1}`), handler))
+}
+
+func TestLimitReader(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_, err := buf.ReadFrom(&limitReader{r: strings.NewReader("1234"), n: 4})
+	assert.NoError(t, err)
+
+	_, err = buf.ReadFrom(&limitReader{r: strings.NewReader("12345"), n: 4})
+	assert.Equal(t, errTooLarge, err)
+
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Shopify/sarama"
 
 	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/sasl"
 )
 
 var kafkaVersion = map[string]sarama.KafkaVersion{
@@ -44,6 +45,11 @@ type Config struct {
 	Workers      int
 	Version      string
 
+	SASLMechanism string // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER
+	SASLUsername  string
+	SASLPassword  string
+	SASLTokenFile string
+
 	TLSConfig config.TLSConfig
 }
 
@@ -68,9 +74,21 @@ func saramaConfig(kCfg *Config) (*sarama.Config, error) {
 	sConfig := sarama.NewConfig()
 	sConfig.ClientID = "tcpdog"
 	sConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	// the offsets are committed once the events have been stored
+	sConfig.Consumer.Offsets.AutoCommit.Enable = false
 	sConfig.Consumer.Retry.Backoff = time.Duration(kCfg.RetryBackoff) * time.Second
 	sConfig.Version = kafkaVersion[kCfg.Version]
 
+	err := sasl.Set(sConfig, sasl.Config{
+		Mechanism: kCfg.SASLMechanism,
+		Username:  kCfg.SASLUsername,
+		Password:  kCfg.SASLPassword,
+		TokenFile: kCfg.SASLTokenFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	if kCfg.TLSConfig.Enable {
 		tlsConfig, err := config.GetTLS(&kCfg.TLSConfig)
 		if err != nil {
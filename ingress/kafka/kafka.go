@@ -2,15 +2,15 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/proto"
 
+	"github.com/mehrdadrad/tcpdog/ack"
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/egress/helper"
-	pb "github.com/mehrdadrad/tcpdog/proto"
 )
 
 type consumerGroup struct {
@@ -19,20 +19,119 @@ type consumerGroup struct {
 	serialization string
 }
 
+// commitInterval is the interval to commit the marked offsets.
+var commitInterval = time.Second
+
+// drainTimeout is the time to wait at rebalance for the handed over
+// events to be acknowledged, the unacknowledged events are redelivered.
+var drainTimeout = 10 * time.Second
+
 type handler struct {
-	ch chan []byte
+	ch      chan message
+	ctx     context.Context
+	stopped <-chan struct{}
+	stop    func()
+
+	mu     sync.Mutex
+	claims []*offsets    // claims of the current session
+	commit chan struct{} // closed once the commit loop has returned
+}
+
+// message represents a consumed message and its acknowledgement.
+type message struct {
+	value []byte
+	ack   func()
+}
+
+// Setup starts committing the marked offsets periodically.
+func (h *handler) Setup(session sarama.ConsumerGroupSession) error {
+	h.mu.Lock()
+	h.claims = nil
+	h.commit = make(chan struct{})
+	h.mu.Unlock()
+
+	go func() {
+		defer close(h.commit)
+
+		ticker := time.NewTicker(commitInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				session.Commit()
+			case <-session.Context().Done():
+				return
+			}
+		}
+	}()
+
+	return nil
 }
 
-func (h handler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (h handler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
-func (h handler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for message := range claim.Messages() {
-		h.ch <- message.Value
-		session.MarkMessage(message, "")
+// Cleanup commits the offsets of the acknowledged events, it waits for
+// the ingestion to stop at shutdown or for the handed over events to
+// be acknowledged at rebalance.
+func (h *handler) Cleanup(session sarama.ConsumerGroupSession) error {
+	<-h.commit
+
+	if h.ctx.Err() != nil {
+		h.stop()
+
+		if h.stopped != nil {
+			<-h.stopped
+		}
+	} else {
+		h.drain()
 	}
+
+	session.Commit()
+
 	return nil
 }
 
+// ConsumeClaim hands over the messages to the workers, the offsets
+// are marked once the ingestion has acknowledged the events.
+func (h *handler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	o := newOffsets(session, claim.Topic(), claim.Partition())
+
+	h.mu.Lock()
+	h.claims = append(h.claims, o)
+	h.mu.Unlock()
+
+	for m := range claim.Messages() {
+		h.ch <- message{value: m.Value, ack: o.add(m.Offset)}
+	}
+
+	return nil
+}
+
+// drain waits for the claims' events to be acknowledged up to the drain timeout.
+func (h *handler) drain() {
+	timeout := time.After(drainTimeout)
+
+	for h.pending() > 0 {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// pending returns the number of the unacknowledged events.
+func (h *handler) pending() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, o := range h.claims {
+		n += o.len()
+	}
+
+	return n
+}
+
 func newConsumerGroup(logger *zap.Logger, kCfg *Config) (*consumerGroup, error) {
 	var err error
 
@@ -52,8 +151,13 @@ func newConsumerGroup(logger *zap.Logger, kCfg *Config) (*consumerGroup, error)
 	}, nil
 }
 
-// Start starts a consumer group
-func Start(ctx context.Context, name string, ser string, ch chan interface{}) error {
+// Start starts a consumer group, the events are handed over with their
+// acknowledgements and the offsets are committed once the ingestion has
+// stored the events. once the context is done it drains the consumed
+// messages, closes the channel and commits the offsets after the stopped
+// channel has been closed (the ingestion has stopped). the events which
+// haven't been acknowledged are redelivered after a rebalance or a restart.
+func Start(ctx context.Context, name string, ser string, ch chan interface{}, wg *sync.WaitGroup, stopped <-chan struct{}) error {
 	kCfg := kafkaConfig(config.FromContextServer(ctx).Ingress[name].Config)
 	logger := config.FromContextServer(ctx).Logger()
 
@@ -71,30 +175,58 @@ func Start(ctx context.Context, name string, ser string, ch chan interface{}) er
 		}
 	}()
 
-	handler := handler{
-		ch: make(chan []byte, 1),
+	var (
+		workers sync.WaitGroup
+		once    sync.Once
+	)
+
+	handler := &handler{
+		ch:      make(chan message, 1),
+		ctx:     ctx,
+		stopped: stopped,
+	}
+
+	handler.stop = func() {
+		once.Do(func() {
+			close(handler.ch)
+			workers.Wait()
+			close(ch)
+		})
 	}
 
 	// consumer group
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+
 		backoff := helper.NewBackoff(logger)
 
 		for {
 			backoff.Next()
 
 			err := cg.group.Consume(ctx, []string{kCfg.Topic}, handler)
+			if ctx.Err() != nil {
+				break
+			}
+
 			if err != nil {
 				logger.Error("kafka", zap.Error(err))
 			} else {
 				logger.Warn("kafka", zap.String("msg", "consumer group has been terminated"))
-				cg.consumerGroupCleanup()
-				return
+				break
 			}
 		}
+
+		handler.stop()
+		cg.consumerGroupCleanup()
 	}()
 
 	for i := 0; i < kCfg.Workers; i++ {
-		go cg.worker(ctx, ch, handler.ch)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			cg.worker(ctx, ch, handler.ch)
+		}()
 	}
 
 	return nil
@@ -104,42 +236,18 @@ func (k *consumerGroup) consumerGroupCleanup() {
 	k.group.Close()
 }
 
-func (k *consumerGroup) worker(ctx context.Context, ch chan interface{}, bCh chan []byte) {
-	unmarshal := getUnmarshal(k.serialization)
+func (k *consumerGroup) worker(ctx context.Context, ch chan interface{}, mCh chan message) {
+	unmarshal := helper.GetUnmarshal(k.serialization)
 
-	for {
-		b := <-bCh
-		i, err := unmarshal(b)
+	for m := range mCh {
+		i, err := unmarshal(m.value)
 		if err != nil {
 			k.logger.Error("kafka", zap.String("event", "marshal"), zap.Error(err))
+			// the invalid message is skipped
+			m.ack()
 			continue
 		}
 
-		ch <- i
+		ch <- ack.Event{Value: i, Ack: m.ack}
 	}
 }
-
-func getUnmarshal(ser string) func(b []byte) (interface{}, error) {
-	switch ser {
-	case "json":
-		return func(b []byte) (interface{}, error) {
-			m := map[string]interface{}{}
-			err := json.Unmarshal(b, &m)
-			return m, err
-		}
-	case "spb":
-		return func(b []byte) (interface{}, error) {
-			p := pb.FieldsSPB{}
-			err := proto.Unmarshal(b, &p)
-			return &p, err
-		}
-	case "pb":
-		return func(b []byte) (interface{}, error) {
-			p := pb.Fields{}
-			err := proto.Unmarshal(b, &p)
-			return &p, err
-		}
-	}
-
-	return nil
-}
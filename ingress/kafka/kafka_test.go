@@ -2,68 +2,17 @@ package kafka
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
-	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/types/known/structpb"
+	"go.uber.org/zap"
 
+	"github.com/mehrdadrad/tcpdog/ack"
 	"github.com/mehrdadrad/tcpdog/config"
-	pb "github.com/mehrdadrad/tcpdog/proto"
 )
 
-func TestGetUnmarshalJSON(t *testing.T) {
-	f := getUnmarshal("json")
-	b := []byte(`{"F1":5,"Timestamp":1611634115,"Hostname":"foo"}`)
-	v, err := f(b)
-	assert.NoError(t, err)
-
-	m := v.(map[string]interface{})
-	assert.Equal(t, float64(5), m["F1"])
-	assert.Equal(t, float64(1611634115), m["Timestamp"])
-	assert.Equal(t, "foo", m["Hostname"])
-}
-
-func TestGetUnmarshalPB(t *testing.T) {
-	f := getUnmarshal("pb")
-	r := uint32(5)
-	s := "foo"
-	p := pb.Fields{RTT: &r, Hostname: &s}
-	b, err := proto.Marshal(&p)
-	assert.NoError(t, err)
-
-	v, err := f(b)
-	assert.NoError(t, err)
-
-	m := v.(*pb.Fields)
-	assert.Equal(t, uint32(5), *m.RTT)
-	assert.Equal(t, "foo", *m.Hostname)
-}
-
-func TestGetUnmarshalSPB(t *testing.T) {
-	f := getUnmarshal("spb")
-	b := []byte(`{"F1":5,"Timestamp":1611634115,"Hostname":"foo"}`)
-	p := structpb.Struct{}
-	protojson.Unmarshal(b, &p)
-	b, err := proto.Marshal(&pb.FieldsSPB{Fields: &p})
-	assert.NoError(t, err)
-
-	v, err := f(b)
-	assert.NoError(t, err)
-
-	m := v.(*pb.FieldsSPB)
-
-	assert.Equal(t, float64(5), m.Fields.Fields["F1"].GetNumberValue())
-	assert.Equal(t, float64(1611634115), m.Fields.Fields["Timestamp"].GetNumberValue())
-	assert.Equal(t, "foo", m.Fields.Fields["Hostname"].GetStringValue())
-
-	// cover nil
-	f = getUnmarshal("unknown")
-	assert.Nil(t, f)
-}
-
 func TestStart(t *testing.T) {
 	broker := sarama.NewMockBroker(t, 0)
 	defer broker.Close()
@@ -100,7 +49,7 @@ func TestStart(t *testing.T) {
 	defer cancel()
 	ctx = cfg.WithContext(ctx)
 	ch := make(chan interface{}, 1)
-	err := Start(ctx, "foo", "json", ch)
+	err := Start(ctx, "foo", "json", ch, new(sync.WaitGroup), nil)
 	assert.NoError(t, err)
 }
 
@@ -117,6 +66,28 @@ func TestSaramaConfig(t *testing.T) {
 		},
 	}
 
-	_, err := saramaConfig(conf)
+	sConfig, err := saramaConfig(conf)
 	assert.NoError(t, err)
+	assert.False(t, sConfig.Consumer.Offsets.AutoCommit.Enable)
+}
+
+func TestWorker(t *testing.T) {
+	cg := &consumerGroup{logger: zap.NewNop(), serialization: "json"}
+	ch := make(chan interface{}, 2)
+	mCh := make(chan message, 2)
+	acked := 0
+
+	mCh <- message{value: []byte("invalid"), ack: func() { acked++ }}
+	mCh <- message{value: []byte(`{"F1":1}`), ack: func() { acked++ }}
+	close(mCh)
+
+	cg.worker(context.Background(), ch, mCh)
+
+	// the invalid message is acknowledged
+	assert.Equal(t, 1, acked)
+
+	v, done := ack.Split(<-ch)
+	assert.Equal(t, float64(1), v.(map[string]interface{})["F1"])
+	done()
+	assert.Equal(t, 2, acked)
 }
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// offsets tracks the offsets of a claim which have been handed over to
+// the ingestion, an offset is marked once it and all the previous ones
+// have been acknowledged so the committed offset never passes an event
+// which hasn't been stored.
+type offsets struct {
+	mu        sync.Mutex
+	session   sarama.ConsumerGroupSession
+	topic     string
+	partition int32
+	pending   []*offset
+}
+
+type offset struct {
+	offset int64
+	acked  bool
+}
+
+func newOffsets(session sarama.ConsumerGroupSession, topic string, partition int32) *offsets {
+	return &offsets{
+		session:   session,
+		topic:     topic,
+		partition: partition,
+	}
+}
+
+// add adds the offset, it returns its acknowledgement.
+func (o *offsets) add(n int64) func() {
+	e := &offset{offset: n}
+
+	o.mu.Lock()
+	o.pending = append(o.pending, e)
+	o.mu.Unlock()
+
+	return func() { o.ack(e) }
+}
+
+// ack acknowledges the offset and marks the last offset
+// which all its previous ones have been acknowledged.
+func (o *offsets) ack(e *offset) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e.acked = true
+
+	i := 0
+	for i < len(o.pending) && o.pending[i].acked {
+		i++
+	}
+
+	if i == 0 {
+		return
+	}
+
+	// the session ignores it once it has been released
+	o.session.MarkOffset(o.topic, o.partition, o.pending[i-1].offset+1, "")
+	o.pending = append(o.pending[:0], o.pending[i:]...)
+}
+
+// len returns the number of the unacknowledged offsets.
+func (o *offsets) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.pending)
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionMock struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *sessionMock) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.marked = append(s.marked, offset)
+}
+
+func (s *sessionMock) Context() context.Context {
+	return context.Background()
+}
+
+func TestOffsets(t *testing.T) {
+	s := &sessionMock{}
+	o := newOffsets(s, "tcpdog", 0)
+
+	ack10 := o.add(10)
+	ack11 := o.add(11)
+	ack12 := o.add(12)
+	assert.Equal(t, 3, o.len())
+
+	// the previous offset hasn't been acknowledged
+	ack11()
+	assert.Len(t, s.marked, 0)
+
+	ack10()
+	assert.Equal(t, []int64{12}, s.marked)
+	assert.Equal(t, 1, o.len())
+
+	ack12()
+	assert.Equal(t, []int64{12, 13}, s.marked)
+	assert.Equal(t, 0, o.len())
+}
@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"log"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents mqtt consumer configuration
+type Config struct {
+	Brokers      []string
+	Topic        string // subscription topic filter, e.g. tcpdog/#
+	QoS          byte   // 0, 1 or 2
+	ClientID     string
+	CleanSession bool // the broker keeps the session if it's false
+	KeepAlive    int  // seconds
+
+	Username string
+	Password string
+
+	TLSConfig config.TLSConfig
+}
+
+func mqttConfig(cfg map[string]interface{}) *Config {
+	// default configuration
+	conf := &Config{
+		Brokers:   []string{"tcp://localhost:1883"},
+		Topic:     "tcpdog/#",
+		QoS:       1,
+		ClientID:  "tcpdog-server",
+		KeepAlive: 30,
+	}
+
+	if err := config.Transform(cfg, conf); err != nil {
+		log.Fatal(err)
+	}
+
+	return conf
+}
+
+func clientOptions(mCfg *Config) (*paho.ClientOptions, error) {
+	opts := paho.NewClientOptions().
+		SetClientID(mCfg.ClientID).
+		SetUsername(mCfg.Username).
+		SetPassword(mCfg.Password).
+		SetCleanSession(mCfg.CleanSession).
+		SetKeepAlive(time.Duration(mCfg.KeepAlive) * time.Second).
+		SetAutoReconnect(true).
+		SetAutoAckDisabled(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute)
+
+	for _, broker := range mCfg.Brokers {
+		opts.AddBroker(broker)
+	}
+
+	if mCfg.TLSConfig.Enable {
+		tlsConfig, err := config.GetTLS(&mCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
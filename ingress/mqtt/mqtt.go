@@ -0,0 +1,129 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/ack"
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+// subscribeTimeout is the time to wait for the subscription acknowledgement.
+var subscribeTimeout = 10 * time.Second
+
+// quiesce is the time in millisecond to wait for
+// the in-flight messages at disconnect.
+const quiesce = 250
+
+type consumer struct {
+	mCfg      *Config
+	ch        chan interface{}
+	unmarshal func(b []byte) (interface{}, error)
+	stop      chan struct{}
+	mu        sync.RWMutex
+	closed    bool
+	logger    *zap.Logger
+}
+
+// Start starts consuming from mqtt, it subscribes to the topic once it's
+// connected and resubscribes after reconnecting. the qos 1 and 2 messages are
+// acknowledged once they have been stored by the ingestion, it disconnects
+// once the ingestion has stopped.
+func Start(ctx context.Context, name string, ser string, ch chan interface{}, wg *sync.WaitGroup, stopped <-chan struct{}) error {
+	mCfg := mqttConfig(config.FromContextServer(ctx).Ingress[name].Config)
+	logger := config.FromContextServer(ctx).Logger()
+
+	if mCfg.QoS > 2 {
+		return fmt.Errorf("qos %d is not supported", mCfg.QoS)
+	}
+
+	unmarshal := helper.GetUnmarshal(ser)
+	if unmarshal == nil {
+		return fmt.Errorf("unknown serialization: %s", ser)
+	}
+
+	opts, err := clientOptions(mCfg)
+	if err != nil {
+		return err
+	}
+
+	c := &consumer{
+		mCfg:      mCfg,
+		ch:        ch,
+		unmarshal: unmarshal,
+		stop:      make(chan struct{}),
+		logger:    logger,
+	}
+
+	opts.SetOnConnectHandler(c.subscribe)
+	opts.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		logger.Error("mqtt", zap.Error(err))
+	})
+
+	// it retries in the background until it's connected
+	client := paho.NewClient(opts)
+	client.Connect()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+
+		// releases the blocked handlers, their messages
+		// aren't acknowledged and the broker redelivers them.
+		close(c.stop)
+
+		c.mu.Lock()
+		c.closed = true
+		close(ch)
+		c.mu.Unlock()
+
+		// the stored events are acknowledged before disconnecting
+		<-stopped
+		client.Disconnect(quiesce)
+	}()
+
+	return nil
+}
+
+func (c *consumer) subscribe(client paho.Client) {
+	token := client.Subscribe(c.mCfg.Topic, c.mCfg.QoS, c.handle)
+	if !token.WaitTimeout(subscribeTimeout) {
+		c.logger.Error("mqtt", zap.String("topic", c.mCfg.Topic), zap.String("error", "subscribe timeout"))
+		return
+	}
+
+	if err := token.Error(); err != nil {
+		c.logger.Error("mqtt", zap.String("topic", c.mCfg.Topic), zap.Error(err))
+	}
+}
+
+// handle hands over the message to the ingestion, the invalid
+// message is acknowledged and skipped.
+func (c *consumer) handle(_ paho.Client, m paho.Message) {
+	i, err := c.unmarshal(m.Payload())
+	if err != nil {
+		c.logger.Error("mqtt", zap.String("event", "marshal"), zap.Error(err))
+		m.Ack()
+		return
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.ch <- ack.Event{Value: i, Ack: m.Ack}:
+	case <-c.stop:
+	}
+}
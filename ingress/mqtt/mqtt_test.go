@@ -0,0 +1,84 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/ack"
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/mqtt/mqtttest"
+)
+
+func TestStart(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	assert.NoError(t, err)
+	defer broker.Close()
+
+	cfg := config.ServerConfig{
+		Ingress: map[string]config.Ingress{
+			"foo": {
+				Type: "mqtt",
+				Config: map[string]interface{}{
+					"brokers": []string{broker.URL()},
+					"qos":     2,
+				},
+			},
+			"bar": {
+				Type:   "mqtt",
+				Config: map[string]interface{}{"qos": 3},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = cfg.WithContext(ctx)
+
+	ch := make(chan interface{}, 10)
+	wg := new(sync.WaitGroup)
+	stopped := make(chan struct{})
+
+	err = Start(ctx, "foo", "json", ch, wg, stopped)
+	assert.NoError(t, err)
+
+	// waits for the subscription
+	time.Sleep(200 * time.Millisecond)
+
+	broker.Publish("tcpdog/host/tracepoint", 1, []byte("invalid"))
+	broker.Publish("tcpdog/host/tracepoint", 2, []byte(`{"F1":1}`))
+	broker.Publish("other/host/tracepoint", 1, []byte(`{"F1":2}`))
+
+	select {
+	case e := <-ch:
+		v, done := ack.Split(e)
+		assert.Equal(t, float64(1), v.(map[string]interface{})["F1"])
+
+		// only the invalid message has been acknowledged
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, 1, broker.Acks())
+
+		done()
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, 2, broker.Acks())
+	case <-time.After(time.Second):
+		t.Fatal("time exceeded")
+	}
+
+	cancel()
+	close(stopped)
+	wg.Wait()
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	err = Start(ctx, "foo", "foo", ch, wg, nil)
+	assert.Error(t, err)
+
+	err = Start(ctx, "bar", "json", ch, wg, nil)
+	assert.Error(t, err)
+}
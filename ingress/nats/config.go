@@ -0,0 +1,99 @@
+package nats
+
+import (
+	"log"
+	"time"
+
+	natsio "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+// Config represents nats consumer configuration
+type Config struct {
+	Servers       []string
+	Subject       string // subscription subject, e.g. tcpdog.>
+	Queue         string // queue group of the core nats subscription
+	Stream        string // jetstream stream, the durable consumer is used if it's set
+	Durable       string
+	Batch         int // pull batch size (jetstream)
+	AckWait       int // seconds
+	MaxAckPending int // it should exceed the ingestion batch size (jetstream)
+
+	Username  string
+	Password  string
+	Token     string
+	CredsFile string
+
+	TLSConfig config.TLSConfig
+}
+
+func natsConfig(cfg map[string]interface{}) *Config {
+	// default configuration
+	conf := &Config{
+		Servers:       []string{"nats://localhost:4222"},
+		Subject:       "tcpdog.>",
+		Queue:         "tcpdog",
+		Durable:       "tcpdog",
+		Batch:         100,
+		AckWait:       30,
+		MaxAckPending: 10000,
+	}
+
+	if err := config.Transform(cfg, conf); err != nil {
+		log.Fatal(err)
+	}
+
+	return conf
+}
+
+// clientOptions returns the connection options, the client reconnects
+// in the background and it keeps retrying if the first connect fails.
+func clientOptions(nCfg *Config, logger *zap.Logger) ([]natsio.Option, error) {
+	opts := []natsio.Option{
+		natsio.Name("tcpdog-server"),
+		natsio.MaxReconnects(-1),
+		natsio.RetryOnFailedConnect(true),
+		natsio.DisconnectErrHandler(func(_ *natsio.Conn, err error) {
+			if err != nil {
+				logger.Error("nats", zap.Error(err))
+			}
+		}),
+	}
+
+	if nCfg.Username != "" {
+		opts = append(opts, natsio.UserInfo(nCfg.Username, nCfg.Password))
+	}
+
+	if nCfg.Token != "" {
+		opts = append(opts, natsio.Token(nCfg.Token))
+	}
+
+	if nCfg.CredsFile != "" {
+		opts = append(opts, natsio.UserCredentials(nCfg.CredsFile))
+	}
+
+	if nCfg.TLSConfig.Enable {
+		tlsConfig, err := config.GetTLS(&nCfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, natsio.Secure(tlsConfig))
+	}
+
+	return opts, nil
+}
+
+func (nCfg *Config) consumerConfig() jetstream.ConsumerConfig {
+	return jetstream.ConsumerConfig{
+		Durable:       nCfg.Durable,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       time.Duration(nCfg.AckWait) * time.Second,
+		MaxAckPending: nCfg.MaxAckPending,
+		FilterSubject: nCfg.Subject,
+	}
+}
@@ -0,0 +1,197 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	natsio "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/ack"
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/helper"
+)
+
+// flushTimeout is the time to wait for the server to
+// receive the last acknowledgements at shutdown.
+var flushTimeout = 5 * time.Second
+
+type consumer struct {
+	conn      *natsio.Conn
+	nCfg      *Config
+	ch        chan interface{}
+	unmarshal func(b []byte) (interface{}, error)
+	logger    *zap.Logger
+}
+
+// Start starts consuming from nats, it pulls from a jetstream durable
+// consumer if the stream is configured otherwise it subscribes to the
+// subject. the jetstream messages are acknowledged once they have been
+// stored by the ingestion, at shutdown the connection is held until the
+// stopped channel has been closed (the ingestion has stopped).
+func Start(ctx context.Context, name string, ser string, ch chan interface{}, wg *sync.WaitGroup, stopped <-chan struct{}) error {
+	nCfg := natsConfig(config.FromContextServer(ctx).Ingress[name].Config)
+	logger := config.FromContextServer(ctx).Logger()
+
+	unmarshal := helper.GetUnmarshal(ser)
+	if unmarshal == nil {
+		return fmt.Errorf("unknown serialization: %s", ser)
+	}
+
+	opts, err := clientOptions(nCfg, logger)
+	if err != nil {
+		return err
+	}
+
+	// it retries in the background until it's connected
+	conn, err := natsio.Connect(strings.Join(nCfg.Servers, ","), opts...)
+	if err != nil {
+		return err
+	}
+
+	c := &consumer{
+		conn:      conn,
+		nCfg:      nCfg,
+		ch:        ch,
+		unmarshal: unmarshal,
+		logger:    logger,
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if nCfg.Stream != "" {
+			c.pull(ctx)
+		} else {
+			c.subscribe(ctx)
+		}
+
+		close(ch)
+
+		if stopped != nil {
+			<-stopped
+		}
+
+		if err := conn.FlushTimeout(flushTimeout); err != nil {
+			logger.Error("nats", zap.Error(err))
+		}
+
+		conn.Close()
+	}()
+
+	return nil
+}
+
+// pull consumes from the durable consumer until the context is done,
+// it recreates the consumer on failure.
+func (c *consumer) pull(ctx context.Context) {
+	backoff := helper.NewBackoff(c.logger)
+
+	js, err := jetstream.New(c.conn)
+	if err != nil {
+		c.logger.Error("nats", zap.Error(err))
+		return
+	}
+
+	for ctx.Err() == nil {
+		backoff.Next()
+
+		cons, err := js.CreateOrUpdateConsumer(ctx, c.nCfg.Stream, c.nCfg.consumerConfig())
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("nats", zap.Error(err))
+			}
+			continue
+		}
+
+		iter, err := cons.Messages(jetstream.PullMaxMessages(c.nCfg.Batch))
+		if err != nil {
+			c.logger.Error("nats", zap.Error(err))
+			continue
+		}
+
+		c.consume(ctx, iter)
+	}
+}
+
+// consume hands over the messages to the ingestion, the
+// iterator is stopped once the context is done.
+func (c *consumer) consume(ctx context.Context, iter jetstream.MessagesContext) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+
+		iter.Stop()
+	}()
+
+	for {
+		m, err := iter.Next()
+		if err != nil {
+			if !errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				c.logger.Error("nats", zap.Error(err))
+			}
+			return
+		}
+
+		c.handle(ctx, m.Data(), func() {
+			if err := m.Ack(); err != nil {
+				c.logger.Error("nats", zap.Error(err))
+			}
+		})
+	}
+}
+
+// subscribe hands over the core nats messages to the ingestion.
+func (c *consumer) subscribe(ctx context.Context) {
+	msgs := make(chan *natsio.Msg, 1000)
+
+	sub, err := c.conn.ChanQueueSubscribe(c.nCfg.Subject, c.nCfg.Queue, msgs)
+	if err != nil {
+		c.logger.Error("nats", zap.Error(err))
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case m := <-msgs:
+			c.handle(ctx, m.Data, nil)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle hands over the message to the ingestion, the invalid message
+// is acknowledged and skipped. the message isn't acknowledged if it's
+// stopped and the stream redelivers it.
+func (c *consumer) handle(ctx context.Context, data []byte, done func()) {
+	i, err := c.unmarshal(data)
+	if err != nil {
+		c.logger.Error("nats", zap.String("event", "marshal"), zap.Error(err))
+		if done != nil {
+			done()
+		}
+		return
+	}
+
+	if done != nil {
+		i = ack.Event{Value: i, Ack: done}
+	}
+
+	select {
+	case c.ch <- i:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,136 @@
+package nats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	natsio "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/ack"
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/egress/nats/natstest"
+)
+
+func TestStartJetStream(t *testing.T) {
+	srv, err := natstest.NewServer()
+	assert.NoError(t, err)
+	defer srv.Close()
+
+	assert.NoError(t, srv.AddStream("TCPDOG", "tcpdog.>"))
+
+	cfg := config.ServerConfig{
+		Ingress: map[string]config.Ingress{
+			"foo": {
+				Type: "nats",
+				Config: map[string]interface{}{
+					"servers": []string{srv.URL()},
+					"stream":  "TCPDOG",
+					"batch":   2,
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = cfg.WithContext(ctx)
+
+	ch := make(chan interface{}, 10)
+	wg := new(sync.WaitGroup)
+	stopped := make(chan struct{})
+
+	err = Start(ctx, "foo", "json", ch, wg, stopped)
+	assert.NoError(t, err)
+
+	pub, err := natsio.Connect(srv.URL())
+	assert.NoError(t, err)
+	defer pub.Close()
+
+	for _, data := range []string{"invalid", `{"F1":1}`, `{"F1":2}`, `{"F1":3}`} {
+		assert.NoError(t, pub.Publish("tcpdog.foo", []byte(data)))
+	}
+
+	var acks []func()
+	for i := 1; i <= 3; i++ {
+		select {
+		case e := <-ch:
+			v, done := ack.Split(e)
+			assert.Equal(t, float64(i), v.(map[string]interface{})["F1"])
+			acks = append(acks, done)
+		case <-time.After(time.Second):
+			t.Fatal("time exceeded")
+		}
+	}
+
+	// only the invalid message has been acknowledged
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, srv.Acked("TCPDOG", "tcpdog"))
+
+	// the ingestion has stored the first two events
+	acks[0]()
+	acks[1]()
+
+	cancel()
+	close(stopped)
+	wg.Wait()
+
+	assert.Equal(t, 3, srv.Acked("TCPDOG", "tcpdog"))
+
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestStartCore(t *testing.T) {
+	srv, err := natstest.NewServer()
+	assert.NoError(t, err)
+	defer srv.Close()
+
+	cfg := config.ServerConfig{
+		Ingress: map[string]config.Ingress{
+			"foo": {
+				Type: "nats",
+				Config: map[string]interface{}{
+					"servers": []string{srv.URL()},
+				},
+			},
+		},
+	}
+
+	cfg.SetMockLogger("memory1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = cfg.WithContext(ctx)
+
+	ch := make(chan interface{}, 10)
+	wg := new(sync.WaitGroup)
+
+	err = Start(ctx, "foo", "json", ch, wg, nil)
+	assert.NoError(t, err)
+
+	pub, err := natsio.Connect(srv.URL())
+	assert.NoError(t, err)
+	defer pub.Close()
+
+	// waits for the subscription
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, pub.Publish("tcpdog.foo", []byte("invalid")))
+	assert.NoError(t, pub.Publish("tcpdog.foo", []byte(`{"F1":1}`)))
+
+	select {
+	case v := <-ch:
+		assert.Equal(t, float64(1), v.(map[string]interface{})["F1"])
+	case <-time.After(time.Second):
+		t.Fatal("time exceeded")
+	}
+
+	cancel()
+	wg.Wait()
+
+	err = Start(ctx, "foo", "foo", ch, wg, nil)
+	assert.Error(t, err)
+}
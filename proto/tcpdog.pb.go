@@ -82,67 +82,68 @@ type Fields struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Task          *string `protobuf:"bytes,1,opt,name=Task,proto3,oneof" json:"Task,omitempty"`
-	PID           *uint32 `protobuf:"varint,2,opt,name=PID,proto3,oneof" json:"PID,omitempty"`
-	TCPHeaderLen  *uint32 `protobuf:"varint,3,opt,name=TCPHeaderLen,proto3,oneof" json:"TCPHeaderLen,omitempty"`
-	TotalRetrans  *uint32 `protobuf:"varint,4,opt,name=TotalRetrans,proto3,oneof" json:"TotalRetrans,omitempty"`
-	SAddr         *string `protobuf:"bytes,5,opt,name=SAddr,proto3,oneof" json:"SAddr,omitempty"`
-	DAddr         *string `protobuf:"bytes,6,opt,name=DAddr,proto3,oneof" json:"DAddr,omitempty"`
-	DPort         *uint32 `protobuf:"varint,7,opt,name=DPort,proto3,oneof" json:"DPort,omitempty"`
-	LPort         *uint32 `protobuf:"varint,8,opt,name=LPort,proto3,oneof" json:"LPort,omitempty"`
-	BytesReceived *uint64 `protobuf:"varint,9,opt,name=BytesReceived,proto3,oneof" json:"BytesReceived,omitempty"`
-	BytesSent     *uint64 `protobuf:"varint,10,opt,name=BytesSent,proto3,oneof" json:"BytesSent,omitempty"`
-	BytesAcked    *uint64 `protobuf:"varint,11,opt,name=BytesAcked,proto3,oneof" json:"BytesAcked,omitempty"`
-	NumSAcks      *uint32 `protobuf:"varint,12,opt,name=NumSAcks,proto3,oneof" json:"NumSAcks,omitempty"`
-	UserMSS       *uint32 `protobuf:"varint,13,opt,name=UserMSS,proto3,oneof" json:"UserMSS,omitempty"`
-	MSSClamp      *uint32 `protobuf:"varint,14,opt,name=MSSClamp,proto3,oneof" json:"MSSClamp,omitempty"`
-	AdvMSS        *uint32 `protobuf:"varint,15,opt,name=AdvMSS,proto3,oneof" json:"AdvMSS,omitempty"`
-	RTT           *uint32 `protobuf:"varint,16,opt,name=RTT,proto3,oneof" json:"RTT,omitempty"`
-	SRTT          *uint32 `protobuf:"varint,17,opt,name=SRTT,proto3,oneof" json:"SRTT,omitempty"`
-	RTTVar        *uint32 `protobuf:"varint,18,opt,name=RTTVar,proto3,oneof" json:"RTTVar,omitempty"`
-	RcvRTT        *uint32 `protobuf:"varint,19,opt,name=RcvRTT,proto3,oneof" json:"RcvRTT,omitempty"`
-	RACKRTT       *uint32 `protobuf:"varint,20,opt,name=RACKRTT,proto3,oneof" json:"RACKRTT,omitempty"`
-	MDev          *uint32 `protobuf:"varint,21,opt,name=MDev,proto3,oneof" json:"MDev,omitempty"`
-	MDevMax       *uint32 `protobuf:"varint,22,opt,name=MDevMax,proto3,oneof" json:"MDevMax,omitempty"`
-	SegsIn        *uint32 `protobuf:"varint,23,opt,name=SegsIn,proto3,oneof" json:"SegsIn,omitempty"`
-	SegsOut       *uint32 `protobuf:"varint,24,opt,name=SegsOut,proto3,oneof" json:"SegsOut,omitempty"`
-	GSOSegs       *uint32 `protobuf:"varint,25,opt,name=GSOSegs,proto3,oneof" json:"GSOSegs,omitempty"`
-	DataSegsIn    *uint32 `protobuf:"varint,26,opt,name=DataSegsIn,proto3,oneof" json:"DataSegsIn,omitempty"`
-	MaxWindow     *uint32 `protobuf:"varint,27,opt,name=MaxWindow,proto3,oneof" json:"MaxWindow,omitempty"`
-	SndWnd        *uint32 `protobuf:"varint,28,opt,name=SndWnd,proto3,oneof" json:"SndWnd,omitempty"`
-	WindowClamp   *uint32 `protobuf:"varint,29,opt,name=WindowClamp,proto3,oneof" json:"WindowClamp,omitempty"`
-	RcvSSThresh   *uint32 `protobuf:"varint,30,opt,name=RcvSSThresh,proto3,oneof" json:"RcvSSThresh,omitempty"`
-	ECNFlags      *uint32 `protobuf:"varint,31,opt,name=ECNFlags,proto3,oneof" json:"ECNFlags,omitempty"`
-	SndCwnd       *uint32 `protobuf:"varint,32,opt,name=SndCwnd,proto3,oneof" json:"SndCwnd,omitempty"`
-	PrrOut        *uint32 `protobuf:"varint,33,opt,name=PrrOut,proto3,oneof" json:"PrrOut,omitempty"`
-	Delivered     *uint32 `protobuf:"varint,34,opt,name=Delivered,proto3,oneof" json:"Delivered,omitempty"`
-	DeliveredCe   *uint32 `protobuf:"varint,35,opt,name=DeliveredCe,proto3,oneof" json:"DeliveredCe,omitempty"`
-	Lost          *uint32 `protobuf:"varint,36,opt,name=Lost,proto3,oneof" json:"Lost,omitempty"`
-	LostOut       *uint32 `protobuf:"varint,37,opt,name=LostOut,proto3,oneof" json:"LostOut,omitempty"`
-	PriorSSThresh *uint32 `protobuf:"varint,38,opt,name=PriorSSThresh,proto3,oneof" json:"PriorSSThresh,omitempty"`
-	DataSegsOut   *uint32 `protobuf:"varint,39,opt,name=DataSegsOut,proto3,oneof" json:"DataSegsOut,omitempty"`
-	RcvSpace      *uint32 `protobuf:"varint,40,opt,name=RcvSpace,proto3,oneof" json:"RcvSpace,omitempty"`
-	UnAcked       *uint32 `protobuf:"varint,41,opt,name=UnAcked,proto3,oneof" json:"UnAcked,omitempty"`
-	SAcked        *uint32 `protobuf:"varint,42,opt,name=SAcked,proto3,oneof" json:"SAcked,omitempty"`
-	RTO           *uint32 `protobuf:"varint,43,opt,name=RTO,proto3,oneof" json:"RTO,omitempty"`
-	DsackDups     *uint32 `protobuf:"varint,44,opt,name=DsackDups,proto3,oneof" json:"DsackDups,omitempty"`
-	RateDelivered *uint32 `protobuf:"varint,45,opt,name=RateDelivered,proto3,oneof" json:"RateDelivered,omitempty"`
-	RateInterval  *uint32 `protobuf:"varint,46,opt,name=RateInterval,proto3,oneof" json:"RateInterval,omitempty"`
-	SndSSThresh   *uint32 `protobuf:"varint,47,opt,name=SndSSThresh,proto3,oneof" json:"SndSSThresh,omitempty"`
-	PacketsOut    *uint32 `protobuf:"varint,48,opt,name=PacketsOut,proto3,oneof" json:"PacketsOut,omitempty"`
-	RetransOut    *uint32 `protobuf:"varint,49,opt,name=RetransOut,proto3,oneof" json:"RetransOut,omitempty"`
-	MaxPacketsOut *uint32 `protobuf:"varint,50,opt,name=MaxPacketsOut,proto3,oneof" json:"MaxPacketsOut,omitempty"`
-	MaxPacketsSeq *uint32 `protobuf:"varint,51,opt,name=MaxPacketsSeq,proto3,oneof" json:"MaxPacketsSeq,omitempty"`
-	GeoLocation   *string `protobuf:"bytes,52,opt,name=GeoLocation,proto3,oneof" json:"GeoLocation,omitempty"`
-	CCode         *string `protobuf:"bytes,53,opt,name=CCode,proto3,oneof" json:"CCode,omitempty"`
-	CSCode        *string `protobuf:"bytes,54,opt,name=CSCode,proto3,oneof" json:"CSCode,omitempty"`
-	Country       *string `protobuf:"bytes,55,opt,name=Country,proto3,oneof" json:"Country,omitempty"`
-	City          *string `protobuf:"bytes,56,opt,name=City,proto3,oneof" json:"City,omitempty"`
-	Region        *string `protobuf:"bytes,57,opt,name=Region,proto3,oneof" json:"Region,omitempty"`
-	ASN           *string `protobuf:"bytes,58,opt,name=ASN,proto3,oneof" json:"ASN,omitempty"`
-	ASNOrg        *string `protobuf:"bytes,59,opt,name=ASNOrg,proto3,oneof" json:"ASNOrg,omitempty"`
-	Hostname      *string `protobuf:"bytes,60,opt,name=Hostname,proto3,oneof" json:"Hostname,omitempty"`
-	Timestamp     *uint64 `protobuf:"varint,61,opt,name=Timestamp,proto3,oneof" json:"Timestamp,omitempty"`
+	Task          *string            `protobuf:"bytes,1,opt,name=Task,proto3,oneof" json:"Task,omitempty"`
+	PID           *uint32            `protobuf:"varint,2,opt,name=PID,proto3,oneof" json:"PID,omitempty"`
+	TCPHeaderLen  *uint32            `protobuf:"varint,3,opt,name=TCPHeaderLen,proto3,oneof" json:"TCPHeaderLen,omitempty"`
+	TotalRetrans  *uint32            `protobuf:"varint,4,opt,name=TotalRetrans,proto3,oneof" json:"TotalRetrans,omitempty"`
+	SAddr         *string            `protobuf:"bytes,5,opt,name=SAddr,proto3,oneof" json:"SAddr,omitempty"`
+	DAddr         *string            `protobuf:"bytes,6,opt,name=DAddr,proto3,oneof" json:"DAddr,omitempty"`
+	DPort         *uint32            `protobuf:"varint,7,opt,name=DPort,proto3,oneof" json:"DPort,omitempty"`
+	LPort         *uint32            `protobuf:"varint,8,opt,name=LPort,proto3,oneof" json:"LPort,omitempty"`
+	BytesReceived *uint64            `protobuf:"varint,9,opt,name=BytesReceived,proto3,oneof" json:"BytesReceived,omitempty"`
+	BytesSent     *uint64            `protobuf:"varint,10,opt,name=BytesSent,proto3,oneof" json:"BytesSent,omitempty"`
+	BytesAcked    *uint64            `protobuf:"varint,11,opt,name=BytesAcked,proto3,oneof" json:"BytesAcked,omitempty"`
+	NumSAcks      *uint32            `protobuf:"varint,12,opt,name=NumSAcks,proto3,oneof" json:"NumSAcks,omitempty"`
+	UserMSS       *uint32            `protobuf:"varint,13,opt,name=UserMSS,proto3,oneof" json:"UserMSS,omitempty"`
+	MSSClamp      *uint32            `protobuf:"varint,14,opt,name=MSSClamp,proto3,oneof" json:"MSSClamp,omitempty"`
+	AdvMSS        *uint32            `protobuf:"varint,15,opt,name=AdvMSS,proto3,oneof" json:"AdvMSS,omitempty"`
+	RTT           *uint32            `protobuf:"varint,16,opt,name=RTT,proto3,oneof" json:"RTT,omitempty"`
+	SRTT          *uint32            `protobuf:"varint,17,opt,name=SRTT,proto3,oneof" json:"SRTT,omitempty"`
+	RTTVar        *uint32            `protobuf:"varint,18,opt,name=RTTVar,proto3,oneof" json:"RTTVar,omitempty"`
+	RcvRTT        *uint32            `protobuf:"varint,19,opt,name=RcvRTT,proto3,oneof" json:"RcvRTT,omitempty"`
+	RACKRTT       *uint32            `protobuf:"varint,20,opt,name=RACKRTT,proto3,oneof" json:"RACKRTT,omitempty"`
+	MDev          *uint32            `protobuf:"varint,21,opt,name=MDev,proto3,oneof" json:"MDev,omitempty"`
+	MDevMax       *uint32            `protobuf:"varint,22,opt,name=MDevMax,proto3,oneof" json:"MDevMax,omitempty"`
+	SegsIn        *uint32            `protobuf:"varint,23,opt,name=SegsIn,proto3,oneof" json:"SegsIn,omitempty"`
+	SegsOut       *uint32            `protobuf:"varint,24,opt,name=SegsOut,proto3,oneof" json:"SegsOut,omitempty"`
+	GSOSegs       *uint32            `protobuf:"varint,25,opt,name=GSOSegs,proto3,oneof" json:"GSOSegs,omitempty"`
+	DataSegsIn    *uint32            `protobuf:"varint,26,opt,name=DataSegsIn,proto3,oneof" json:"DataSegsIn,omitempty"`
+	MaxWindow     *uint32            `protobuf:"varint,27,opt,name=MaxWindow,proto3,oneof" json:"MaxWindow,omitempty"`
+	SndWnd        *uint32            `protobuf:"varint,28,opt,name=SndWnd,proto3,oneof" json:"SndWnd,omitempty"`
+	WindowClamp   *uint32            `protobuf:"varint,29,opt,name=WindowClamp,proto3,oneof" json:"WindowClamp,omitempty"`
+	RcvSSThresh   *uint32            `protobuf:"varint,30,opt,name=RcvSSThresh,proto3,oneof" json:"RcvSSThresh,omitempty"`
+	ECNFlags      *uint32            `protobuf:"varint,31,opt,name=ECNFlags,proto3,oneof" json:"ECNFlags,omitempty"`
+	SndCwnd       *uint32            `protobuf:"varint,32,opt,name=SndCwnd,proto3,oneof" json:"SndCwnd,omitempty"`
+	PrrOut        *uint32            `protobuf:"varint,33,opt,name=PrrOut,proto3,oneof" json:"PrrOut,omitempty"`
+	Delivered     *uint32            `protobuf:"varint,34,opt,name=Delivered,proto3,oneof" json:"Delivered,omitempty"`
+	DeliveredCe   *uint32            `protobuf:"varint,35,opt,name=DeliveredCe,proto3,oneof" json:"DeliveredCe,omitempty"`
+	Lost          *uint32            `protobuf:"varint,36,opt,name=Lost,proto3,oneof" json:"Lost,omitempty"`
+	LostOut       *uint32            `protobuf:"varint,37,opt,name=LostOut,proto3,oneof" json:"LostOut,omitempty"`
+	PriorSSThresh *uint32            `protobuf:"varint,38,opt,name=PriorSSThresh,proto3,oneof" json:"PriorSSThresh,omitempty"`
+	DataSegsOut   *uint32            `protobuf:"varint,39,opt,name=DataSegsOut,proto3,oneof" json:"DataSegsOut,omitempty"`
+	RcvSpace      *uint32            `protobuf:"varint,40,opt,name=RcvSpace,proto3,oneof" json:"RcvSpace,omitempty"`
+	UnAcked       *uint32            `protobuf:"varint,41,opt,name=UnAcked,proto3,oneof" json:"UnAcked,omitempty"`
+	SAcked        *uint32            `protobuf:"varint,42,opt,name=SAcked,proto3,oneof" json:"SAcked,omitempty"`
+	RTO           *uint32            `protobuf:"varint,43,opt,name=RTO,proto3,oneof" json:"RTO,omitempty"`
+	DsackDups     *uint32            `protobuf:"varint,44,opt,name=DsackDups,proto3,oneof" json:"DsackDups,omitempty"`
+	RateDelivered *uint32            `protobuf:"varint,45,opt,name=RateDelivered,proto3,oneof" json:"RateDelivered,omitempty"`
+	RateInterval  *uint32            `protobuf:"varint,46,opt,name=RateInterval,proto3,oneof" json:"RateInterval,omitempty"`
+	SndSSThresh   *uint32            `protobuf:"varint,47,opt,name=SndSSThresh,proto3,oneof" json:"SndSSThresh,omitempty"`
+	PacketsOut    *uint32            `protobuf:"varint,48,opt,name=PacketsOut,proto3,oneof" json:"PacketsOut,omitempty"`
+	RetransOut    *uint32            `protobuf:"varint,49,opt,name=RetransOut,proto3,oneof" json:"RetransOut,omitempty"`
+	MaxPacketsOut *uint32            `protobuf:"varint,50,opt,name=MaxPacketsOut,proto3,oneof" json:"MaxPacketsOut,omitempty"`
+	MaxPacketsSeq *uint32            `protobuf:"varint,51,opt,name=MaxPacketsSeq,proto3,oneof" json:"MaxPacketsSeq,omitempty"`
+	GeoLocation   *string            `protobuf:"bytes,52,opt,name=GeoLocation,proto3,oneof" json:"GeoLocation,omitempty"`
+	CCode         *string            `protobuf:"bytes,53,opt,name=CCode,proto3,oneof" json:"CCode,omitempty"`
+	CSCode        *string            `protobuf:"bytes,54,opt,name=CSCode,proto3,oneof" json:"CSCode,omitempty"`
+	Country       *string            `protobuf:"bytes,55,opt,name=Country,proto3,oneof" json:"Country,omitempty"`
+	City          *string            `protobuf:"bytes,56,opt,name=City,proto3,oneof" json:"City,omitempty"`
+	Region        *string            `protobuf:"bytes,57,opt,name=Region,proto3,oneof" json:"Region,omitempty"`
+	ASN           *string            `protobuf:"bytes,58,opt,name=ASN,proto3,oneof" json:"ASN,omitempty"`
+	ASNOrg        *string            `protobuf:"bytes,59,opt,name=ASNOrg,proto3,oneof" json:"ASNOrg,omitempty"`
+	Hostname      *string            `protobuf:"bytes,60,opt,name=Hostname,proto3,oneof" json:"Hostname,omitempty"`
+	Timestamp     *uint64            `protobuf:"varint,61,opt,name=Timestamp,proto3,oneof" json:"Timestamp,omitempty"`
+	Ext           map[string]float64 `protobuf:"bytes,62,rep,name=Ext,proto3" json:"Ext,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
 }
 
 func (x *Fields) Reset() {
@@ -604,6 +605,162 @@ func (x *Fields) GetTimestamp() uint64 {
 	return 0
 }
 
+func (x *Fields) GetExt() map[string]float64 {
+	if x != nil {
+		return x.Ext
+	}
+	return nil
+}
+
+type FieldsBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Fields []*Fields `protobuf:"bytes,1,rep,name=fields,proto3" json:"fields,omitempty"`
+}
+
+func (x *FieldsBatch) Reset() {
+	*x = FieldsBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tcpdog_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FieldsBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldsBatch) ProtoMessage() {}
+
+func (x *FieldsBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_tcpdog_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldsBatch.ProtoReflect.Descriptor instead.
+func (*FieldsBatch) Descriptor() ([]byte, []int) {
+	return file_tcpdog_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FieldsBatch) GetFields() []*Fields {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type FieldsSeq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq    uint64    `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Fields []*Fields `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"`
+}
+
+func (x *FieldsSeq) Reset() {
+	*x = FieldsSeq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tcpdog_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FieldsSeq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldsSeq) ProtoMessage() {}
+
+func (x *FieldsSeq) ProtoReflect() protoreflect.Message {
+	mi := &file_tcpdog_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldsSeq.ProtoReflect.Descriptor instead.
+func (*FieldsSeq) Descriptor() ([]byte, []int) {
+	return file_tcpdog_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FieldsSeq) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *FieldsSeq) GetFields() []*Fields {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type Ack struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq uint64 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tcpdog_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_tcpdog_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_tcpdog_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Ack) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
 type Response struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -615,7 +772,7 @@ type Response struct {
 func (x *Response) Reset() {
 	*x = Response{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tcpdog_proto_msgTypes[2]
+		mi := &file_tcpdog_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -628,7 +785,7 @@ func (x *Response) String() string {
 func (*Response) ProtoMessage() {}
 
 func (x *Response) ProtoReflect() protoreflect.Message {
-	mi := &file_tcpdog_proto_msgTypes[2]
+	mi := &file_tcpdog_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -641,7 +798,7 @@ func (x *Response) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Response.ProtoReflect.Descriptor instead.
 func (*Response) Descriptor() ([]byte, []int) {
-	return file_tcpdog_proto_rawDescGZIP(), []int{2}
+	return file_tcpdog_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Response) GetCode() int32 {
@@ -661,7 +818,7 @@ var file_tcpdog_proto_rawDesc = []byte{
 	0x42, 0x12, 0x2f, 0x0a, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x0b, 0x32, 0x17, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x52, 0x06, 0x66, 0x69, 0x65, 0x6c,
-	0x64, 0x73, 0x22, 0xce, 0x15, 0x0a, 0x06, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x12, 0x17, 0x0a,
+	0x64, 0x73, 0x22, 0xb1, 0x16, 0x0a, 0x06, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x12, 0x17, 0x0a,
 	0x04, 0x54, 0x61, 0x73, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x04, 0x54,
 	0x61, 0x73, 0x6b, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x50, 0x49, 0x44, 0x18, 0x02, 0x20,
 	0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x03, 0x50, 0x49, 0x44, 0x88, 0x01, 0x01, 0x12, 0x27, 0x0a,
@@ -785,65 +942,88 @@ var file_tcpdog_proto_rawDesc = []byte{
 	0x6d, 0x65, 0x18, 0x3c, 0x20, 0x01, 0x28, 0x09, 0x48, 0x3b, 0x52, 0x08, 0x48, 0x6f, 0x73, 0x74,
 	0x6e, 0x61, 0x6d, 0x65, 0x88, 0x01, 0x01, 0x12, 0x21, 0x0a, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x73,
 	0x74, 0x61, 0x6d, 0x70, 0x18, 0x3d, 0x20, 0x01, 0x28, 0x04, 0x48, 0x3c, 0x52, 0x09, 0x54, 0x69,
-	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x88, 0x01, 0x01, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x54,
-	0x61, 0x73, 0x6b, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x50, 0x49, 0x44, 0x42, 0x0f, 0x0a, 0x0d, 0x5f,
-	0x54, 0x43, 0x50, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x4c, 0x65, 0x6e, 0x42, 0x0f, 0x0a, 0x0d,
-	0x5f, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x52, 0x65, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x42, 0x08, 0x0a,
-	0x06, 0x5f, 0x53, 0x41, 0x64, 0x64, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x44, 0x41, 0x64, 0x64,
-	0x72, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x44, 0x50, 0x6f, 0x72, 0x74, 0x42, 0x08, 0x0a, 0x06, 0x5f,
-	0x4c, 0x50, 0x6f, 0x72, 0x74, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x42, 0x79, 0x74, 0x65, 0x73, 0x52,
-	0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x42, 0x79, 0x74, 0x65,
-	0x73, 0x53, 0x65, 0x6e, 0x74, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x42, 0x79, 0x74, 0x65, 0x73, 0x41,
-	0x63, 0x6b, 0x65, 0x64, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x4e, 0x75, 0x6d, 0x53, 0x41, 0x63, 0x6b,
-	0x73, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x55, 0x73, 0x65, 0x72, 0x4d, 0x53, 0x53, 0x42, 0x0b, 0x0a,
-	0x09, 0x5f, 0x4d, 0x53, 0x53, 0x43, 0x6c, 0x61, 0x6d, 0x70, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x41,
-	0x64, 0x76, 0x4d, 0x53, 0x53, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x52, 0x54, 0x54, 0x42, 0x07, 0x0a,
-	0x05, 0x5f, 0x53, 0x52, 0x54, 0x54, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x52, 0x54, 0x54, 0x56, 0x61,
-	0x72, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x52, 0x63, 0x76, 0x52, 0x54, 0x54, 0x42, 0x0a, 0x0a, 0x08,
-	0x5f, 0x52, 0x41, 0x43, 0x4b, 0x52, 0x54, 0x54, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x4d, 0x44, 0x65,
-	0x76, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x4d, 0x44, 0x65, 0x76, 0x4d, 0x61, 0x78, 0x42, 0x09, 0x0a,
-	0x07, 0x5f, 0x53, 0x65, 0x67, 0x73, 0x49, 0x6e, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x53, 0x65, 0x67,
-	0x73, 0x4f, 0x75, 0x74, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x47, 0x53, 0x4f, 0x53, 0x65, 0x67, 0x73,
-	0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x44, 0x61, 0x74, 0x61, 0x53, 0x65, 0x67, 0x73, 0x49, 0x6e, 0x42,
-	0x0c, 0x0a, 0x0a, 0x5f, 0x4d, 0x61, 0x78, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x42, 0x09, 0x0a,
-	0x07, 0x5f, 0x53, 0x6e, 0x64, 0x57, 0x6e, 0x64, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x57, 0x69, 0x6e,
-	0x64, 0x6f, 0x77, 0x43, 0x6c, 0x61, 0x6d, 0x70, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x52, 0x63, 0x76,
-	0x53, 0x53, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x45, 0x43, 0x4e,
-	0x46, 0x6c, 0x61, 0x67, 0x73, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x53, 0x6e, 0x64, 0x43, 0x77, 0x6e,
-	0x64, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x50, 0x72, 0x72, 0x4f, 0x75, 0x74, 0x42, 0x0c, 0x0a, 0x0a,
-	0x5f, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x44,
-	0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x43, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x4c,
-	0x6f, 0x73, 0x74, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x4c, 0x6f, 0x73, 0x74, 0x4f, 0x75, 0x74, 0x42,
-	0x10, 0x0a, 0x0e, 0x5f, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x53, 0x53, 0x54, 0x68, 0x72, 0x65, 0x73,
-	0x68, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x44, 0x61, 0x74, 0x61, 0x53, 0x65, 0x67, 0x73, 0x4f, 0x75,
-	0x74, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x52, 0x63, 0x76, 0x53, 0x70, 0x61, 0x63, 0x65, 0x42, 0x0a,
-	0x0a, 0x08, 0x5f, 0x55, 0x6e, 0x41, 0x63, 0x6b, 0x65, 0x64, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x53,
-	0x41, 0x63, 0x6b, 0x65, 0x64, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x52, 0x54, 0x4f, 0x42, 0x0c, 0x0a,
-	0x0a, 0x5f, 0x44, 0x73, 0x61, 0x63, 0x6b, 0x44, 0x75, 0x70, 0x73, 0x42, 0x10, 0x0a, 0x0e, 0x5f,
-	0x52, 0x61, 0x74, 0x65, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x42, 0x0f, 0x0a,
-	0x0d, 0x5f, 0x52, 0x61, 0x74, 0x65, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x42, 0x0e,
-	0x0a, 0x0c, 0x5f, 0x53, 0x6e, 0x64, 0x53, 0x53, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x42, 0x0d,
-	0x0a, 0x0b, 0x5f, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x42, 0x0d, 0x0a,
-	0x0b, 0x5f, 0x52, 0x65, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x4f, 0x75, 0x74, 0x42, 0x10, 0x0a, 0x0e,
-	0x5f, 0x4d, 0x61, 0x78, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x42, 0x10,
-	0x0a, 0x0e, 0x5f, 0x4d, 0x61, 0x78, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x53, 0x65, 0x71,
-	0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x47, 0x65, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
-	0x42, 0x08, 0x0a, 0x06, 0x5f, 0x43, 0x43, 0x6f, 0x64, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x43,
-	0x53, 0x43, 0x6f, 0x64, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x72,
-	0x79, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x43, 0x69, 0x74, 0x79, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x52,
-	0x65, 0x67, 0x69, 0x6f, 0x6e, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x41, 0x53, 0x4e, 0x42, 0x09, 0x0a,
-	0x07, 0x5f, 0x41, 0x53, 0x4e, 0x4f, 0x72, 0x67, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x48, 0x6f, 0x73,
-	0x74, 0x6e, 0x61, 0x6d, 0x65, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74,
-	0x61, 0x6d, 0x70, 0x22, 0x1e, 0x0a, 0x08, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
-	0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63,
-	0x6f, 0x64, 0x65, 0x32, 0x76, 0x0a, 0x06, 0x54, 0x43, 0x50, 0x44, 0x6f, 0x67, 0x12, 0x32, 0x0a,
-	0x0a, 0x54, 0x72, 0x61, 0x63, 0x65, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x0e, 0x2e, 0x74, 0x63,
-	0x70, 0x64, 0x6f, 0x67, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x1a, 0x10, 0x2e, 0x74, 0x63,
-	0x70, 0x64, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28,
-	0x01, 0x12, 0x38, 0x0a, 0x0d, 0x54, 0x72, 0x61, 0x63, 0x65, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53,
-	0x50, 0x42, 0x12, 0x11, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x46, 0x69, 0x65, 0x6c,
-	0x64, 0x73, 0x53, 0x50, 0x42, 0x1a, 0x10, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x88, 0x01, 0x01, 0x12, 0x29, 0x0a, 0x03, 0x45, 0x78,
+	0x74, 0x18, 0x3e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67,
+	0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x2e, 0x45, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x03, 0x45, 0x78, 0x74, 0x1a, 0x36, 0x0a, 0x08, 0x45, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x07, 0x0a,
+	0x05, 0x5f, 0x54, 0x61, 0x73, 0x6b, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x50, 0x49, 0x44, 0x42, 0x0f,
+	0x0a, 0x0d, 0x5f, 0x54, 0x43, 0x50, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x4c, 0x65, 0x6e, 0x42,
+	0x0f, 0x0a, 0x0d, 0x5f, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x52, 0x65, 0x74, 0x72, 0x61, 0x6e, 0x73,
+	0x42, 0x08, 0x0a, 0x06, 0x5f, 0x53, 0x41, 0x64, 0x64, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x44,
+	0x41, 0x64, 0x64, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x44, 0x50, 0x6f, 0x72, 0x74, 0x42, 0x08,
+	0x0a, 0x06, 0x5f, 0x4c, 0x50, 0x6f, 0x72, 0x74, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x42, 0x79, 0x74,
+	0x65, 0x73, 0x52, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x42,
+	0x79, 0x74, 0x65, 0x73, 0x53, 0x65, 0x6e, 0x74, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x42, 0x79, 0x74,
+	0x65, 0x73, 0x41, 0x63, 0x6b, 0x65, 0x64, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x4e, 0x75, 0x6d, 0x53,
+	0x41, 0x63, 0x6b, 0x73, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x55, 0x73, 0x65, 0x72, 0x4d, 0x53, 0x53,
+	0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x4d, 0x53, 0x53, 0x43, 0x6c, 0x61, 0x6d, 0x70, 0x42, 0x09, 0x0a,
+	0x07, 0x5f, 0x41, 0x64, 0x76, 0x4d, 0x53, 0x53, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x52, 0x54, 0x54,
+	0x42, 0x07, 0x0a, 0x05, 0x5f, 0x53, 0x52, 0x54, 0x54, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x52, 0x54,
+	0x54, 0x56, 0x61, 0x72, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x52, 0x63, 0x76, 0x52, 0x54, 0x54, 0x42,
+	0x0a, 0x0a, 0x08, 0x5f, 0x52, 0x41, 0x43, 0x4b, 0x52, 0x54, 0x54, 0x42, 0x07, 0x0a, 0x05, 0x5f,
+	0x4d, 0x44, 0x65, 0x76, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x4d, 0x44, 0x65, 0x76, 0x4d, 0x61, 0x78,
+	0x42, 0x09, 0x0a, 0x07, 0x5f, 0x53, 0x65, 0x67, 0x73, 0x49, 0x6e, 0x42, 0x0a, 0x0a, 0x08, 0x5f,
+	0x53, 0x65, 0x67, 0x73, 0x4f, 0x75, 0x74, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x47, 0x53, 0x4f, 0x53,
+	0x65, 0x67, 0x73, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x44, 0x61, 0x74, 0x61, 0x53, 0x65, 0x67, 0x73,
+	0x49, 0x6e, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x4d, 0x61, 0x78, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x42, 0x09, 0x0a, 0x07, 0x5f, 0x53, 0x6e, 0x64, 0x57, 0x6e, 0x64, 0x42, 0x0e, 0x0a, 0x0c, 0x5f,
+	0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x43, 0x6c, 0x61, 0x6d, 0x70, 0x42, 0x0e, 0x0a, 0x0c, 0x5f,
+	0x52, 0x63, 0x76, 0x53, 0x53, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x42, 0x0b, 0x0a, 0x09, 0x5f,
+	0x45, 0x43, 0x4e, 0x46, 0x6c, 0x61, 0x67, 0x73, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x53, 0x6e, 0x64,
+	0x43, 0x77, 0x6e, 0x64, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x50, 0x72, 0x72, 0x4f, 0x75, 0x74, 0x42,
+	0x0c, 0x0a, 0x0a, 0x5f, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x42, 0x0e, 0x0a,
+	0x0c, 0x5f, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x43, 0x65, 0x42, 0x07, 0x0a,
+	0x05, 0x5f, 0x4c, 0x6f, 0x73, 0x74, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x4c, 0x6f, 0x73, 0x74, 0x4f,
+	0x75, 0x74, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x53, 0x53, 0x54, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x44, 0x61, 0x74, 0x61, 0x53, 0x65, 0x67,
+	0x73, 0x4f, 0x75, 0x74, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x52, 0x63, 0x76, 0x53, 0x70, 0x61, 0x63,
+	0x65, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x55, 0x6e, 0x41, 0x63, 0x6b, 0x65, 0x64, 0x42, 0x09, 0x0a,
+	0x07, 0x5f, 0x53, 0x41, 0x63, 0x6b, 0x65, 0x64, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x52, 0x54, 0x4f,
+	0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x44, 0x73, 0x61, 0x63, 0x6b, 0x44, 0x75, 0x70, 0x73, 0x42, 0x10,
+	0x0a, 0x0e, 0x5f, 0x52, 0x61, 0x74, 0x65, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64,
+	0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x52, 0x61, 0x74, 0x65, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61,
+	0x6c, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x53, 0x6e, 0x64, 0x53, 0x53, 0x54, 0x68, 0x72, 0x65, 0x73,
+	0x68, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x4f, 0x75, 0x74,
+	0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x52, 0x65, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x4f, 0x75, 0x74, 0x42,
+	0x10, 0x0a, 0x0e, 0x5f, 0x4d, 0x61, 0x78, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x4f, 0x75,
+	0x74, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x4d, 0x61, 0x78, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73,
+	0x53, 0x65, 0x71, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x47, 0x65, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x43, 0x43, 0x6f, 0x64, 0x65, 0x42, 0x09, 0x0a,
+	0x07, 0x5f, 0x43, 0x53, 0x43, 0x6f, 0x64, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x43, 0x6f, 0x75,
+	0x6e, 0x74, 0x72, 0x79, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x43, 0x69, 0x74, 0x79, 0x42, 0x09, 0x0a,
+	0x07, 0x5f, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x41, 0x53, 0x4e,
+	0x42, 0x09, 0x0a, 0x07, 0x5f, 0x41, 0x53, 0x4e, 0x4f, 0x72, 0x67, 0x42, 0x0b, 0x0a, 0x09, 0x5f,
+	0x48, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x35, 0x0a, 0x0b, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x26, 0x0a, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x46,
+	0x69, 0x65, 0x6c, 0x64, 0x73, 0x52, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x22, 0x45, 0x0a,
+	0x09, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x53, 0x65, 0x71, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65,
+	0x71, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x26, 0x0a, 0x06,
+	0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x74,
+	0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x52, 0x06, 0x66, 0x69,
+	0x65, 0x6c, 0x64, 0x73, 0x22, 0x17, 0x0a, 0x03, 0x41, 0x63, 0x6b, 0x12, 0x10, 0x0a, 0x03, 0x73,
+	0x65, 0x71, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x22, 0x1e, 0x0a,
+	0x08, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x32, 0xeb, 0x01,
+	0x0a, 0x06, 0x54, 0x43, 0x50, 0x44, 0x6f, 0x67, 0x12, 0x32, 0x0a, 0x0a, 0x54, 0x72, 0x61, 0x63,
+	0x65, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x0e, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e,
+	0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x1a, 0x10, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x38, 0x0a, 0x0d,
+	0x54, 0x72, 0x61, 0x63, 0x65, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x50, 0x42, 0x12, 0x11, 0x2e,
+	0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x53, 0x50, 0x42,
+	0x1a, 0x10, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x3c, 0x0a, 0x0f, 0x54, 0x72, 0x61, 0x63, 0x65, 0x70,
+	0x6f, 0x69, 0x6e, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x13, 0x2e, 0x74, 0x63, 0x70, 0x64,
+	0x6f, 0x67, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x42, 0x61, 0x74, 0x63, 0x68, 0x1a, 0x10,
+	0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x28, 0x01, 0x12, 0x35, 0x0a, 0x0d, 0x54, 0x72, 0x61, 0x63, 0x65, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x41, 0x63, 0x6b, 0x12, 0x11, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f, 0x67, 0x2e, 0x46,
+	0x69, 0x65, 0x6c, 0x64, 0x73, 0x53, 0x65, 0x71, 0x1a, 0x0b, 0x2e, 0x74, 0x63, 0x70, 0x64, 0x6f,
+	0x67, 0x2e, 0x41, 0x63, 0x6b, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x62, 0x06, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x33,
 }
 
@@ -859,24 +1039,35 @@ func file_tcpdog_proto_rawDescGZIP() []byte {
 	return file_tcpdog_proto_rawDescData
 }
 
-var file_tcpdog_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_tcpdog_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_tcpdog_proto_goTypes = []interface{}{
 	(*FieldsSPB)(nil),      // 0: tcpdog.FieldsSPB
 	(*Fields)(nil),         // 1: tcpdog.Fields
-	(*Response)(nil),       // 2: tcpdog.Response
-	(*_struct.Struct)(nil), // 3: google.protobuf.Struct
+	(*FieldsBatch)(nil),    // 2: tcpdog.FieldsBatch
+	(*FieldsSeq)(nil),      // 3: tcpdog.FieldsSeq
+	(*Ack)(nil),            // 4: tcpdog.Ack
+	(*Response)(nil),       // 5: tcpdog.Response
+	nil,                    // 6: tcpdog.Fields.ExtEntry
+	(*_struct.Struct)(nil), // 7: google.protobuf.Struct
 }
 var file_tcpdog_proto_depIdxs = []int32{
-	3, // 0: tcpdog.FieldsSPB.fields:type_name -> google.protobuf.Struct
-	1, // 1: tcpdog.TCPDog.Tracepoint:input_type -> tcpdog.Fields
-	0, // 2: tcpdog.TCPDog.TracepointSPB:input_type -> tcpdog.FieldsSPB
-	2, // 3: tcpdog.TCPDog.Tracepoint:output_type -> tcpdog.Response
-	2, // 4: tcpdog.TCPDog.TracepointSPB:output_type -> tcpdog.Response
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	7, // 0: tcpdog.FieldsSPB.fields:type_name -> google.protobuf.Struct
+	6, // 1: tcpdog.Fields.Ext:type_name -> tcpdog.Fields.ExtEntry
+	1, // 2: tcpdog.FieldsBatch.fields:type_name -> tcpdog.Fields
+	1, // 3: tcpdog.FieldsSeq.fields:type_name -> tcpdog.Fields
+	1, // 4: tcpdog.TCPDog.Tracepoint:input_type -> tcpdog.Fields
+	0, // 5: tcpdog.TCPDog.TracepointSPB:input_type -> tcpdog.FieldsSPB
+	2, // 6: tcpdog.TCPDog.TracepointBatch:input_type -> tcpdog.FieldsBatch
+	3, // 7: tcpdog.TCPDog.TracepointAck:input_type -> tcpdog.FieldsSeq
+	5, // 8: tcpdog.TCPDog.Tracepoint:output_type -> tcpdog.Response
+	5, // 9: tcpdog.TCPDog.TracepointSPB:output_type -> tcpdog.Response
+	5, // 10: tcpdog.TCPDog.TracepointBatch:output_type -> tcpdog.Response
+	4, // 11: tcpdog.TCPDog.TracepointAck:output_type -> tcpdog.Ack
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_tcpdog_proto_init() }
@@ -910,6 +1101,42 @@ func file_tcpdog_proto_init() {
 			}
 		}
 		file_tcpdog_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FieldsBatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tcpdog_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FieldsSeq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tcpdog_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ack); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tcpdog_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Response); i {
 			case 0:
 				return &v.state
@@ -929,7 +1156,7 @@ func file_tcpdog_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_tcpdog_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -957,6 +1184,8 @@ const _ = grpc.SupportPackageIsVersion6
 type TCPDogClient interface {
 	Tracepoint(ctx context.Context, opts ...grpc.CallOption) (TCPDog_TracepointClient, error)
 	TracepointSPB(ctx context.Context, opts ...grpc.CallOption) (TCPDog_TracepointSPBClient, error)
+	TracepointBatch(ctx context.Context, opts ...grpc.CallOption) (TCPDog_TracepointBatchClient, error)
+	TracepointAck(ctx context.Context, opts ...grpc.CallOption) (TCPDog_TracepointAckClient, error)
 }
 
 type tCPDogClient struct {
@@ -1035,10 +1264,77 @@ func (x *tCPDogTracepointSPBClient) CloseAndRecv() (*Response, error) {
 	return m, nil
 }
 
+func (c *tCPDogClient) TracepointBatch(ctx context.Context, opts ...grpc.CallOption) (TCPDog_TracepointBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TCPDog_serviceDesc.Streams[2], "/tcpdog.TCPDog/TracepointBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tCPDogTracepointBatchClient{stream}
+	return x, nil
+}
+
+type TCPDog_TracepointBatchClient interface {
+	Send(*FieldsBatch) error
+	CloseAndRecv() (*Response, error)
+	grpc.ClientStream
+}
+
+type tCPDogTracepointBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *tCPDogTracepointBatchClient) Send(m *FieldsBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *tCPDogTracepointBatchClient) CloseAndRecv() (*Response, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tCPDogClient) TracepointAck(ctx context.Context, opts ...grpc.CallOption) (TCPDog_TracepointAckClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TCPDog_serviceDesc.Streams[3], "/tcpdog.TCPDog/TracepointAck", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tCPDogTracepointAckClient{stream}
+	return x, nil
+}
+
+type TCPDog_TracepointAckClient interface {
+	Send(*FieldsSeq) error
+	Recv() (*Ack, error)
+	grpc.ClientStream
+}
+
+type tCPDogTracepointAckClient struct {
+	grpc.ClientStream
+}
+
+func (x *tCPDogTracepointAckClient) Send(m *FieldsSeq) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *tCPDogTracepointAckClient) Recv() (*Ack, error) {
+	m := new(Ack)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // TCPDogServer is the server API for TCPDog service.
 type TCPDogServer interface {
 	Tracepoint(TCPDog_TracepointServer) error
 	TracepointSPB(TCPDog_TracepointSPBServer) error
+	TracepointBatch(TCPDog_TracepointBatchServer) error
+	TracepointAck(TCPDog_TracepointAckServer) error
 }
 
 // UnimplementedTCPDogServer can be embedded to have forward compatible implementations.
@@ -1051,6 +1347,12 @@ func (*UnimplementedTCPDogServer) Tracepoint(TCPDog_TracepointServer) error {
 func (*UnimplementedTCPDogServer) TracepointSPB(TCPDog_TracepointSPBServer) error {
 	return status.Errorf(codes.Unimplemented, "method TracepointSPB not implemented")
 }
+func (*UnimplementedTCPDogServer) TracepointBatch(TCPDog_TracepointBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method TracepointBatch not implemented")
+}
+func (*UnimplementedTCPDogServer) TracepointAck(TCPDog_TracepointAckServer) error {
+	return status.Errorf(codes.Unimplemented, "method TracepointAck not implemented")
+}
 
 func RegisterTCPDogServer(s *grpc.Server, srv TCPDogServer) {
 	s.RegisterService(&_TCPDog_serviceDesc, srv)
@@ -1108,6 +1410,58 @@ func (x *tCPDogTracepointSPBServer) Recv() (*FieldsSPB, error) {
 	return m, nil
 }
 
+func _TCPDog_TracepointBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TCPDogServer).TracepointBatch(&tCPDogTracepointBatchServer{stream})
+}
+
+type TCPDog_TracepointBatchServer interface {
+	SendAndClose(*Response) error
+	Recv() (*FieldsBatch, error)
+	grpc.ServerStream
+}
+
+type tCPDogTracepointBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *tCPDogTracepointBatchServer) SendAndClose(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *tCPDogTracepointBatchServer) Recv() (*FieldsBatch, error) {
+	m := new(FieldsBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TCPDog_TracepointAck_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TCPDogServer).TracepointAck(&tCPDogTracepointAckServer{stream})
+}
+
+type TCPDog_TracepointAckServer interface {
+	Send(*Ack) error
+	Recv() (*FieldsSeq, error)
+	grpc.ServerStream
+}
+
+type tCPDogTracepointAckServer struct {
+	grpc.ServerStream
+}
+
+func (x *tCPDogTracepointAckServer) Send(m *Ack) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *tCPDogTracepointAckServer) Recv() (*FieldsSeq, error) {
+	m := new(FieldsSeq)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _TCPDog_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "tcpdog.TCPDog",
 	HandlerType: (*TCPDogServer)(nil),
@@ -1123,6 +1477,17 @@ var _TCPDog_serviceDesc = grpc.ServiceDesc{
 			Handler:       _TCPDog_TracepointSPB_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "TracepointBatch",
+			Handler:       _TCPDog_TracepointBatch_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "TracepointAck",
+			Handler:       _TCPDog_TracepointAck_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "tcpdog.proto",
 }
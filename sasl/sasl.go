@@ -0,0 +1,119 @@
+// Package sasl configures the kafka SASL authentication
+// of the kafka egress and ingress.
+package sasl
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// Config represents kafka SASL configuration.
+type Config struct {
+	Mechanism string // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER
+	Username  string
+	Password  string
+	TokenFile string // OAUTHBEARER token, it's read per authentication
+}
+
+// Set applies the SASL configuration to the sarama configuration,
+// the mechanism defaults to OAUTHBEARER if there is a token file and
+// PLAIN otherwise.
+func Set(sConfig *sarama.Config, cfg Config) error {
+	if cfg.Mechanism == "" && cfg.Username == "" && cfg.TokenFile == "" {
+		return nil
+	}
+
+	mechanism := strings.ToUpper(cfg.Mechanism)
+	if mechanism == "" {
+		mechanism = sarama.SASLTypePlaintext
+		if cfg.TokenFile != "" {
+			mechanism = sarama.SASLTypeOAuth
+		}
+	}
+
+	sConfig.Net.SASL.Enable = true
+	sConfig.Net.SASL.Handshake = true
+	sConfig.Net.SASL.Mechanism = sarama.SASLMechanism(mechanism)
+	sConfig.Net.SASL.User = cfg.Username
+	sConfig.Net.SASL.Password = cfg.Password
+
+	switch mechanism {
+	case sarama.SASLTypePlaintext:
+		if sConfig.Version.IsAtLeast(sarama.V1_0_0_0) {
+			sConfig.Net.SASL.Version = sarama.SASLHandshakeV1
+		}
+		return nil
+	case sarama.SASLTypeSCRAMSHA256:
+		sConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &SCRAMClient{HashGeneratorFcn: scram.SHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		sConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &SCRAMClient{HashGeneratorFcn: scram.SHA512}
+		}
+	case sarama.SASLTypeOAuth:
+		if cfg.TokenFile == "" {
+			return errors.New("OAUTHBEARER requires a token file")
+		}
+		sConfig.Net.SASL.TokenProvider = TokenFile(cfg.TokenFile)
+	default:
+		return fmt.Errorf("unknown SASL mechanism: %s", cfg.Mechanism)
+	}
+
+	// the SCRAM and OAUTHBEARER exchanges run over the v1 handshake
+	if !sConfig.Version.IsAtLeast(sarama.V1_0_0_0) {
+		return fmt.Errorf("%s requires kafka version 1.0.0 and later", mechanism)
+	}
+
+	sConfig.Net.SASL.Version = sarama.SASLHandshakeV1
+
+	return nil
+}
+
+// TokenFile provides the OAUTHBEARER access token from a file, the file
+// is read per authentication so an external agent can refresh the token.
+type TokenFile string
+
+// Token returns the access token.
+func (t TokenFile) Token() (*sarama.AccessToken, error) {
+	b, err := ioutil.ReadFile(string(t))
+	if err != nil {
+		return nil, err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return nil, fmt.Errorf("empty token file: %s", t)
+	}
+
+	return &sarama.AccessToken{Token: token}, nil
+}
+
+// SCRAMClient represents a SCRAM client (RFC 5802).
+type SCRAMClient struct {
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+
+	nonce scram.NonceGeneratorFcn // it's only set by the tests
+}
+
+// Begin prepares the client for the SCRAM exchange.
+func (c *SCRAMClient) Begin(username, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(username, password, authzID)
+	if err != nil {
+		return err
+	}
+
+	if c.nonce != nil {
+		client = client.WithNonceGenerator(c.nonce)
+	}
+
+	c.ClientConversation = client.NewConversation()
+
+	return nil
+}
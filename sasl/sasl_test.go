@@ -0,0 +1,95 @@
+package sasl
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/xdg-go/scram"
+)
+
+// the SCRAM-SHA-256 exchange from RFC 7677
+func TestSCRAMClient(t *testing.T) {
+	c := &SCRAMClient{
+		HashGeneratorFcn: scram.SHA256,
+		nonce:            func() string { return "rOprNGfwEbeRWgbNEkqO" },
+	}
+	assert.NoError(t, c.Begin("user", "pencil", ""))
+
+	resp, err := c.Step("")
+	assert.NoError(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", resp)
+
+	resp, err = c.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.NoError(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", resp)
+	assert.False(t, c.Done())
+
+	_, err = c.Step("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")
+	assert.NoError(t, err)
+	assert.True(t, c.Done())
+
+	// invalid server signature
+	assert.NoError(t, c.Begin("user", "pencil", ""))
+	c.Step("")
+	c.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	_, err = c.Step("v=AAAA")
+	assert.Error(t, err)
+
+	// invalid server nonce
+	assert.NoError(t, c.Begin("user", "pencil", ""))
+	c.Step("")
+	_, err = c.Step("r=foo,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.Error(t, err)
+}
+
+func TestSet(t *testing.T) {
+	sConfig := sarama.NewConfig()
+	assert.NoError(t, Set(sConfig, Config{}))
+	assert.False(t, sConfig.Net.SASL.Enable)
+
+	sConfig.Version = sarama.V1_0_0_0
+	err := Set(sConfig, Config{Mechanism: "scram-sha-512", Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	assert.True(t, sConfig.Net.SASL.Enable)
+	assert.Equal(t, sarama.SASLHandshakeV1, sConfig.Net.SASL.Version)
+	assert.NotNil(t, sConfig.Net.SASL.SCRAMClientGeneratorFunc)
+	assert.NoError(t, sConfig.Validate())
+
+	sConfig = sarama.NewConfig()
+	err = Set(sConfig, Config{Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypePlaintext), sConfig.Net.SASL.Mechanism)
+
+	// SCRAM requires kafka 1.0
+	sConfig.Version = sarama.V0_10_2_0
+	err = Set(sConfig, Config{Mechanism: "SCRAM-SHA-256", Username: "user", Password: "pass"})
+	assert.Error(t, err)
+
+	err = Set(sConfig, Config{Mechanism: "GSSAPI"})
+	assert.Error(t, err)
+}
+
+func TestTokenFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "tcpdog")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	sConfig := sarama.NewConfig()
+	sConfig.Version = sarama.V2_0_0_0
+	err = Set(sConfig, Config{TokenFile: f.Name()})
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeOAuth), sConfig.Net.SASL.Mechanism)
+
+	_, err = sConfig.Net.SASL.TokenProvider.Token()
+	assert.Error(t, err)
+
+	f.WriteString("mytoken\n")
+	f.Close()
+
+	token, err := sConfig.Net.SASL.TokenProvider.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "mytoken", token.Token)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/ingress/grpc"
+)
+
+// startAPI starts the server's http api, the api address
+// is not reloadable.
+func startAPI(cfg *config.ServerConfig) {
+	addr := cfg.API
+	if addr == "" {
+		return
+	}
+
+	logger := cfg.Logger()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/agents", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(grpc.Agents())
+	})
+
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dropped": grpc.Dropped(),
+		})
+	})
+
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		logger.Fatal("api", zap.Error(err))
+	}()
+
+	logger.Info("api", zap.String("msg", "api has been started at "+addr))
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/ingress/grpc"
+)
+
+// server represents the running flows.
+type server struct {
+	sync.Mutex
+
+	cfg   *config.ServerConfig
+	flows []*flowInstance
+}
+
+// flowInstance represents a running flow, an ingress and an ingestion
+// which are connected through a forwarder so the ingestion can be
+// replaced while the ingress keeps running.
+type flowInstance struct {
+	flow    config.Flow
+	ingress config.Ingress
+	g       *ingestionInstance
+
+	iCancel  context.CancelFunc
+	iWG      *sync.WaitGroup
+	stopped  chan struct{} // closed once the ingestion has stopped
+	swap     chan *ingestionInstance
+	replaced chan *ingestionInstance
+	done     chan struct{} // closed once the forwarder has returned
+}
+
+// ingestionInstance represents a running ingestion of a flow.
+type ingestionInstance struct {
+	ingestion config.Ingestion
+	geo       config.Geo
+	ch        chan interface{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        *sync.WaitGroup
+}
+
+// errSwapTimeout is returned if the replaced ingestion couldn't flush
+// in time, its unstored events are not acknowledged.
+var errSwapTimeout = errors.New("replaced ingestion flush timed out")
+
+// closeTimeout is the time to wait for a canceled ingestion
+// and its ingress once the stop timeout has been exceeded.
+var closeTimeout = 5 * time.Second
+
+// newServer starts the flows.
+func newServer(cfg *config.ServerConfig) *server {
+	s := &server{cfg: cfg}
+
+	for _, flow := range cfg.Flow {
+		f, err := startFlow(cfg, flow)
+		if err != nil {
+			cfg.Logger().Fatal("flow", zap.Error(err))
+		}
+
+		s.flows = append(s.flows, f)
+	}
+
+	return s
+}
+
+// reload reads and validates the configuration file, then it stops the
+// removed or changed flows and starts the new ones, the unchanged flows
+// keep running. a flow which only its ingestion has changed keeps its
+// ingress and the ingestion is replaced. the running configuration stays
+// untouched if the new one is not valid, it returns an error if a flow
+// couldn't be started or replaced. a flow which its ingress has failed
+// is restarted.
+func (s *server) reload() error {
+	s.Lock()
+	defer s.Unlock()
+
+	logger := s.cfg.Logger()
+
+	cfg, err := s.cfg.Reload()
+	if err != nil {
+		return err
+	}
+
+	if err := validate(cfg); err != nil {
+		return err
+	}
+
+	var (
+		flows   []*flowInstance
+		kept    = map[*flowInstance]bool{}
+		swaps   = map[*flowInstance]config.Flow{}
+		changed []config.Flow
+		pending []config.Flow
+	)
+
+	for _, flow := range cfg.Flow {
+		if f := s.findFlow(cfg, flow, kept); f != nil {
+			kept[f] = true
+			flows = append(flows, f)
+			continue
+		}
+
+		changed = append(changed, flow)
+	}
+
+	for _, flow := range changed {
+		if f := s.findIngress(cfg, flow, kept); f != nil {
+			kept[f] = true
+			swaps[f] = flow
+			continue
+		}
+
+		pending = append(pending, flow)
+	}
+
+	// the changed flows should be stopped first as
+	// the new ones may listen on the same address.
+	var stopped []*flowInstance
+	for _, f := range s.flows {
+		if !kept[f] {
+			stopped = append(stopped, f)
+		}
+	}
+
+	s.stopFlows(stopped)
+
+	failed := 0
+
+	for f, flow := range swaps {
+		f, err := s.swapIngestion(cfg, f, flow)
+		if err != nil {
+			logger.Error("flow", zap.String("ingress", flow.Ingress),
+				zap.String("ingestion", flow.Ingestion), zap.Error(err))
+			failed++
+		}
+
+		if f != nil {
+			flows = append(flows, f)
+		}
+	}
+
+	for _, flow := range pending {
+		f, err := startFlow(cfg, flow)
+		if err != nil {
+			logger.Error("flow", zap.String("ingress", flow.Ingress),
+				zap.String("ingestion", flow.Ingestion), zap.Error(err))
+			failed++
+			continue
+		}
+
+		flows = append(flows, f)
+	}
+
+	s.cfg, s.flows = cfg, flows
+
+	logger.Info("tcpdog", zap.String("msg", "configuration has been reloaded"),
+		zap.Int("started", len(pending)), zap.Int("replaced", len(swaps)),
+		zap.Int("stopped", len(stopped)), zap.Int("failed", failed))
+
+	if failed > 0 {
+		return fmt.Errorf("%d flow(s) couldn't be started or replaced", failed)
+	}
+
+	return nil
+}
+
+// shutdown stops all the flows.
+func (s *server) shutdown() {
+	s.Lock()
+	defer s.Unlock()
+
+	logger := s.cfg.Logger()
+	logger.Info("tcpdog", zap.String("msg", "shutting down"))
+
+	if s.stopFlows(s.flows) {
+		logger.Info("tcpdog", zap.String("msg", "shutdown completed"))
+	}
+
+	if dropped := grpc.Dropped(); len(dropped) > 0 {
+		logger.Warn("tcpdog", zap.String("msg", "grpc ingress dropped events"), zap.Any("agents", dropped))
+	}
+}
+
+// stopFlows stops the flows concurrently up to the configured timeout.
+func (s *server) stopFlows(flows []*flowInstance) bool {
+	var (
+		wg sync.WaitGroup
+		ok = true
+		mu sync.Mutex
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	for _, f := range flows {
+		wg.Add(1)
+		go func(f *flowInstance) {
+			defer wg.Done()
+
+			if err := f.stop(ctx.Done()); err != nil {
+				s.cfg.Logger().Warn("flow", zap.String("ingress", f.flow.Ingress),
+					zap.String("ingestion", f.flow.Ingestion), zap.Error(err))
+
+				mu.Lock()
+				ok = false
+				mu.Unlock()
+			}
+		}(f)
+	}
+
+	wg.Wait()
+
+	return ok
+}
+
+// swapIngestion replaces the ingestion of a flow, the flow keeps
+// its running ingestion if the new one couldn't be started. the flow
+// is restarted if the replaced ingestion couldn't flush in time, so
+// the new ingress redelivers the events which haven't been stored.
+func (s *server) swapIngestion(cfg *config.ServerConfig, f *flowInstance, flow config.Flow) (*flowInstance, error) {
+	timeout := time.Duration(s.cfg.ShutdownTimeout) * time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := f.swapIngestion(cfg, flow, ctx.Done())
+	if err != errSwapTimeout {
+		return f, err
+	}
+
+	s.cfg.Logger().Warn("flow", zap.String("ingress", flow.Ingress), zap.String("ingestion", flow.Ingestion),
+		zap.Error(err), zap.String("msg", "restarting the flow to redeliver the unstored events"))
+
+	sctx, scancel := context.WithTimeout(context.Background(), timeout)
+	defer scancel()
+
+	if err := f.stop(sctx.Done()); err != nil {
+		s.cfg.Logger().Warn("flow", zap.String("ingress", flow.Ingress),
+			zap.String("ingestion", flow.Ingestion), zap.Error(err))
+	}
+
+	return startFlow(cfg, flow)
+}
+
+// findFlow returns a running flow with the same configuration.
+func (s *server) findFlow(cfg *config.ServerConfig, flow config.Flow, kept map[*flowInstance]bool) *flowInstance {
+	for _, f := range s.flows {
+		if kept[f] || !f.running() {
+			continue
+		}
+
+		if f.flow == flow && reflect.DeepEqual(f.ingress, cfg.Ingress[flow.Ingress]) &&
+			reflect.DeepEqual(f.g.ingestion, cfg.Ingestion[flow.Ingestion]) &&
+			reflect.DeepEqual(f.g.geo, cfg.Geo) {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// findIngress returns a running flow with the same ingress configuration.
+func (s *server) findIngress(cfg *config.ServerConfig, flow config.Flow, kept map[*flowInstance]bool) *flowInstance {
+	for _, f := range s.flows {
+		if kept[f] || !f.running() {
+			continue
+		}
+
+		if f.flow.Ingress == flow.Ingress && f.flow.Serialization == flow.Serialization &&
+			reflect.DeepEqual(f.ingress, cfg.Ingress[flow.Ingress]) {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// startFlow starts the ingestion and then the ingress of a flow.
+func startFlow(cfg *config.ServerConfig, flow config.Flow) (*flowInstance, error) {
+	g, err := startIngestion(cfg, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	iCtx, iCancel := context.WithCancel(context.Background())
+
+	f := &flowInstance{
+		flow:     flow,
+		ingress:  cfg.Ingress[flow.Ingress],
+		g:        g,
+		iCancel:  iCancel,
+		iWG:      new(sync.WaitGroup),
+		stopped:  make(chan struct{}),
+		swap:     make(chan *ingestionInstance),
+		replaced: make(chan *ingestionInstance),
+		done:     make(chan struct{}),
+	}
+
+	ch := make(chan interface{}, 1000)
+
+	if err := ingress(cfg.WithContext(iCtx), flow, ch, f.iWG, f.stopped); err != nil {
+		iCancel()
+		g.cancel()
+		return nil, err
+	}
+
+	go f.forward(ch, g)
+
+	return f, nil
+}
+
+// startIngestion starts the ingestion of a flow, the ingestions have
+// their own context as they should outlive the ingresses to drain and flush.
+func startIngestion(cfg *config.ServerConfig, flow config.Flow) (*ingestionInstance, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	g := &ingestionInstance{
+		ingestion: cfg.Ingestion[flow.Ingestion],
+		geo:       cfg.Geo,
+		ch:        make(chan interface{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		wg:        new(sync.WaitGroup),
+	}
+
+	if err := ingestion(cfg.WithContext(ctx), flow, g.ch, g.wg); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// forward forwards the ingress events to the ingestion, the ingestion
+// is replaced once a new one is received and the replaced one is handed
+// back to drain. it closes the ingestion channel once the ingress has
+// closed its channel.
+func (f *flowInstance) forward(ch chan interface{}, g *ingestionInstance) {
+	defer close(f.done)
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				close(g.ch)
+				return
+			}
+
+			// the event isn't acknowledged if the ingestion has been canceled
+			select {
+			case g.ch <- e:
+			case <-g.ctx.Done():
+			}
+		case n := <-f.swap:
+			f.replaced <- g
+			g = n
+		}
+	}
+}
+
+// running returns false once the ingress has closed its channel, e.g. it
+// has failed, the flow has ended then and it's restarted at reload.
+func (f *flowInstance) running() bool {
+	select {
+	case <-f.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// swapIngestion starts the new ingestion and replaces the running one,
+// the replaced ingestion drains and flushes its events up to the timeout.
+func (f *flowInstance) swapIngestion(cfg *config.ServerConfig, flow config.Flow, timeout <-chan struct{}) error {
+	g, err := startIngestion(cfg, flow)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case f.swap <- g:
+	case <-f.done:
+		g.cancel()
+		return errors.New("ingress has been stopped")
+	}
+
+	old := <-f.replaced
+	f.flow, f.g = flow, g
+
+	defer old.cancel()
+
+	close(old.ch)
+
+	if !waitTimeout(old.wg, timeout) {
+		return errSwapTimeout
+	}
+
+	return nil
+}
+
+// stop stops the ingress and waits for the ingestion to drain and flush
+// the flow, then it lets the ingress commit the events which have been
+// acknowledged (e.g. kafka offsets) and close. if the flush timed out,
+// the ingestion is canceled and its unstored events are not committed.
+func (f *flowInstance) stop(timeout <-chan struct{}) error {
+	defer f.g.cancel()
+
+	f.iCancel()
+
+	if waitTimeout(f.g.wg, timeout) {
+		close(f.stopped)
+
+		if !waitTimeout(f.iWG, timeout) {
+			return errors.New("ingress close timed out")
+		}
+
+		return nil
+	}
+
+	f.g.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	// the ingress is left behind if the ingestion doesn't return
+	if waitTimeout(f.g.wg, ctx.Done()) {
+		close(f.stopped)
+		waitTimeout(f.iWG, ctx.Done())
+	}
+
+	return errors.New("ingestion flush timed out, the unstored events are not committed")
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout <-chan struct{}) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-timeout:
+		return false
+	}
+}
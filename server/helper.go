@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"go.uber.org/zap"
 
@@ -13,60 +14,66 @@ import (
 	"github.com/mehrdadrad/tcpdog/ingestion/elasticsearch"
 	"github.com/mehrdadrad/tcpdog/ingestion/influxdb"
 	"github.com/mehrdadrad/tcpdog/ingress/grpc"
+	"github.com/mehrdadrad/tcpdog/ingress/http"
 	"github.com/mehrdadrad/tcpdog/ingress/kafka"
+	"github.com/mehrdadrad/tcpdog/ingress/mqtt"
+	"github.com/mehrdadrad/tcpdog/ingress/nats"
 )
 
-func ingress(ctx context.Context, flow config.Flow, ch chan interface{}) {
+func ingress(ctx context.Context, flow config.Flow, ch chan interface{}, wg *sync.WaitGroup, stopped <-chan struct{}) error {
+	var err error
+
 	cfg := config.FromContextServer(ctx)
-	logger := cfg.Logger()
+	iType := cfg.Ingress[flow.Ingress].Type
 
-	switch cfg.Ingress[flow.Ingress].Type {
+	switch iType {
 	case "grpc":
-		err := grpc.Start(ctx, flow.Ingress, ch)
-		if err != nil {
-			logger.Fatal("grpc", zap.Error(err))
-		}
-
-		logger.Info("grpc", zap.String("msg", flow.Ingress+" has been started"))
-
+		err = grpc.Start(ctx, flow.Ingress, ch, wg)
+	case "http":
+		err = http.Start(ctx, flow.Ingress, ch, wg)
 	case "kafka":
-		err := kafka.Start(ctx, flow.Ingress, flow.Serialization, ch)
-		if err != nil {
-			logger.Fatal("kafka", zap.Error(err))
-		}
+		err = kafka.Start(ctx, flow.Ingress, flow.Serialization, ch, wg, stopped)
+	case "nats":
+		err = nats.Start(ctx, flow.Ingress, flow.Serialization, ch, wg, stopped)
+	case "mqtt":
+		err = mqtt.Start(ctx, flow.Ingress, flow.Serialization, ch, wg, stopped)
+	default:
+		err = fmt.Errorf("ingress type %s is not supported", iType)
+	}
 
-		logger.Info("kafka", zap.String("msg", flow.Ingress+" has been started"))
+	if err != nil {
+		return err
 	}
+
+	cfg.Logger().Info(iType, zap.String("msg", flow.Ingress+" has been started"))
+
+	return nil
 }
 
-func ingestion(ctx context.Context, flow config.Flow, ch chan interface{}) {
+func ingestion(ctx context.Context, flow config.Flow, ch chan interface{}, wg *sync.WaitGroup) error {
+	var err error
+
 	cfg := config.FromContextServer(ctx)
-	logger := cfg.Logger()
+	iType := cfg.Ingestion[flow.Ingestion].Type
 
-	switch cfg.Ingestion[flow.Ingestion].Type {
+	switch iType {
 	case "influxdb":
-		err := influxdb.Start(ctx, flow.Ingestion, flow.Serialization, ch)
-		if err != nil {
-			logger.Fatal("influxdb", zap.Error(err))
-		}
-
-		logger.Info("influxdb", zap.String("msg", flow.Ingestion+" has been started"))
-
+		err = influxdb.Start(ctx, flow.Ingestion, flow.Serialization, ch, wg)
 	case "elasticsearch":
-		err := elasticsearch.Start(ctx, flow.Ingestion, flow.Serialization, ch)
-		if err != nil {
-			logger.Fatal("elasticsearch", zap.Error(err))
-		}
-
-		logger.Info("elasticsearch", zap.String("msg", flow.Ingestion+" has been started"))
+		err = elasticsearch.Start(ctx, flow.Ingestion, flow.Serialization, ch, wg)
 	case "clickhouse":
-		err := clickhouse.Start(ctx, flow.Ingestion, flow.Serialization, ch)
-		if err != nil {
-			logger.Fatal("clickhouse", zap.Error(err))
-		}
+		err = clickhouse.Start(ctx, flow.Ingestion, flow.Serialization, ch, wg)
+	default:
+		err = fmt.Errorf("ingestion type %s is not supported", iType)
+	}
 
-		logger.Info("clickhouse", zap.String("msg", flow.Ingestion+" has been started"))
+	if err != nil {
+		return err
 	}
+
+	cfg.Logger().Info(iType, zap.String("msg", flow.Ingestion+" has been started"))
+
+	return nil
 }
 
 func validate(cfg *config.ServerConfig) error {
@@ -98,6 +105,11 @@ func validateFlow(cfg *config.ServerConfig) error {
 		if _, ok := cfg.Ingress[f.Ingress]; !ok {
 			return fmt.Errorf("ingress %s is not available", f.Ingress)
 		}
+
+		// the http ingress accepts only json events
+		if cfg.Ingress[f.Ingress].Type == "http" && f.Serialization != "json" {
+			return fmt.Errorf("ingress %s requires json serialization", f.Ingress)
+		}
 	}
 
 	return nil
@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/sethvargo/go-signalcontext"
 	"go.uber.org/zap"
@@ -28,13 +30,22 @@ func main() {
 	ctx, cancel := signalcontext.OnInterrupt()
 	defer cancel()
 
-	ctx = cfg.WithContext(ctx)
-
-	for _, flow := range cfg.Flow {
-		ch := make(chan interface{}, 1000)
-		ingress(ctx, flow, ch)
-		ingestion(ctx, flow, ch)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	s := newServer(cfg)
+	startAPI(cfg)
+
+	for {
+		select {
+		case <-hup:
+			logger.Info("tcpdog", zap.String("msg", "reloading configuration"))
+			if err := s.reload(); err != nil {
+				logger.Error("reload", zap.Error(err))
+			}
+		case <-ctx.Done():
+			s.shutdown()
+			return
+		}
 	}
-
-	<-ctx.Done()
 }
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/ebpf"
+	"github.com/mehrdadrad/tcpdog/egress"
+	"github.com/mehrdadrad/tcpdog/expr"
+)
+
+// agent represents the running tracepoints and egresses.
+type agent struct {
+	sync.Mutex
+
+	cfg      *config.Config
+	bpf      tracer
+	bufPool  *sync.Pool
+	egresses map[string]*egressInstance
+	tps      []*tpInstance
+	nextID   int
+}
+
+// tracer represents the bpf programs of the tracepoints.
+type tracer interface {
+	Load(cfg *config.Config, index, id int) error
+	Attach(ctx context.Context, tp ebpf.TP) error
+	Start(ctx context.Context, tp ebpf.TP)
+	Stop(id int)
+	Close()
+	Dropped() uint64
+	PerfLost() uint64
+	Counters() map[string]uint64
+}
+
+// egressInstance represents a running egress.
+type egressInstance struct {
+	ch     chan *bytes.Buffer
+	wg     *sync.WaitGroup
+	cancel context.CancelFunc
+	conf   config.EgressConfig
+	fields []config.Field
+}
+
+// tpInstance represents an attached tracepoint.
+type tpInstance struct {
+	id      int
+	index   int
+	tp      config.Tracepoint
+	fields  []config.Field
+	derived []config.DerivedField
+	routes  []config.Route
+}
+
+// newAgent loads the bpf programs and starts the egresses and the tracepoints.
+func newAgent(cfg *config.Config) *agent {
+	logger := cfg.Logger()
+
+	a := &agent{
+		cfg:      cfg,
+		bpf:      ebpf.New(cfg),
+		egresses: map[string]*egressInstance{},
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				return new(bytes.Buffer)
+			},
+		},
+	}
+
+	for _, tracepoint := range cfg.Tracepoints {
+		for _, ref := range cfg.GetTPEgressRefs(tracepoint) {
+			if _, ok := a.egresses[ref.Name]; ok {
+				continue
+			}
+
+			e, err := a.startEgress(cfg, tracepoint.ForEgress(ref))
+			if err != nil {
+				logger.Fatal("egress", zap.Error(err))
+			}
+
+			a.egresses[ref.Name] = e
+		}
+	}
+
+	ctx := cfg.WithContext(context.Background())
+	for index := range cfg.Tracepoints {
+		a.bpf.Start(ctx, a.getTP(cfg, index, index, a.egresses))
+		a.tps = append(a.tps, newTPInstance(cfg, index, index))
+	}
+
+	a.nextID = len(cfg.Tracepoints)
+
+	return a
+}
+
+// reload reads and validates the configuration file, then it attaches the
+// new tracepoints before the removed or changed ones are detached, so the
+// capture gap is minimized, the events may be emitted twice meanwhile.
+// a replaced egress which holds a socket, a spool or a file is stopped
+// along with its tracepoints before its replacement starts, so they don't
+// compete for it. the running configuration stays untouched if the new
+// one is not valid or its programs couldn't be loaded, and it's restored
+// if the new one couldn't be started.
+func (a *agent) reload() error {
+	a.Lock()
+	defer a.Unlock()
+
+	cfg, err := a.cfg.Reload()
+	if err != nil {
+		return err
+	}
+
+	if err := validate(cfg); err != nil {
+		return err
+	}
+
+	return a.apply(cfg)
+}
+
+// apply replaces the running configuration, the unchanged
+// tracepoints and egresses keep running.
+func (a *agent) apply(cfg *config.Config) error {
+	logger := a.cfg.Logger()
+
+	egresses, tps := a.kept(cfg)
+
+	// the programs are compiled before anything is stopped
+	loaded := map[int]int{}
+	for index := range cfg.Tracepoints {
+		if _, ok := tps[index]; ok {
+			continue
+		}
+
+		if err := a.bpf.Load(cfg, index, a.nextID); err != nil {
+			for _, id := range loaded {
+				a.bpf.Stop(id)
+			}
+			return err
+		}
+
+		loaded[index] = a.nextID
+		a.nextID++
+	}
+
+	var (
+		exclusive []*egressInstance
+		stopped   []*egressInstance
+		early     = map[*egressInstance]bool{}
+	)
+
+	for name, e := range a.egresses {
+		if egresses[name] == e {
+			continue
+		}
+
+		if egress.Exclusive(e.conf) {
+			exclusive = append(exclusive, e)
+			early[e] = true
+		} else {
+			stopped = append(stopped, e)
+		}
+	}
+
+	var (
+		detached []*tpInstance
+		late     []*tpInstance
+		keptTPs  []*tpInstance
+		restore  = map[int]*tpInstance{}
+		kept     = map[*tpInstance]bool{}
+	)
+
+	for _, o := range tps {
+		kept[o] = true
+	}
+
+	// the tracepoints which feed an exclusive egress are detached before
+	// it stops, the others are detached once the new ones are attached.
+	for _, o := range a.tps {
+		if !kept[o] && a.feeds(o, early) {
+			detached = append(detached, o)
+			continue
+		}
+
+		if !kept[o] {
+			late = append(late, o)
+		}
+
+		keptTPs = append(keptTPs, o)
+		restore[o.index] = o
+	}
+
+	a.detach(detached)
+	a.stopEgresses(exclusive)
+
+	newEgresses, newTPs, err := a.start(cfg, egresses, tps, loaded)
+	if err != nil {
+		logger.Error("tcpdog", zap.String("msg", "restoring the previous configuration"), zap.Error(err))
+
+		running := map[string]*egressInstance{}
+		for name, e := range a.egresses {
+			if !early[e] {
+				running[name] = e
+			}
+		}
+
+		a.egresses, a.tps = running, keptTPs
+
+		oldEgresses, oldTPs, rErr := a.start(a.cfg, running, restore, nil)
+		if rErr != nil {
+			logger.Error("tcpdog", zap.String("msg", "previous configuration couldn't be restored"), zap.Error(rErr))
+			return err
+		}
+
+		a.egresses, a.tps = oldEgresses, oldTPs
+
+		return err
+	}
+
+	a.detach(late)
+	a.stopEgresses(stopped)
+
+	a.cfg, a.egresses, a.tps = cfg, newEgresses, newTPs
+
+	logger.Info("tcpdog", zap.String("msg", "configuration has been reloaded"),
+		zap.Int("tracepoints", len(loaded)), zap.Int("egresses", len(newEgresses)-len(egresses)),
+		zap.Int("detached", len(detached)+len(late)), zap.Int("stopped", len(exclusive)+len(stopped)))
+
+	return nil
+}
+
+// detach detaches the tracepoints concurrently, it returns once
+// their workers handed over the events to the egresses.
+func (a *agent) detach(tps []*tpInstance) {
+	var wg sync.WaitGroup
+
+	for _, o := range tps {
+		wg.Add(1)
+		go func(o *tpInstance) {
+			defer wg.Done()
+			a.bpf.Stop(o.id)
+			a.cfg.Logger().Info("ebpf", zap.String("msg", o.tp.Name+" has been detached"))
+		}(o)
+	}
+
+	wg.Wait()
+}
+
+// feeds returns true if the running tracepoint sends its events to any of the egresses.
+func (a *agent) feeds(o *tpInstance, egresses map[*egressInstance]bool) bool {
+	for _, ref := range a.cfg.GetTPEgressRefs(o.tp) {
+		if egresses[a.egresses[ref.Name]] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// kept returns the running egresses and tracepoints which are unchanged
+// at the configuration, the tracepoints are keyed by their new index.
+func (a *agent) kept(cfg *config.Config) (map[string]*egressInstance, map[int]*tpInstance) {
+	var (
+		egresses = map[string]*egressInstance{}
+		tps      = map[int]*tpInstance{}
+		seen     = map[string]bool{}
+		kept     = map[*tpInstance]bool{}
+	)
+
+	for _, tracepoint := range cfg.Tracepoints {
+		for _, ref := range cfg.GetTPEgressRefs(tracepoint) {
+			if seen[ref.Name] {
+				continue
+			}
+
+			seen[ref.Name] = true
+			tp := tracepoint.ForEgress(ref)
+
+			old, ok := a.egresses[ref.Name]
+			if ok && reflect.DeepEqual(old.conf, cfg.Egress[ref.Name]) &&
+				reflect.DeepEqual(old.fields, cfg.GetEgressOutFields(tp)) {
+				egresses[ref.Name] = old
+			}
+		}
+	}
+
+	for index, tracepoint := range cfg.Tracepoints {
+		o := a.findTP(newTPInstance(cfg, index, 0), kept)
+		if o != nil && keptEgresses(cfg, tracepoint, egresses) {
+			kept[o] = true
+			tps[index] = o
+		}
+	}
+
+	return egresses, tps
+}
+
+// start starts the egresses and attaches the tracepoints of the configuration
+// which are not running, the running tracepoints are keyed by their index and
+// the loaded programs' ids by the tracepoint index. it stops whatever it has
+// started or loaded if it fails.
+func (a *agent) start(cfg *config.Config, running map[string]*egressInstance,
+	attached map[int]*tpInstance, loaded map[int]int) (map[string]*egressInstance, []*tpInstance, error) {
+	var (
+		egresses = map[string]*egressInstance{}
+		started  []*egressInstance
+		tps      []*tpInstance
+		ids      []int
+	)
+
+	for name, e := range running {
+		egresses[name] = e
+	}
+
+	for _, id := range loaded {
+		ids = append(ids, id)
+	}
+
+	rollback := func(err error) (map[string]*egressInstance, []*tpInstance, error) {
+		for _, id := range ids {
+			a.bpf.Stop(id)
+		}
+		a.stopEgresses(started)
+		return nil, nil, err
+	}
+
+	for _, tracepoint := range cfg.Tracepoints {
+		for _, ref := range cfg.GetTPEgressRefs(tracepoint) {
+			if _, ok := egresses[ref.Name]; ok {
+				continue
+			}
+
+			e, err := a.startEgress(cfg, tracepoint.ForEgress(ref))
+			if err != nil {
+				return rollback(err)
+			}
+
+			egresses[ref.Name] = e
+			started = append(started, e)
+		}
+	}
+
+	ctx := cfg.WithContext(context.Background())
+	for index := range cfg.Tracepoints {
+		if o, ok := attached[index]; ok {
+			t := *o
+			t.index = index
+			tps = append(tps, &t)
+			continue
+		}
+
+		id, ok := loaded[index]
+		if !ok {
+			id = a.nextID
+			if err := a.bpf.Load(cfg, index, id); err != nil {
+				return rollback(err)
+			}
+
+			a.nextID++
+			ids = append(ids, id)
+		}
+
+		if err := a.bpf.Attach(ctx, a.getTP(cfg, index, id, egresses)); err != nil {
+			return rollback(err)
+		}
+
+		tps = append(tps, newTPInstance(cfg, index, id))
+	}
+
+	return egresses, tps, nil
+}
+
+// shutdown detaches the tracepoints and drains the perf buffers, then it
+// closes the egress channels and waits for the egresses to flush up to
+// the configured timeout.
+func (a *agent) shutdown() {
+	a.Lock()
+	defer a.Unlock()
+
+	logger := a.cfg.Logger()
+	logger.Info("tcpdog", zap.String("msg", "shutting down"))
+
+	a.bpf.Close()
+
+	var egresses []*egressInstance
+	for _, e := range a.egresses {
+		egresses = append(egresses, e)
+	}
+
+	dropped := a.bpf.Dropped() + a.stopEgresses(egresses)
+
+	logger.Info("tcpdog", zap.String("msg", "shutdown completed"), zap.Uint64("dropped", dropped),
+		zap.Any("overflow", a.bpf.Counters()), zap.Uint64("perf_lost", a.bpf.PerfLost()))
+}
+
+// stopEgresses closes the egress channels and waits for the egresses to
+// flush up to the configured timeout, it returns the number of dropped events.
+func (a *agent) stopEgresses(egresses []*egressInstance) uint64 {
+	var dropped uint64
+
+	done := make(chan struct{})
+	go func() {
+		for _, e := range egresses {
+			close(e.ch)
+			e.wg.Wait()
+		}
+		close(done)
+	}()
+
+	timeout := time.Duration(a.cfg.ShutdownTimeout) * time.Second
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		a.cfg.Logger().Warn("tcpdog", zap.String("msg", fmt.Sprintf("egress flush timed out after %s", timeout)))
+	}
+
+	for _, e := range egresses {
+		e.cancel()
+		dropped += uint64(len(e.ch))
+	}
+
+	return dropped
+}
+
+// startEgress starts an egress, the tracepoint is bound to the egress.
+func (a *agent) startEgress(cfg *config.Config, tracepoint config.Tracepoint) (*egressInstance, error) {
+	// egresses have their own context as they should
+	// outlive the tracepoints to drain and flush.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e := &egressInstance{
+		ch:     make(chan *bytes.Buffer, cfg.Egress[tracepoint.Egress].ChannelSize),
+		wg:     new(sync.WaitGroup),
+		cancel: cancel,
+		conf:   cfg.Egress[tracepoint.Egress],
+		fields: cfg.GetEgressOutFields(tracepoint),
+	}
+
+	err := egress.Start(cfg.WithContext(ctx), tracepoint, a.bufPool, e.ch, e.wg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	eType := cfg.Egress[tracepoint.Egress].Type
+	cfg.Logger().Info("egress", zap.String("msg", tracepoint.Egress+" has been started"), zap.String("type", eType))
+
+	return e, nil
+}
+
+func (a *agent) getTP(cfg *config.Config, index, id int, egresses map[string]*egressInstance) ebpf.TP {
+	var (
+		outputs []ebpf.Output
+		routes  []ebpf.Route
+	)
+
+	tracepoint := cfg.Tracepoints[index]
+
+	// the routes and filters have been validated
+	for _, r := range cfg.Routes[tracepoint.Routes] {
+		route := ebpf.Route{}
+		if r.Match != "" {
+			route.Match, _ = expr.Parse(r.Match)
+		}
+
+		for _, ref := range r.Egresses {
+			route.Outputs = append(route.Outputs, getOutput(cfg, tracepoint, ref, egresses))
+		}
+
+		routes = append(routes, route)
+	}
+
+	if tracepoint.Routes == "" {
+		for _, ref := range tracepoint.EgressRefs() {
+			outputs = append(outputs, getOutput(cfg, tracepoint, ref, egresses))
+		}
+	}
+
+	return ebpf.TP{
+		Name:    tracepoint.Name,
+		Index:   id,
+		BufPool: a.bufPool,
+		Outputs: outputs,
+		Routes:  routes,
+		INet:    tracepoint.INet,
+		Workers: tracepoint.Workers,
+		Fields:  cfg.GetTPFields(tracepoint.Fields),
+		Derived: cfg.Derived[tracepoint.Fields],
+	}
+}
+
+// findTP returns a running tracepoint with the same configuration.
+func (a *agent) findTP(n *tpInstance, kept map[*tpInstance]bool) *tpInstance {
+	for _, o := range a.tps {
+		if kept[o] {
+			continue
+		}
+
+		if reflect.DeepEqual(o.tp, n.tp) && reflect.DeepEqual(o.fields, n.fields) &&
+			reflect.DeepEqual(o.derived, n.derived) && reflect.DeepEqual(o.routes, n.routes) {
+			return o
+		}
+	}
+
+	return nil
+}
+
+func getOutput(cfg *config.Config, tracepoint config.Tracepoint, ref config.EgressRef,
+	egresses map[string]*egressInstance) ebpf.Output {
+	out := ebpf.Output{
+		Name:     ref.Name,
+		Chan:     egresses[ref.Name].ch,
+		Overflow: cfg.Egress[ref.Name].Overflow,
+	}
+
+	if len(ref.Fields) > 0 {
+		for _, f := range cfg.GetEgressOutFields(tracepoint.ForEgress(ref)) {
+			out.Fields = append(out.Fields, f.Name)
+		}
+	}
+
+	if ref.Filter != "" {
+		out.Filter, _ = expr.Parse(ref.Filter)
+	}
+
+	return out
+}
+
+// keptEgresses returns true if all the tracepoint's egresses are kept.
+func keptEgresses(cfg *config.Config, tracepoint config.Tracepoint, kept map[string]*egressInstance) bool {
+	for _, ref := range cfg.GetTPEgressRefs(tracepoint) {
+		if _, ok := kept[ref.Name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newTPInstance(cfg *config.Config, index, id int) *tpInstance {
+	tracepoint := cfg.Tracepoints[index]
+
+	return &tpInstance{
+		id:      id,
+		index:   index,
+		tp:      tracepoint,
+		fields:  cfg.Fields[tracepoint.Fields],
+		derived: cfg.Derived[tracepoint.Fields],
+		routes:  cfg.Routes[tracepoint.Routes],
+	}
+}
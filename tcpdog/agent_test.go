@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+	"github.com/mehrdadrad/tcpdog/ebpf"
+)
+
+// mockTracer records the loaded, attached and stopped programs.
+type mockTracer struct {
+	sync.Mutex
+
+	ops       []string
+	attachErr error
+	onAttach  func()
+}
+
+func (m *mockTracer) record(op string, id int) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.ops = append(m.ops, fmt.Sprintf("%s %d", op, id))
+}
+
+func (m *mockTracer) Load(cfg *config.Config, index, id int) error {
+	m.record("load", id)
+	return nil
+}
+
+func (m *mockTracer) Attach(ctx context.Context, tp ebpf.TP) error {
+	if m.onAttach != nil {
+		m.onAttach()
+	}
+
+	if m.attachErr != nil {
+		return m.attachErr
+	}
+
+	m.record("attach", tp.Index)
+	return nil
+}
+
+func (m *mockTracer) Start(ctx context.Context, tp ebpf.TP) {
+	m.record("attach", tp.Index)
+}
+
+func (m *mockTracer) Stop(id int)                 { m.record("stop", id) }
+func (m *mockTracer) Close()                      {}
+func (m *mockTracer) Dropped() uint64             { return 0 }
+func (m *mockTracer) PerfLost() uint64            { return 0 }
+func (m *mockTracer) Counters() map[string]uint64 { return nil }
+
+func (m *mockTracer) reset() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.ops = nil
+}
+
+func testConfig(channelSize int) *config.Config {
+	cfg := &config.Config{
+		Tracepoints: []config.Tracepoint{
+			{Name: "sock:inet_sock_set_state", Fields: "f1", Egress: "foo", INet: []int{4}, Workers: 1},
+			{Name: "tcp:tcp_retransmit_skb", Fields: "f1", Egress: "bar", INet: []int{4}, Workers: 1},
+		},
+		Fields: map[string][]config.Field{
+			"f1": {{Name: "PID"}, {Name: "Task"}},
+		},
+		Egress: map[string]config.EgressConfig{
+			"foo": {Type: "console", ChannelSize: channelSize},
+			"bar": {Type: "console"},
+		},
+		ShutdownTimeout: 5,
+	}
+
+	cfg.SetMockLogger("memory")
+
+	return cfg
+}
+
+func testAgent(t *testing.T) (*agent, *mockTracer) {
+	m := &mockTracer{}
+	a := &agent{
+		cfg:      testConfig(10),
+		bpf:      m,
+		egresses: map[string]*egressInstance{},
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				return new(bytes.Buffer)
+			},
+		},
+	}
+
+	assert.NoError(t, a.apply(a.cfg))
+	assert.Equal(t, []string{"load 0", "load 1", "attach 0", "attach 1"}, m.ops)
+	assert.Len(t, a.egresses, 2)
+	assert.Len(t, a.tps, 2)
+
+	m.reset()
+
+	return a, m
+}
+
+func TestReloadUnchanged(t *testing.T) {
+	a, m := testAgent(t)
+	egresses, tps := a.egresses, a.tps
+
+	assert.NoError(t, a.apply(testConfig(10)))
+	assert.Len(t, m.ops, 0)
+	assert.Equal(t, egresses, a.egresses)
+	assert.Equal(t, tps, a.tps)
+}
+
+func TestReloadAttachBeforeDetach(t *testing.T) {
+	a, m := testAgent(t)
+	foo, bar := a.egresses["foo"], a.egresses["bar"]
+
+	closed := false
+	m.onAttach = func() {
+		select {
+		case _, ok := <-foo.ch:
+			closed = !ok
+		default:
+		}
+	}
+
+	cfg := testConfig(20)
+	assert.NoError(t, a.apply(cfg))
+
+	// the new tracepoint is attached before the replaced one is detached
+	// and its egress is stopped, the other one keeps running.
+	assert.Equal(t, []string{"load 2", "attach 2", "stop 0"}, m.ops)
+	assert.False(t, closed)
+	assert.Equal(t, bar, a.egresses["bar"])
+	assert.NotEqual(t, foo, a.egresses["foo"])
+	assert.Equal(t, cfg, a.cfg)
+	assert.Equal(t, 0, a.tps[0].index)
+	assert.Equal(t, 2, a.tps[0].id)
+	assert.Equal(t, 1, a.tps[1].id)
+
+	_, ok := <-foo.ch
+	assert.False(t, ok)
+}
+
+func TestReloadExclusive(t *testing.T) {
+	file, err := ioutil.TempFile("", "tcpdog")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	a, m := testAgent(t)
+
+	cfg := testConfig(10)
+	cfg.Egress["foo"] = config.EgressConfig{Type: "jsonl", Config: map[string]interface{}{"filename": file.Name()}}
+	assert.NoError(t, a.apply(cfg))
+	m.reset()
+
+	foo := a.egresses["foo"]
+
+	closed := false
+	m.onAttach = func() {
+		select {
+		case _, ok := <-foo.ch:
+			closed = !ok
+		default:
+		}
+	}
+
+	cfg = testConfig(10)
+	cfg.Egress["foo"] = config.EgressConfig{Type: "jsonl", ChannelSize: 20, Config: map[string]interface{}{"filename": file.Name()}}
+	assert.NoError(t, a.apply(cfg))
+
+	// the egress holds the file, so it's stopped along with
+	// its tracepoint before the replacements start.
+	assert.Equal(t, []string{"load 3", "stop 2", "attach 3"}, m.ops)
+	assert.True(t, closed)
+	assert.NotEqual(t, foo, a.egresses["foo"])
+
+	m.reset()
+	m.onAttach = func() {
+		// the previous configuration is restored
+		// once the new one couldn't be attached
+		if len(m.ops) > 2 {
+			m.attachErr = nil
+		}
+	}
+	m.attachErr = errors.New("attach failed")
+	cfg = a.cfg
+
+	assert.Error(t, a.apply(testConfig(10)))
+	assert.Equal(t, []string{"load 4", "stop 3", "stop 4", "load 5", "attach 5"}, m.ops)
+	assert.Equal(t, cfg, a.cfg)
+	assert.Len(t, a.egresses, 2)
+	assert.Equal(t, 5, a.tps[0].id)
+	assert.Equal(t, 1, a.tps[1].id)
+}
+
+func TestReloadRestore(t *testing.T) {
+	a, m := testAgent(t)
+	cfg := a.cfg
+
+	m.attachErr = errors.New("attach failed")
+
+	// the replaced tracepoint is still attached, so
+	// only the new one is stopped.
+	assert.Error(t, a.apply(testConfig(20)))
+	assert.Equal(t, []string{"load 2", "stop 2"}, m.ops)
+	assert.Equal(t, cfg, a.cfg)
+	assert.Len(t, a.egresses, 2)
+	assert.Len(t, a.tps, 2)
+	assert.Equal(t, 0, a.tps[0].id)
+	assert.Equal(t, 1, a.tps[1].id)
+}
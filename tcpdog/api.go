@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/mehrdadrad/tcpdog/egress"
+)
+
+// startAPI starts the agent's http api, the api address
+// is not reloadable.
+func startAPI(a *agent) {
+	addr := a.cfg.API
+	if addr == "" {
+		return
+	}
+
+	logger := a.cfg.Logger()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := a.reload(); err != nil {
+			logger.Error("reload", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintln(w, "configuration has been reloaded")
+	})
+
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dropped":   a.bpf.Dropped(),
+			"overflow":  a.bpf.Counters(),
+			"perf_lost": a.bpf.PerfLost(),
+			"egress":    egress.Stats(),
+		})
+	})
+
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		logger.Fatal("api", zap.Error(err))
+	}()
+
+	logger.Info("api", zap.String("msg", "api has been started at "+addr))
+}
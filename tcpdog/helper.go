@@ -3,13 +3,19 @@ package main
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 
+	"go.uber.org/zap"
+
 	"github.com/mehrdadrad/tcpdog/config"
 	"github.com/mehrdadrad/tcpdog/ebpf"
+	"github.com/mehrdadrad/tcpdog/expr"
 )
 
 func validate(cfg *config.Config) error {
+	kernel := ebpf.ProbeKernel()
+
 	for i, tp := range cfg.Tracepoints {
 		// fields validation
 		err := validateFields(cfg, tp.Fields)
@@ -17,6 +23,18 @@ func validate(cfg *config.Config) error {
 			return err
 		}
 
+		// fields availability at the running kernel
+		err = validateKernel(cfg, kernel, tp.Fields)
+		if err != nil {
+			return err
+		}
+
+		// derived fields validation
+		err = validateDerived(cfg, tp.Fields)
+		if err != nil {
+			return err
+		}
+
 		// tcpstatus validation
 		s, err := ebpf.ValidateTCPStatus(tp.TCPState)
 		if err != nil {
@@ -37,7 +55,7 @@ func validate(cfg *config.Config) error {
 		}
 	}
 
-	return nil
+	return validateProjections(cfg)
 }
 
 func validateFields(cfg *config.Config, name string) error {
@@ -57,9 +75,78 @@ func validateFields(cfg *config.Config, name string) error {
 	return nil
 }
 
+func validateKernel(cfg *config.Config, kernel *ebpf.Kernel, name string) error {
+	var fields []config.Field
+
+	if cfg.UnavailableFields != "fail" && cfg.UnavailableFields != "drop" {
+		return fmt.Errorf("invalid unavailable fields policy: %s", cfg.UnavailableFields)
+	}
+
+	for _, f := range cfg.Fields[name] {
+		err := kernel.CheckField(f.Name)
+		if err == nil {
+			fields = append(fields, f)
+			continue
+		}
+
+		if cfg.UnavailableFields == "fail" {
+			return err
+		}
+
+		cfg.Logger().Warn("kernel", zap.String("msg", f.Name+" has been dropped"), zap.Error(err))
+	}
+
+	if len(fields) < 1 {
+		return fmt.Errorf("no field is available at kernel %s (%s)", kernel.Release, name)
+	}
+
+	cfg.Fields[name] = fields
+
+	return nil
+}
+
+func validateDerived(cfg *config.Config, name string) error {
+	known := map[string]bool{"Timestamp": true, "Hostname": true}
+	numeric := map[string]bool{}
+	for _, f := range cfg.Fields[name] {
+		known[f.Name] = true
+		numeric[f.Name] = ebpf.IsNumeric(f.Name)
+	}
+
+	for _, d := range cfg.Derived[name] {
+		if d.Name == "" {
+			return fmt.Errorf("derived field without name (%s)", name)
+		}
+
+		if _, err := ebpf.ValidateField(d.Name); err == nil || known[d.Name] {
+			return fmt.Errorf("derived field %s conflicts with a field (%s)", d.Name, name)
+		}
+
+		e, err := expr.Parse(d.Expr)
+		if err != nil {
+			return fmt.Errorf("derived field %s: %v", d.Name, err)
+		}
+
+		for _, v := range e.Vars() {
+			if !known[v] || v == "Timestamp" || v == "Hostname" {
+				return fmt.Errorf("derived field %s: %s is not captured at %s", d.Name, v, name)
+			}
+
+			if !numeric[v] {
+				return fmt.Errorf("derived field %s: %s is not a number", d.Name, v)
+			}
+		}
+
+		known[d.Name] = true
+		numeric[d.Name] = true
+	}
+
+	return nil
+}
+
 func validateMix(cfg *config.Config, tp config.Tracepoint) error {
-	if _, ok := cfg.Egress[tp.Egress]; !ok {
-		return fmt.Errorf("egress not found: %s", tp.Egress)
+	if err := validateEgresses(cfg, tp); err != nil {
+		return err
 	}
 
 	for _, inet := range tp.INet {
@@ -75,6 +162,129 @@ func validateMix(cfg *config.Config, tp config.Tracepoint) error {
 	return nil
 }
 
+func validateEgresses(cfg *config.Config, tp config.Tracepoint) error {
+	if tp.Egress != "" && len(tp.Egresses) > 0 {
+		return fmt.Errorf("egress and egresses are mutually exclusive (%s)", tp.Name)
+	}
+
+	known := map[string]bool{}
+	for _, f := range cfg.GetTPOutFields(tp.Fields) {
+		known[f.Name] = true
+	}
+
+	if tp.Routes != "" {
+		return validateRoutes(cfg, tp, known)
+	}
+
+	return validateEgressRefs(cfg, tp, tp.EgressRefs(), known)
+}
+
+func validateRoutes(cfg *config.Config, tp config.Tracepoint, known map[string]bool) error {
+	if tp.Egress != "" || len(tp.Egresses) > 0 {
+		return fmt.Errorf("routes and egress are mutually exclusive (%s)", tp.Name)
+	}
+
+	routes, ok := cfg.Routes[tp.Routes]
+	if !ok || len(routes) < 1 {
+		return fmt.Errorf("routes not found: %s", tp.Routes)
+	}
+
+	projections := map[string][]string{}
+	for i, r := range routes {
+		if len(r.Egresses) < 1 {
+			return fmt.Errorf("route %d without egress (%s)", i, tp.Routes)
+		}
+
+		if r.Match != "" {
+			if err := validateExpr(r.Match, known, tp.Fields); err != nil {
+				return fmt.Errorf("route %d match: %v (%s)", i, err, tp.Routes)
+			}
+		}
+
+		if err := validateEgressRefs(cfg, tp, r.Egresses, known); err != nil {
+			return err
+		}
+
+		// an egress is started once so its projection
+		// should be the same at all the routes.
+		for _, ref := range r.Egresses {
+			if p, ok := projections[ref.Name]; ok && !reflect.DeepEqual(p, ref.Fields) {
+				return fmt.Errorf("egress %s has different fields at the routes (%s)", ref.Name, tp.Routes)
+			}
+			projections[ref.Name] = ref.Fields
+		}
+	}
+
+	return nil
+}
+
+// validateProjections validates the egresses which are shared by the
+// tracepoints, an egress is started once so its projection should be
+// the same at all the tracepoints.
+func validateProjections(cfg *config.Config) error {
+	projections := map[string][]string{}
+	for _, tp := range cfg.Tracepoints {
+		for _, ref := range cfg.GetTPEgressRefs(tp) {
+			if p, ok := projections[ref.Name]; ok && !reflect.DeepEqual(p, ref.Fields) {
+				return fmt.Errorf("egress %s has different fields at the tracepoints (%s)", ref.Name, tp.Name)
+			}
+			projections[ref.Name] = ref.Fields
+		}
+	}
+
+	return nil
+}
+
+func validateEgressRefs(cfg *config.Config, tp config.Tracepoint, refs []config.EgressRef, known map[string]bool) error {
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		e, ok := cfg.Egress[ref.Name]
+		if !ok {
+			return fmt.Errorf("egress not found: %s", ref.Name)
+		}
+
+		if seen[ref.Name] {
+			return fmt.Errorf("duplicate egress %s (%s)", ref.Name, tp.Name)
+		}
+		seen[ref.Name] = true
+
+		if err := ebpf.ValidateOverflow(e.Overflow); err != nil {
+			return fmt.Errorf("%v (%s)", err, ref.Name)
+		}
+
+		for _, name := range ref.Fields {
+			if !known[name] {
+				return fmt.Errorf("egress %s: %s is not captured at %s", ref.Name, name, tp.Fields)
+			}
+		}
+
+		if ref.Filter == "" {
+			continue
+		}
+
+		if err := validateExpr(ref.Filter, known, tp.Fields); err != nil {
+			return fmt.Errorf("egress %s filter: %v", ref.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateExpr(s string, known map[string]bool, fields string) error {
+	e, err := expr.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range e.Vars() {
+		if !known[v] {
+			return fmt.Errorf("%s is not captured at %s", v, fields)
+		}
+	}
+
+	return nil
+}
+
 func exit(err error) {
 	fmt.Println(err)
 	os.Exit(1)
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mehrdadrad/tcpdog/config"
+)
+
+func TestValidateDerived(t *testing.T) {
+	cfg := &config.Config{
+		Fields: map[string][]config.Field{
+			"f1": {{Name: "TotalRetrans"}, {Name: "SegsOut"}, {Name: "Task"}},
+		},
+		Derived: map[string][]config.DerivedField{
+			"f1": {
+				{Name: "LossRate", Expr: "TotalRetrans / SegsOut"},
+				{Name: "LossPercent", Expr: "LossRate * 100"},
+			},
+		},
+	}
+
+	assert.NoError(t, validateDerived(cfg, "f1"))
+
+	// the string fields are not numeric operands
+	cfg.Derived["f1"] = []config.DerivedField{{Name: "Foo", Expr: "Task + 1"}}
+	assert.Error(t, validateDerived(cfg, "f1"))
+
+	cfg.Derived["f1"] = []config.DerivedField{{Name: "Foo", Expr: "RTT * 2"}}
+	assert.Error(t, validateDerived(cfg, "f1"))
+}
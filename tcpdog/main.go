@@ -2,16 +2,14 @@ package main
 
 import (
 	"C"
-	"bytes"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
 
 	"github.com/sethvargo/go-signalcontext"
 	"go.uber.org/zap"
 
 	"github.com/mehrdadrad/tcpdog/config"
-	"github.com/mehrdadrad/tcpdog/ebpf"
-	"github.com/mehrdadrad/tcpdog/egress"
 )
 
 var version string
@@ -33,45 +31,22 @@ func main() {
 	ctx, cancel := signalcontext.OnInterrupt()
 	defer cancel()
 
-	ctx = cfg.WithContext(ctx)
-
-	e := ebpf.New(cfg)
-	defer e.Close()
-
-	bufPool := &sync.Pool{
-		New: func() interface{} {
-			return new(bytes.Buffer)
-		},
-	}
-
-	chMap := map[string]chan *bytes.Buffer{}
-	for _, tracepoint := range cfg.Tracepoints {
-		if _, ok := chMap[tracepoint.Egress]; ok {
-			continue
-		}
-
-		ch := make(chan *bytes.Buffer, 1000)
-		chMap[tracepoint.Egress] = ch
-		err := egress.Start(ctx, tracepoint, bufPool, ch)
-		if err != nil {
-			logger.Fatal("egress", zap.Error(err))
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	a := newAgent(cfg)
+	startAPI(a)
+
+	for {
+		select {
+		case <-hup:
+			logger.Info("tcpdog", zap.String("msg", "reloading configuration"))
+			if err := a.reload(); err != nil {
+				logger.Error("reload", zap.Error(err))
+			}
+		case <-ctx.Done():
+			a.shutdown()
+			return
 		}
-
-		eType := cfg.Egress[tracepoint.Egress].Type
-		logger.Info("egress", zap.String("msg", tracepoint.Egress+" has been started"), zap.String("type", eType))
 	}
-
-	for index, tracepoint := range cfg.Tracepoints {
-		e.Start(ctx, ebpf.TP{
-			Name:    tracepoint.Name,
-			Index:   index,
-			BufPool: bufPool,
-			OutChan: chMap[tracepoint.Egress],
-			INet:    tracepoint.INet,
-			Workers: tracepoint.Workers,
-			Fields:  cfg.GetTPFields(tracepoint.Fields),
-		})
-	}
-
-	<-ctx.Done()
 }